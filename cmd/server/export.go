@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/export"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportHandler serves GET /v1/users:export as a file download. It reads the
+// ExportUsers stream from the gRPC server and copies the chunks into the
+// response body as they arrive.
+func exportHandler(client pb.UserServiceClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := exportRequest(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format, err := export.ParseFormat(req.Format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stream, err := client.ExportUsers(r.Context(), req)
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		// Errors before the first chunk can still be reported as a status
+		chunk, err := stream.Recv()
+		if err != nil && err != io.EOF {
+			writeExportError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, format.Extension()))
+		w.WriteHeader(http.StatusOK)
+		for err == nil {
+			if _, err := w.Write(chunk.Data); err != nil {
+				return
+			}
+			chunk, err = stream.Recv()
+		}
+		if err != io.EOF {
+			// Abort the response, so the client sees a broken transfer rather
+			// than a complete but truncated file
+			slog.Error("User export failed mid-stream", "error", err)
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// exportRequest reads the export filters from the query string
+func exportRequest(query url.Values) (*pb.ExportUsersRequest, error) {
+	req := &pb.ExportUsersRequest{Format: query.Get("format")}
+	if v := query.Get("is_blocked"); v != "" {
+		blocked, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("is_blocked must be true or false")
+		}
+		req.IsBlocked = &blocked
+	}
+	if v := query.Get("show_deleted"); v != "" {
+		showDeleted, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("show_deleted must be true or false")
+		}
+		req.ShowDeleted = showDeleted
+	}
+	for _, p := range []struct {
+		name string
+		dst  **timestamppb.Timestamp
+	}{
+		{"created_after", &req.CreatedAfter},
+		{"created_before", &req.CreatedBefore},
+	} {
+		if v := query.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+			}
+			*p.dst = timestamppb.New(t)
+		}
+	}
+	return req, nil
+}
+
+// writeExportError reports a gRPC error with the status code the gateway
+// would use
+func writeExportError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	http.Error(w, st.Message(), runtime.HTTPStatusFromCode(st.Code()))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// gatewayErrorHandler reports a stale etag or If-Match (ABORTED) as
+// 412 Precondition Failed instead of the gateway's default 409 Conflict.
+func gatewayErrorHandler(ctx context.Context, mux *runtime.ServeMux, m runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	if status.Code(err) == codes.Aborted {
+		w = &statusOverrideWriter{ResponseWriter: w, status: http.StatusPreconditionFailed}
+	}
+	runtime.DefaultHTTPErrorHandler(ctx, mux, m, w, r, err)
+}
+
+type statusOverrideWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusOverrideWriter) WriteHeader(int) {
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// setETagHeader exposes the returned user's etag as an ETag header so REST
+// clients can send it back in If-Match
+func setETagHeader(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	if r, ok := resp.(interface{ GetUser() *pb.User }); ok && r.GetUser() != nil {
+		w.Header().Set("ETag", strconv.Quote(r.GetUser().GetEtag()))
+	}
+	return nil
+}
+
+// incomingHeaderMatcher forwards the headers recorded in the audit log as
+// plain metadata, and every other header as the gateway does by default
+func incomingHeaderMatcher(key string) (string, bool) {
+	switch lower := strings.ToLower(key); lower {
+	case "x-actor-id", "x-request-id":
+		return lower, true
+	default:
+		return runtime.DefaultHeaderMatcher(key)
+	}
+}
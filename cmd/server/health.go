@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// serviceHealth tracks whether the storage backend is reachable. It drives
+// the gRPC health service, for both the server as a whole ("") and the
+// UserService, and turns UserService calls away while it is down.
+type serviceHealth struct {
+	server  *health.Server
+	serving atomic.Bool
+}
+
+// newServiceHealth registers the health service on grpcServer, starting out
+// as NOT_SERVING
+func newServiceHealth(grpcServer *grpc.Server) *serviceHealth {
+	h := &serviceHealth{server: health.NewServer()}
+	healthpb.RegisterHealthServer(grpcServer, h.server)
+	h.SetServing(false)
+	return h
+}
+
+// SetServing reports the storage as reachable or not
+func (h *serviceHealth) SetServing(serving bool) {
+	h.serving.Store(serving)
+	servingStatus := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		servingStatus = healthpb.HealthCheckResponse_SERVING
+	}
+	h.server.SetServingStatus("", servingStatus)
+	h.server.SetServingStatus(pb.UserService_ServiceDesc.ServiceName, servingStatus)
+}
+
+// Shutdown reports NOT_SERVING for good, so clients move away while the
+// server drains
+func (h *serviceHealth) Shutdown() {
+	h.serving.Store(false)
+	h.server.Shutdown()
+}
+
+// unaryInterceptor fails UserService calls with UNAVAILABLE while the storage
+// is not reachable, instead of letting them time out or fail as internal
+// errors. Health and reflection calls are let through.
+func (h *serviceHealth) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !h.serving.Load() && strings.HasPrefix(info.FullMethod, "/"+pb.UserService_ServiceDesc.ServiceName+"/") {
+		return nil, status.Error(codes.Unavailable, "storage is unavailable")
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor does the same as unaryInterceptor for streaming calls
+func (h *serviceHealth) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !h.serving.Load() && strings.HasPrefix(info.FullMethod, "/"+pb.UserService_ServiceDesc.ServiceName+"/") {
+		return status.Error(codes.Unavailable, "storage is unavailable")
+	}
+	return handler(srv, ss)
+}
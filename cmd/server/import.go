@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/config"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/importer"
+)
+
+const importUsage = `Usage: server import [flags] FILE
+
+Creates users from a JSONL or CSV file, such as one written by an export, and
+prints the outcome of every row. Rows are validated like CreateUser requests;
+a row that fails doesn't stop the others.
+
+Flags:
+`
+
+// runImport implements the import subcommand
+func runImport(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "jsonl or csv (default: from the file extension)")
+	mode := flags.String("mode", string(importer.ModeCreate), "what to do with a taken email or phone: create (fail the row), skip-duplicates or upsert")
+	dryRun := flags.Bool("dry-run", false, "validate and check every row without writing anything")
+	concurrency := flags.Int("concurrency", cfg.Import.Concurrency, "rows written at once")
+	reason := flags.String("reason", "bulk import", "reason recorded in the audit log")
+	failedOnly := flags.Bool("failed-only", false, "only list rows that failed or were skipped")
+	timeout := flags.Duration("timeout", time.Hour, "give up after this long")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), importUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one file to import")
+	}
+	path := flags.Arg(0)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	f, err := export.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	m, err := importer.ParseMode(*mode)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = audit.WithInfo(ctx, audit.Info{Method: "ImportUsers", Actor: audit.SystemActor, Reason: *reason})
+
+	db, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := importer.Import(ctx, db, emailNormalizer(cfg).Canonical, file, importer.Options{
+		Format:      f,
+		Mode:        m,
+		DryRun:      *dryRun,
+		Concurrency: *concurrency,
+	})
+	if report == nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tSTATUS\tUSER\tERROR")
+	for _, row := range report.Rows {
+		if *failedOnly && row.Err == nil {
+			continue
+		}
+		rowErr := ""
+		if row.Err != nil {
+			rowErr = row.Err.Error()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", row.Line, row.Status, orDash(row.UserID), orDash(rowErr))
+	}
+	if flushErr := w.Flush(); flushErr != nil {
+		return flushErr
+	}
+
+	prefix := ""
+	if report.DryRun {
+		prefix = "Dry run: "
+	}
+	fmt.Printf("\n%s%d rows: %d created, %d updated, %d unchanged, %d skipped, %d failed\n", prefix,
+		len(report.Rows), report.Created, report.Updated, report.Unchanged, report.Skipped, report.Failed)
+	if err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d rows failed", report.Failed)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"log/slog"
@@ -9,14 +10,23 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-
 	"syscall"
-
+	"time"
 
 	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
 	"github.com/Divyansh031/user-service/internal/config"
+	"github.com/Divyansh031/user-service/internal/emailnorm"
+	"github.com/Divyansh031/user-service/internal/events"
 	"github.com/Divyansh031/user-service/internal/grpc/handlers"
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/pagetoken"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/cache"
+	"github.com/Divyansh031/user-service/internal/storage/lazy"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/Divyansh031/user-service/internal/storage/postgres"
 	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/Divyansh031/user-service/internal/storage/sqlite"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"google.golang.org/grpc"
@@ -38,32 +48,47 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
-	slog.Info("Starting user service", "env", cfg.Env)
-
-	// Initialize database
-	slog.Info("Initializing ScyllaDB", "hosts", cfg.ScyllaDB.Hosts, "keyspace", cfg.ScyllaDB.Keyspace)
-	db, err := scylla.NewScyllaDB(cfg.ScyllaDB.Hosts, cfg.ScyllaDB.Port, cfg.ScyllaDB.Keyspace, cfg.ScyllaDB.Consistency)
-	if err != nil {
-		slog.Error("Failed to initialize ScyllaDB", "error", err)
-		log.Fatal(err)
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := runMigrate(cfg, os.Args[2:]); err != nil {
+				slog.Error("Migration failed", "error", err)
+				os.Exit(1)
+			}
+		case "reconcile":
+			if err := runReconcile(cfg, os.Args[2:]); err != nil {
+				slog.Error("Reconciliation failed", "error", err)
+				os.Exit(1)
+			}
+		case "import":
+			if err := runImport(cfg, os.Args[2:]); err != nil {
+				slog.Error("Import failed", "error", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %q, expected migrate, reconcile or import\n", os.Args[1])
+			os.Exit(2)
+		}
+		return
 	}
-	defer db.Close()
 
-	slog.Info("ScyllaDB initialized successfully")
+	slog.Info("Starting user service", "env", cfg.Env)
+
+	// Shutdown signals also cut short the wait for the database
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Start gRPC server
+	// Start gRPC server. It comes up before the database so that health
+	// checks can report NOT_SERVING while the connection is retried.
 	grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPC.Port))
 	if err != nil {
 		slog.Error("Failed to listen on gRPC port", "port", cfg.GRPC.Port, "error", err)
 		log.Fatal(err)
 	}
 
-	grpcServer := grpc.NewServer()
-	userServiceServer := handlers.NewUserServiceServer(db)
-	pb.RegisterUserServiceServer(grpcServer, userServiceServer)
-
-	// Register reflection for grpcurl
-	reflection.Register(grpcServer)  // Allows grpcurl to inspect your API
+	grpcServer, serverHealth, lazyDB := newGRPCServer(cfg)
+	defer lazyDB.Close()
 
 	slog.Info("gRPC server listening", "port", cfg.GRPC.Port)
 
@@ -76,20 +101,268 @@ func main() {
 
 	// Start HTTP/REST server
 	go startRESTServer(cfg)
+	if cfg.Debug.Addr != "" {
+		go startDebugServer(cfg.Debug.Addr)
+	}
 
-	// Graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Initialize database
+	if err := validateStorage(cfg); err != nil {
+		slog.Error("Invalid storage configuration", "driver", cfg.Storage.Driver, "error", err)
+		log.Fatal(err)
+	}
+	connectOpts := lazy.ConnectOptions{
+		Backoff:    cfg.Storage.ConnectBackoff,
+		MaxBackoff: cfg.Storage.ConnectMaxBackoff,
+		Deadline:   cfg.Storage.ConnectDeadline,
+	}
+	db, err := lazy.Connect(ctx, connectOpts, func() (storage.Storage, error) { return newStorage(cfg) })
+	if err != nil {
+		if ctx.Err() != nil {
+			slog.Info("Shutdown signal received before storage was connected")
+			grpcServer.Stop()
+			return
+		}
+		slog.Error("Failed to initialize storage", "driver", cfg.Storage.Driver, "error", err)
+		log.Fatal(err)
+	}
+	backend := db
+	scyllaDB, _ := db.(*scylla.ScyllaDB)
+	if scyllaDB != nil {
+		expvar.Publish("scylla_lookups", expvar.Func(func() any { return scyllaDB.Stats() }))
+	}
+
+	if cfg.Cache.Enabled {
+		cached := cache.NewCachedStorage(db, cfg.Cache.Size, cfg.Cache.TTL)
+		expvar.Publish("storage_cache", expvar.Func(func() any { return cached.Stats() }))
+		slog.Info("Storage cache enabled", "size", cfg.Cache.Size, "ttl", cfg.Cache.TTL)
+		db = cached
+	}
+	lazyDB.Set(db)
+
+	// Start background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if cfg.Purge.Enabled {
+		purger := jobs.NewPurger(db, cfg.Purge.Retention, cfg.Purge.Interval)
+		go purger.Run(jobsCtx)
+		slog.Info("Purger started", "retention", cfg.Purge.Retention, "interval", cfg.Purge.Interval)
+	}
 
-	<-sigChan
+	if cfg.Reconcile.Enabled {
+		if scyllaDB == nil {
+			slog.Warn("Lookup reconciler only runs on the scylladb driver", "driver", cfg.Storage.Driver)
+		} else {
+			reconciler := jobs.NewReconciler(scyllaDB, cfg.Reconcile.Interval, scylla.ReconcileOptions{
+				Repair:      cfg.Reconcile.Repair,
+				GracePeriod: cfg.Reconcile.GracePeriod,
+			})
+			go reconciler.Run(jobsCtx)
+			slog.Info("Lookup reconciler started", "interval", cfg.Reconcile.Interval, "repair", cfg.Reconcile.Repair)
+		}
+	}
+
+	// Failed lookup writes are queued only on ScyllaDB; the other backends
+	// update everything in one transaction
+	if cfg.IndexRetry.Enabled && scyllaDB != nil {
+		retrier := jobs.NewIndexRetrier(scyllaDB, cfg.IndexRetry.Interval, scylla.RetryOptions{
+			MinBackoff: cfg.IndexRetry.MinBackoff,
+			MaxBackoff: cfg.IndexRetry.MaxBackoff,
+		})
+		expvar.Publish("index_retry_queue", expvar.Func(func() any { return retrier.Stats() }))
+		go retrier.Run(jobsCtx)
+		slog.Info("Index retry job started", "interval", cfg.IndexRetry.Interval)
+	}
+
+	// Every backend writes change events to its outbox
+	if outbox, ok := backend.(storage.Outbox); ok {
+		publisher, err := newPublisher(cfg)
+		if err != nil {
+			slog.Error("Failed to create event publisher", "publisher", cfg.Events.Publisher, "error", err)
+			log.Fatal(err)
+		}
+		defer publisher.Close()
+		dispatcher := jobs.NewEventDispatcher(outbox, publisher, cfg.Events.Interval, cfg.Events.BatchSize)
+		expvar.Publish("user_events", expvar.Func(func() any { return dispatcher.Stats() }))
+		go dispatcher.Run(jobsCtx)
+		slog.Info("Event dispatcher started", "publisher", cfg.Events.Publisher, "interval", cfg.Events.Interval)
+	}
+
+	// The in-memory backend can't become unreachable, so it isn't monitored
+	if pinger, ok := backend.(jobs.Pinger); ok {
+		monitor := jobs.NewHealthMonitor(pinger, jobs.HealthOptions{
+			Interval:   cfg.Storage.HealthInterval,
+			Failures:   cfg.Storage.HealthFailures,
+			Backoff:    cfg.Storage.ConnectBackoff,
+			MaxBackoff: cfg.Storage.ConnectMaxBackoff,
+		}, serverHealth.SetServing)
+		expvar.Publish("storage_health", expvar.Func(func() any { return monitor.Stats() }))
+		go monitor.Run(jobsCtx)
+	}
+
+	serverHealth.SetServing(true)
+	slog.Info("Storage connected, serving requests", "driver", cfg.Storage.Driver)
+
+	// Graceful shutdown
+	<-ctx.Done()
 	slog.Info("Shutdown signal received, gracefully shutting down...")
 
 	// Shutdown gRPC server
+	serverHealth.Shutdown()
 	grpcServer.GracefulStop()
+	stopJobs()
 
 	slog.Info("User service stopped")
 }
 
+// newGRPCServer builds the gRPC server with the UserService backed by a lazy
+// storage, which is set once the database has been connected
+func newGRPCServer(cfg *config.Config) (*grpc.Server, *serviceHealth, *lazy.Storage) {
+	var serverHealth *serviceHealth
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			return serverHealth.unaryInterceptor(ctx, req, info, handler)
+		}),
+		grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return serverHealth.streamInterceptor(srv, ss, info, handler)
+		}),
+	)
+	serverHealth = newServiceHealth(grpcServer)
+
+	if cfg.Paging.TokenSecret == "" {
+		slog.Warn("PAGE_TOKEN_SECRET is not set, page tokens will not survive a restart")
+	}
+	pageTokens := pagetoken.NewCodec(cfg.Paging.TokenSecret, cfg.Paging.TokenTTL)
+	lazyDB := lazy.New()
+	userServiceServer := handlers.NewUserServiceServer(lazyDB, pageTokens, emailNormalizer(cfg), cfg.Import.Concurrency)
+	pb.RegisterUserServiceServer(grpcServer, userServiceServer)
+
+	// Register reflection for grpcurl
+	reflection.Register(grpcServer) // Allows grpcurl to inspect your API
+
+	return grpcServer, serverHealth, lazyDB
+}
+
+// emailNormalizer builds the canonical email rules from the config
+func emailNormalizer(cfg *config.Config) *emailnorm.Normalizer {
+	return emailnorm.New(emailnorm.Rules{
+		LowercaseLocal:    cfg.Email.LowercaseLocal,
+		IgnoreDotsDomains: cfg.Email.IgnoreDotsDomains,
+		StripPlusDomains:  cfg.Email.StripPlusDomains,
+		DomainAliases:     cfg.Email.DomainAliases,
+	})
+}
+
+// newPublisher creates the publisher selected by cfg.Events.Publisher
+func newPublisher(cfg *config.Config) (events.Publisher, error) {
+	switch cfg.Events.Publisher {
+	case "log":
+		return events.NewLogPublisher(), nil
+	case "file":
+		return events.NewFilePublisher(cfg.Events.FilePath)
+	default:
+		return nil, fmt.Errorf("unknown event publisher %q, expected log or file", cfg.Events.Publisher)
+	}
+}
+
+// scyllaOptions maps the ScyllaDB config onto the storage package's options
+func scyllaOptions(cfg *config.Config) scylla.ClusterOptions {
+	c := cfg.ScyllaDB
+	return scylla.ClusterOptions{
+		Hosts:             c.Hosts,
+		Port:              c.Port,
+		Keyspace:          c.Keyspace,
+		Consistency:       c.Consistency,
+		SerialConsistency: c.SerialConsistency,
+		Username:          c.Username,
+		Password:          c.Password,
+		TLS: scylla.TLSOptions{
+			Enabled:            c.TLS.Enabled,
+			CAFile:             c.TLS.CAFile,
+			CertFile:           c.TLS.CertFile,
+			KeyFile:            c.TLS.KeyFile,
+			ServerName:         c.TLS.ServerName,
+			InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		},
+		LocalDC:             c.LocalDC,
+		TokenAware:          c.TokenAware,
+		ProtoVersion:        c.ProtoVersion,
+		RetryPolicy:         c.RetryPolicy,
+		RetryAttempts:       c.RetryAttempts,
+		RetryMinBackoff:     c.RetryMinBackoff,
+		RetryMaxBackoff:     c.RetryMaxBackoff,
+		SpeculativeAttempts: c.SpeculativeAttempts,
+		SpeculativeDelay:    c.SpeculativeDelay,
+		NumConns:            c.NumConns,
+		ConnectTimeout:      c.ConnectTimeout,
+		Timeout:             c.Timeout,
+		ExportWorkers:       c.ExportWorkers,
+		Operations: map[scylla.Operation]scylla.OperationOptions{
+			scylla.OpRead:  {Consistency: c.ReadConsistency, Timeout: c.ReadTimeout},
+			scylla.OpWrite: {Consistency: c.WriteConsistency, Timeout: c.WriteTimeout},
+			scylla.OpList:  {Consistency: c.ListConsistency, Timeout: c.ListTimeout},
+			scylla.OpCheck: {Consistency: c.CheckConsistency, Timeout: c.CheckTimeout},
+		},
+	}
+}
+
+// validateStorage catches configuration errors that retrying the connection
+// can't fix
+func validateStorage(cfg *config.Config) error {
+	switch cfg.Storage.Driver {
+	case "scylladb":
+		_, err := scylla.NewClusterConfig(scyllaOptions(cfg))
+		return err
+	case "postgres", "sqlite", "memory":
+		return nil
+	default:
+		return fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+// newStorage opens the storage backend selected by cfg.Storage.Driver
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.Storage.Driver {
+	case "scylladb":
+		if cfg.ScyllaDB.AutoMigrate {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			err := migrateUp(ctx, cfg)
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to migrate schema: %w", err)
+			}
+		}
+		slog.Info("Initializing ScyllaDB", "hosts", cfg.ScyllaDB.Hosts, "keyspace", cfg.ScyllaDB.Keyspace)
+		db, err := scylla.NewScyllaDB(scyllaOptions(cfg))
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("ScyllaDB initialized successfully")
+		return db, nil
+	case "postgres":
+		slog.Info("Initializing PostgreSQL")
+		db, err := postgres.NewPostgresDB(cfg.Postgres.DSN, cfg.Postgres.MaxOpenConns)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("PostgreSQL initialized successfully")
+		return db, nil
+	case "sqlite":
+		slog.Info("Initializing SQLite", "path", cfg.SQLite.Path)
+		db, err := sqlite.NewSQLiteDB(cfg.SQLite.Path)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("SQLite initialized successfully")
+		return db, nil
+	case "memory":
+		slog.Warn("Using in-memory storage, data will be lost on restart")
+		return memory.NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
 // startRESTServer starts a simple HTTP REST server
 func startRESTServer(cfg *config.Config) {
 	ctx := context.Background()
@@ -107,7 +380,11 @@ func startRESTServer(cfg *config.Config) {
 	defer conn.Close()
 
 	// Gateway mux (This expects /v1/users)
-	gwMux := runtime.NewServeMux()
+	gwMux := runtime.NewServeMux(
+		runtime.WithErrorHandler(gatewayErrorHandler),
+		runtime.WithForwardResponseOption(setETagHeader),
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+	)
 
 	client := pb.NewUserServiceClient(conn)
 	if err := pb.RegisterUserServiceHandlerClient(ctx, gwMux, client); err != nil {
@@ -117,6 +394,8 @@ func startRESTServer(cfg *config.Config) {
 
 	// NEW MUX — This will handle /api/v1/users
 	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/users:export", exportHandler(client))
+	mux.Handle("GET /v1/users:export", exportHandler(client))
 	mux.Handle("/api/", http.StripPrefix("/api", gwMux))
 	mux.Handle("/", gwMux) // fallback for /v1/users (if someone uses directly)
 
@@ -131,4 +410,21 @@ func startRESTServer(cfg *config.Config) {
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		slog.Error("HTTP server error", "error", err)
 	}
-}
\ No newline at end of file
+}
+
+// startDebugServer serves the expvar counters on their own listener, away
+// from the public REST port
+func startDebugServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("Debug server listening", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Error("Debug server error", "error", err)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/config"
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+)
+
+const migrateUsage = `Usage: server migrate [up|status|down|emails|search|lists|counters] [flags]
+
+  up        apply all pending migrations (default)
+  status    list migrations and whether they are applied
+  down      roll back the latest migrations; needs -confirm=<keyspace>
+  emails    store the canonical email of every user, after the canonical
+            email migration or a change of the email rules; works on every
+            storage driver
+  search    rebuild the search index of every user, after the search index
+            migration or a change of how users are tokenized
+            (scylladb, sqlite and postgres)
+  lists     copy every user into the ScyllaDB tables behind ListUsers ordering
+            and created_at filters, after the list table migration (scylladb)
+  counters  recount users into the ScyllaDB user counters, after upgrading a
+            keyspace with existing users or when the counts drift (scylladb)
+
+Flags:
+`
+
+// runMigrate implements the migrate subcommand
+func runMigrate(cfg *config.Config, args []string) error {
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "number of migrations to roll back (down only)")
+	confirm := flags.String("confirm", "", "keyspace name, required to roll back since it drops data (down only)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "give up after this long")
+	dryRun := flags.Bool("dry-run", false, "only report what would change (emails only)")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch action {
+	case "up":
+		return migrateUp(ctx, cfg)
+	case "status":
+		return migrateStatus(ctx, cfg)
+	case "down":
+		if *confirm != cfg.ScyllaDB.Keyspace {
+			return fmt.Errorf("rolling back drops data; pass -confirm=%s to proceed", cfg.ScyllaDB.Keyspace)
+		}
+		return migrateDown(ctx, cfg, *steps)
+	case "emails":
+		return migrateEmails(ctx, cfg, *dryRun)
+	case "search":
+		return migrateSearch(ctx, cfg)
+	case "lists":
+		return migrateLists(ctx, cfg)
+	case "counters":
+		return migrateCounters(ctx, cfg)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
+
+func newMigrator(cfg *config.Config) (*scylla.Migrator, error) {
+	return scylla.NewMigrator(scyllaOptions(cfg), scylla.Replication{
+		Class:       cfg.ScyllaDB.Replication.Class,
+		Factor:      cfg.ScyllaDB.Replication.Factor,
+		DataCenters: cfg.ScyllaDB.Replication.DataCenters,
+	})
+}
+
+// migrateUp applies pending migrations. It is also used on startup when
+// auto_migrate is enabled.
+func migrateUp(ctx context.Context, cfg *config.Config) error {
+	migrator, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Info("Schema is up to date", "keyspace", cfg.ScyllaDB.Keyspace, "applied", len(applied))
+	return nil
+}
+
+func migrateStatus(ctx context.Context, cfg *config.Config) error {
+	migrator, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+	return w.Flush()
+}
+
+func migrateDown(ctx context.Context, cfg *config.Config, steps int) error {
+	migrator, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	reverted, err := migrator.Down(ctx, steps)
+	if err != nil {
+		return err
+	}
+	for _, m := range reverted {
+		slog.Info("Rolled back migration", "version", m.Version, "name", m.Name)
+	}
+	return nil
+}
+
+// migrateEmails backfills canonical emails. Conflicts, where two users only
+// differ before normalization, are listed and left for an operator.
+func migrateEmails(ctx context.Context, cfg *config.Config, dryRun bool) error {
+	db, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := jobs.BackfillCanonicalEmails(ctx, db, emailNormalizer(cfg).Canonical, dryRun)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tEMAIL\tCANONICAL EMAIL\tOWNED BY")
+	for _, c := range report.Conflicts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.UserID, c.Email, c.CanonicalEmail, orDash(c.OwnerID))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	verb := "updated"
+	if dryRun {
+		verb = "would update"
+	}
+	fmt.Printf("\nScanned %d users: %s %d, %d conflicts\n", report.Scanned, verb, report.Updated, len(report.Conflicts))
+	if len(report.Conflicts) > 0 {
+		return fmt.Errorf("%d users have a canonical email that belongs to another user", len(report.Conflicts))
+	}
+	return nil
+}
+
+// migrateSearch rebuilds the search index from the users table
+func migrateSearch(ctx context.Context, cfg *config.Config) error {
+	db, err := newStorage(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	indexer, ok := db.(storage.SearchIndexer)
+	if !ok {
+		return fmt.Errorf("the %s storage driver has no search index to rebuild", cfg.Storage.Driver)
+	}
+	n, err := indexer.RebuildSearchIndex(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Indexed %d users\n", n)
+	return nil
+}
+
+// migrateLists backfills the ScyllaDB list tables from the users table
+func migrateLists(ctx context.Context, cfg *config.Config) error {
+	if cfg.Storage.Driver != "scylladb" {
+		return fmt.Errorf("list tables only exist on the scylladb driver, not %q", cfg.Storage.Driver)
+	}
+	db, err := scylla.NewScyllaDB(scyllaOptions(cfg))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	n, err := db.RebuildListTables(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Copied %d users\n", n)
+	return nil
+}
+
+// migrateCounters resets the ScyllaDB user counters from a scan of the users
+// table
+func migrateCounters(ctx context.Context, cfg *config.Config) error {
+	if cfg.Storage.Driver != "scylladb" {
+		return fmt.Errorf("user counters only exist on the scylladb driver, not %q", cfg.Storage.Driver)
+	}
+	db, err := scylla.NewScyllaDB(scyllaOptions(cfg))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	total, blocked, err := db.RecountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Counted %d users, %d blocked\n", total, blocked)
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/config"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+)
+
+const reconcileUsage = `Usage: server reconcile [flags]
+
+Checks users_by_email and users_by_phone against the users table and reports
+every inconsistency. Nothing is changed unless -repair is set.
+
+Flags:
+`
+
+// runReconcile implements the reconcile subcommand
+func runReconcile(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "fix inconsistencies instead of only reporting them")
+	grace := flags.Duration("grace", cfg.Reconcile.GracePeriod, "skip rows written more recently than this")
+	timeout := flags.Duration("timeout", time.Hour, "give up after this long")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), reconcileUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if cfg.Storage.Driver != "scylladb" {
+		return fmt.Errorf("reconcile only applies to the scylladb driver, not %q", cfg.Storage.Driver)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	db, err := scylla.NewScyllaDB(scyllaOptions(cfg))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := db.Reconcile(ctx, scylla.ReconcileOptions{Repair: *repair, GracePeriod: *grace})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tTABLE\tVALUE\tLOOKUP USER\tOWNERS\tREPAIRED")
+	for _, issue := range report.Issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", issue.Kind, issue.Table, issue.Value,
+			orDash(issue.LookupUserID), orDash(strings.Join(issue.UserIDs, ",")), issue.Repaired)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nScanned %d users and %d lookup rows: %d issues, %d repaired\n",
+		report.UsersScanned, report.LookupsScanned, len(report.Issues), report.Repaired)
+	if !*repair && len(report.Issues) > 0 {
+		fmt.Println("Dry run; pass -repair to fix them")
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
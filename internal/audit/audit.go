@@ -0,0 +1,60 @@
+// internal/audit/audit.go
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/google/uuid"
+)
+
+// SystemActor is recorded for changes made without a caller, such as the
+// canonical email backfill
+const SystemActor = "system"
+
+// Info describes the request behind a change. Handlers attach it to the
+// context; storage reads it back when it writes the audit record.
+type Info struct {
+	Method    string
+	Actor     string
+	Reason    string
+	RequestID string
+}
+
+type infoKey struct{}
+
+// WithInfo returns a context that carries info
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// FromContext returns the Info attached to ctx. Without one, or without an
+// actor, the actor is SystemActor.
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(infoKey{}).(Info)
+	if info.Actor == "" {
+		info.Actor = SystemActor
+	}
+	return info
+}
+
+// NewRecord builds the audit record of the change described by event, made
+// by the request described by ctx
+func NewRecord(ctx context.Context, event *domain.Event) *domain.AuditRecord {
+	info := FromContext(ctx)
+	// The record's time is the time in its ID, so filtering by either agrees
+	id := uuid.Must(uuid.NewUUID())
+	sec, nsec := id.Time().UnixTime()
+	return &domain.AuditRecord{
+		ID:         id.String(),
+		UserID:     event.UserID,
+		Action:     event.Type,
+		Method:     info.Method,
+		Actor:      info.Actor,
+		Reason:     info.Reason,
+		RequestID:  info.RequestID,
+		OccurredAt: time.Unix(sec, nsec).UTC(),
+		Changes:    domain.DiffUsers(event.Before, event.After),
+	}
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// AuditRecord records who changed a user, how and why. Storage writes it
+// along with the change, the same way as the change's Event, and keeps it
+// after the user is purged.
+type AuditRecord struct {
+	// ID is a version 1 (time-based) UUID, so records sort by time
+	ID     string
+	UserID string
+	Action EventType
+	// Method is the RPC that made the change, e.g. BlockUser
+	Method string
+	// Actor is the caller identity; "system" for changes made by the
+	// service itself
+	Actor      string
+	Reason     string
+	RequestID  string
+	OccurredAt time.Time
+	Changes    []FieldChange
+}
+
+// FieldChange is one field of User that a change set to a new value
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// DiffUsers lists the fields that differ between before and after, where a
+// nil before stands for a user that didn't exist. Bookkeeping fields (ID,
+// timestamps other than deleted_at, version) are left out.
+func DiffUsers(before, after *User) []FieldChange {
+	if before == nil {
+		before = &User{}
+	}
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"first_name", before.FirstName, after.FirstName},
+		{"last_name", before.LastName, after.LastName},
+		{"gender", before.Gender, after.Gender},
+		{"date_of_birth", formatTime(before.DateOfBirth), formatTime(after.DateOfBirth)},
+		{"phone_number", before.PhoneNumber, after.PhoneNumber},
+		{"email", before.Email, after.Email},
+		{"email_canonical", before.EmailKey(), after.EmailKey()},
+		{"is_blocked", strconv.FormatBool(before.IsBlocked), strconv.FormatBool(after.IsBlocked)},
+		{"deleted_at", formatTime(before.DeletedAt), formatTime(after.DeletedAt)},
+	}
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, FieldChange{Field: f.name, OldValue: f.old, NewValue: f.new})
+		}
+	}
+	return changes
+}
+
+// formatTime renders a zero time as an empty string
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -5,6 +5,8 @@ import "errors"
 // Error variables for domain errors
 var (
 	ErrUserNotFound       = errors.New("user not found")
+	ErrUserNotDeleted     = errors.New("user is not deleted")
+	ErrVersionNotFound    = errors.New("user version not found")
 	ErrEmailAlreadyExists = errors.New("email already exists")
 	ErrPhoneAlreadyExists = errors.New("phone already exists")
 	ErrInvalidFirstName   = errors.New("invalid first name")
@@ -13,6 +15,10 @@ var (
 	ErrInvalidDateOfBirth = errors.New("invalid date of birth")
 	ErrInvalidPhoneNumber = errors.New("invalid phone number")
 	ErrInvalidEmail       = errors.New("invalid email")
+	ErrInvalidPageToken   = errors.New("invalid page token")
+	ErrSearchTooBroad     = errors.New("search matches too many users; add a term or use longer terms")
+	ErrVersionConflict    = errors.New("user was modified concurrently")
 	ErrDatabaseError      = errors.New("database error")
+	ErrStorageUnavailable = errors.New("storage is unavailable")
 	ErrInternal           = errors.New("internal server error")
 )
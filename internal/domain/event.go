@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType names a kind of change to a user
+type EventType string
+
+const (
+	EventUserCreated        EventType = "UserCreated"
+	EventUserUpdated        EventType = "UserUpdated"
+	EventUserContactChanged EventType = "UserContactChanged"
+	EventUserBlocked        EventType = "UserBlocked"
+	EventUserUnblocked      EventType = "UserUnblocked"
+	EventUserDeleted        EventType = "UserDeleted"
+	EventUserRestored       EventType = "UserRestored"
+)
+
+// Event records one change to a user. Storage writes it to the outbox along
+// with the change: in the same transaction on PostgreSQL and SQLite, and on
+// ScyllaDB in a batch after the version-checked user write, from a copy
+// recorded before it, so an interrupted write is finished later rather than
+// lost. The dispatcher publishes it from the outbox at least once, so
+// consumers should skip IDs they have already seen.
+type Event struct {
+	// ID is a version 1 (time-based) UUID
+	ID     string    `json:"id"`
+	Type   EventType `json:"type"`
+	UserID string    `json:"user_id"`
+	// Before is the user as it was, nil for UserCreated
+	Before *User `json:"before,omitempty"`
+	// After is the user as it is now. Its Version orders the events of one
+	// user.
+	After      *User     `json:"after"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewUserEvent builds the event for a change from before to after, where a
+// nil before means the user was created. The type is taken from what
+// changed: a delete or restore wins over a block or unblock, which wins over
+// a new email or phone; anything else is UserUpdated. Both snapshots are
+// copied.
+func NewUserEvent(before, after *User) *Event {
+	event := &Event{
+		ID:         uuid.Must(uuid.NewUUID()).String(),
+		Type:       classifyChange(before, after),
+		UserID:     after.ID,
+		After:      snapshot(after),
+		OccurredAt: time.Now().UTC(),
+	}
+	if before != nil {
+		event.Before = snapshot(before)
+	}
+	return event
+}
+
+func classifyChange(before, after *User) EventType {
+	switch {
+	case before == nil:
+		return EventUserCreated
+	case !before.IsDeleted() && after.IsDeleted():
+		return EventUserDeleted
+	case before.IsDeleted() && !after.IsDeleted():
+		return EventUserRestored
+	case !before.IsBlocked && after.IsBlocked:
+		return EventUserBlocked
+	case before.IsBlocked && !after.IsBlocked:
+		return EventUserUnblocked
+	case before.Email != after.Email || before.EmailKey() != after.EmailKey() ||
+		before.PhoneNumber != after.PhoneNumber:
+		return EventUserContactChanged
+	default:
+		return EventUserUpdated
+	}
+}
+
+func snapshot(user *User) *User {
+	u := *user
+	return &u
+}
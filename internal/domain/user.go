@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,16 +9,24 @@ import (
 
 // User represents a user in the domain
 type User struct {
-	ID          string
-	FirstName   string
-	LastName    string
-	Gender      string
-	DateOfBirth time.Time
-	PhoneNumber string
-	Email       string
-	IsBlocked   bool
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID          string    `json:"id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Gender      string    `json:"gender"`
+	DateOfBirth time.Time `json:"date_of_birth"`
+	PhoneNumber string    `json:"phone_number"`
+	Email       string    `json:"email"`
+	// CanonicalEmail is the normalized form of Email that uniqueness and
+	// lookups by email use. Email keeps the address as the user entered it.
+	CanonicalEmail string    `json:"email_canonical,omitempty"`
+	IsBlocked      bool      `json:"is_blocked"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// Version is incremented by storage on every successful update and
+	// guards against lost updates between concurrent writers.
+	Version int64 `json:"version"`
+	// DeletedAt is set when the user is soft-deleted and zero otherwise
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
 }
 
 // NewUser creates a new user with generated ID and timestamps
@@ -34,9 +43,30 @@ func NewUser(firstName, lastName, gender string, dob time.Time, phone, email str
 		IsBlocked:   false,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		Version:     1,
 	}
 }
 
+// EmailKey returns the email that uniqueness and lookups use. Users stored
+// before canonical emails were backfilled fall back to Email.
+func (u *User) EmailKey() string {
+	if u.CanonicalEmail != "" {
+		return u.CanonicalEmail
+	}
+	return u.Email
+}
+
+// ETag identifies the current version of the user. Clients echo it back to
+// make an update conditional on nobody else having changed the user.
+func (u *User) ETag() string {
+	return strconv.FormatInt(u.Version, 10)
+}
+
+// ParseETag returns the version an ETag stands for
+func ParseETag(etag string) (int64, error) {
+	return strconv.ParseInt(etag, 10, 64)
+}
+
 // Update updates user fields
 func (u *User) Update(firstName, lastName, gender string, dob time.Time) {
 	u.FirstName = firstName
@@ -46,13 +76,15 @@ func (u *User) Update(firstName, lastName, gender string, dob time.Time) {
 	u.UpdatedAt = time.Now()
 }
 
-// UpdateContact updates contact information
+// UpdateContact updates contact information. A new email clears
+// CanonicalEmail; the caller sets the canonical form of the new one.
 func (u *User) UpdateContact(phone, email *string) {
 	if phone != nil {
 		u.PhoneNumber = *phone
 	}
 	if email != nil {
 		u.Email = *email
+		u.CanonicalEmail = ""
 	}
 	u.UpdatedAt = time.Now()
 }
@@ -69,6 +101,39 @@ func (u *User) Unblock() {
 	u.UpdatedAt = time.Now()
 }
 
+// Delete soft-deletes the user
+func (u *User) Delete() {
+	now := time.Now()
+	u.DeletedAt = now
+	u.UpdatedAt = now
+}
+
+// Restore undoes a soft delete
+func (u *User) Restore() {
+	u.DeletedAt = time.Time{}
+	u.UpdatedAt = time.Now()
+}
+
+// Revert sets the user's fields back to those of an older snapshot of the
+// same user. The canonical email is cleared; the caller sets the canonical
+// form under the current rules.
+func (u *User) Revert(snapshot *User) {
+	u.FirstName = snapshot.FirstName
+	u.LastName = snapshot.LastName
+	u.Gender = snapshot.Gender
+	u.DateOfBirth = snapshot.DateOfBirth
+	u.PhoneNumber = snapshot.PhoneNumber
+	u.Email = snapshot.Email
+	u.CanonicalEmail = ""
+	u.IsBlocked = snapshot.IsBlocked
+	u.UpdatedAt = time.Now()
+}
+
+// IsDeleted reports whether the user has been soft-deleted
+func (u *User) IsDeleted() bool {
+	return !u.DeletedAt.IsZero()
+}
+
 // Validate validates the user data
 func (u *User) Validate() error {
 	if u.FirstName == "" {
@@ -100,4 +165,4 @@ func isValidGender(gender string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
@@ -0,0 +1,87 @@
+// internal/emailnorm/emailnorm.go
+package emailnorm
+
+import "strings"
+
+// Rules controls how an email is reduced to its canonical form
+type Rules struct {
+	// LowercaseLocal lowercases the part before the @. The domain is always
+	// lowercased.
+	LowercaseLocal bool
+	// IgnoreDotsDomains lists domains whose mailboxes ignore dots in the
+	// local part, so j.doe@gmail.com and jdoe@gmail.com are the same
+	IgnoreDotsDomains []string
+	// StripPlusDomains lists domains that deliver user+tag to user
+	StripPlusDomains []string
+	// DomainAliases maps a domain to the one it is an alias of, e.g.
+	// googlemail.com to gmail.com. The other rules see the aliased domain.
+	DomainAliases map[string]string
+}
+
+// Normalizer computes canonical emails. Two emails that reach the same
+// mailbox under the configured rules have the same canonical form, which is
+// what uniqueness and lookups by email compare.
+type Normalizer struct {
+	lowercaseLocal bool
+	ignoreDots     map[string]bool
+	stripPlus      map[string]bool
+	aliases        map[string]string
+}
+
+// New creates a Normalizer for rules
+func New(rules Rules) *Normalizer {
+	n := &Normalizer{
+		lowercaseLocal: rules.LowercaseLocal,
+		ignoreDots:     domainSet(rules.IgnoreDotsDomains),
+		stripPlus:      domainSet(rules.StripPlusDomains),
+		aliases:        make(map[string]string, len(rules.DomainAliases)),
+	}
+	for from, to := range rules.DomainAliases {
+		n.aliases[normalizeDomain(from)] = normalizeDomain(to)
+	}
+	return n
+}
+
+// Canonical returns the canonical form of email. Values without an @ are
+// only trimmed; validation rejects them anyway.
+func (n *Normalizer) Canonical(email string) string {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], normalizeDomain(email[at+1:])
+	if alias, ok := n.aliases[domain]; ok {
+		domain = alias
+	}
+
+	if n.stripPlus[domain] {
+		if plus := strings.IndexByte(local, '+'); plus > 0 {
+			local = local[:plus]
+		}
+	}
+	if n.ignoreDots[domain] {
+		if stripped := strings.ReplaceAll(local, ".", ""); stripped != "" {
+			local = stripped
+		}
+	}
+	if n.lowercaseLocal {
+		local = strings.ToLower(local)
+	}
+	return local + "@" + domain
+}
+
+func domainSet(domains []string) map[string]bool {
+	set := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		if d = normalizeDomain(d); d != "" {
+			set[d] = true
+		}
+	}
+	return set
+}
+
+// normalizeDomain lowercases a domain and drops a trailing root dot
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
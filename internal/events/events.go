@@ -0,0 +1,133 @@
+// internal/events/events.go
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+)
+
+// Publisher delivers user change events to whoever consumes them. The
+// dispatcher calls Publish once per event, in order, and only removes an
+// event from the outbox after Publish returned nil. An event can be
+// published more than once, so consumers should skip IDs they have seen.
+type Publisher interface {
+	Publish(ctx context.Context, event *domain.Event) error
+	Close() error
+}
+
+// LogPublisher writes each event to the service log
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+// Publish logs the event with its snapshots
+func (p *LogPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	slog.Info("User event",
+		"event_id", event.ID,
+		"type", event.Type,
+		"user_id", event.UserID,
+		"version", event.After.Version,
+		"occurred_at", event.OccurredAt,
+	)
+	return nil
+}
+
+func (p *LogPublisher) Close() error {
+	return nil
+}
+
+// FilePublisher appends each event to a file as one line of JSON
+type FilePublisher struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFilePublisher opens the file at path for appending, creating it and its
+// directory if they do not exist yet
+func NewFilePublisher(path string) (*FilePublisher, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create events directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open events file: %w", err)
+	}
+	return &FilePublisher{file: file}, nil
+}
+
+// Publish appends the event and syncs the file, so an event is on disk
+// before the dispatcher acknowledges it
+func (p *FilePublisher) Publish(ctx context.Context, event *domain.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	if err := p.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync events file: %w", err)
+	}
+	return nil
+}
+
+func (p *FilePublisher) Close() error {
+	return p.file.Close()
+}
+
+// MemoryPublisher keeps published events in memory. It is meant for tests.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []*domain.Event
+	err    error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Publish records the event, or returns the error set by Fail
+func (p *MemoryPublisher) Publish(ctx context.Context, event *domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Fail makes every later Publish return err; nil makes it succeed again
+func (p *MemoryPublisher) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+// Events returns the events published so far, in order
+func (p *MemoryPublisher) Events() []*domain.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := make([]*domain.Event, len(p.events))
+	copy(events, p.events)
+	return events
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
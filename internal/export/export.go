@@ -0,0 +1,133 @@
+// internal/export/export.go
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+)
+
+// Format is a file format users are exported in, and imported from
+type Format string
+
+const (
+	// FormatJSONL writes one JSON object per line, with the field names of
+	// domain.User
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes a header row followed by one row per user
+	FormatCSV Format = "csv"
+)
+
+// Columns are the CSV columns, in order
+var Columns = []string{
+	"id", "first_name", "last_name", "gender", "date_of_birth", "phone_number", "email",
+	"is_blocked", "created_at", "updated_at", "version", "deleted_at",
+}
+
+// ParseFormat parses a format name. The empty string means JSONL.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatJSONL, nil
+	case FormatJSONL, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected jsonl or csv", s)
+	}
+}
+
+// ContentType is the MIME type of the format
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Extension is the file name extension of the format, without the dot
+func (f Format) Extension() string {
+	return string(f)
+}
+
+// Encoder writes users to a stream
+type Encoder interface {
+	Encode(user *domain.User) error
+	// Flush writes any buffered data to the underlying writer
+	Flush() error
+}
+
+// NewEncoder returns an encoder that writes users to w in format f
+func NewEncoder(w io.Writer, f Format) Encoder {
+	if f == FormatCSV {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	}
+	return &jsonlEncoder{enc: json.NewEncoder(w)}
+}
+
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(user *domain.User) error {
+	return e.enc.Encode(user)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return nil
+}
+
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) Encode(user *domain.User) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		user.ID,
+		user.FirstName,
+		user.LastName,
+		user.Gender,
+		formatTime(user.DateOfBirth),
+		user.PhoneNumber,
+		user.Email,
+		strconv.FormatBool(user.IsBlocked),
+		formatTime(user.CreatedAt),
+		formatTime(user.UpdatedAt),
+		strconv.FormatInt(user.Version, 10),
+		formatTime(user.DeletedAt),
+	})
+}
+
+// Flush writes the header too, so an export without users still has one
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.w.Write(Columns)
+}
+
+// formatTime renders a zero time as an empty cell
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
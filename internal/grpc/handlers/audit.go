@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metadata recorded in the audit log. The REST gateway forwards the
+// X-Actor-Id and X-Request-Id headers under these keys.
+const (
+	actorMetadataKey     = "x-actor-id"
+	requestIDMetadataKey = "x-request-id"
+)
+
+// anonymousActor is recorded when a request doesn't say who made it
+const anonymousActor = "anonymous"
+
+// listAuditScope binds ListUserAuditEvents page tokens to one user
+const listAuditScope = "ListUserAuditEvents:"
+
+// withAudit attaches the caller, reason and request ID of a mutating RPC to
+// ctx, for storage to write into the audit record. A request without a
+// request ID gets a new one.
+func withAudit(ctx context.Context, method, reason string) context.Context {
+	info := audit.Info{Method: method, Reason: reason}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		info.Actor = firstMetadata(md, actorMetadataKey)
+		info.RequestID = firstMetadata(md, requestIDMetadataKey)
+	}
+	if info.Actor == "" {
+		info.Actor = anonymousActor
+	}
+	if info.RequestID == "" {
+		info.RequestID = uuid.New().String()
+	}
+	return audit.WithInfo(ctx, info)
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
+
+// ListUserAuditEvents lists the changes made to a user, newest first. It
+// works for deleted and purged users too.
+func (s *UserServiceServer) ListUserAuditEvents(ctx context.Context, req *pb.ListUserAuditEventsRequest) (*pb.ListUserAuditEventsResponse, error) {
+	slog.Info("Listing user audit events", "user_id", req.UserId, "page_size", req.PageSize)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	opts := storage.AuditListOptions{Limit: pageSize}
+	if req.StartTime != nil {
+		opts.Since = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		opts.Until = req.EndTime.AsTime()
+	}
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && !opts.Since.Before(opts.Until) {
+		return nil, status.Error(codes.InvalidArgument, "start_time must be before end_time")
+	}
+
+	scope := listAuditScope + req.UserId
+	if req.PageToken != "" {
+		cursor, err := s.pageTokens.Decode(scope, req.PageToken)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts.PageToken = cursor
+	}
+
+	records, nextCursor, err := s.storage.ListAuditRecords(ctx, req.UserId, opts)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		slog.Error("Failed to list audit events", "error", err)
+		return nil, status.Error(codes.Internal, "failed to list audit events")
+	}
+
+	events := make([]*pb.AuditEvent, len(records))
+	for i, record := range records {
+		events[i] = auditRecordToProto(record)
+	}
+	return &pb.ListUserAuditEventsResponse{
+		Events:        events,
+		NextPageToken: s.pageTokens.Encode(scope, nextCursor),
+	}, nil
+}
+
+func auditRecordToProto(record *domain.AuditRecord) *pb.AuditEvent {
+	changes := make([]*pb.AuditFieldChange, len(record.Changes))
+	for i, change := range record.Changes {
+		changes[i] = &pb.AuditFieldChange{
+			Field:    change.Field,
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		}
+	}
+	return &pb.AuditEvent{
+		Id:         record.ID,
+		UserId:     record.UserID,
+		Action:     string(record.Action),
+		Method:     record.Method,
+		Actor:      record.Actor,
+		Reason:     record.Reason,
+		RequestId:  record.RequestID,
+		OccurredAt: timestamppb.New(record.OccurredAt),
+		Changes:    changes,
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exportChunkSize is the most data sent in one ExportUsersChunk
+const exportChunkSize = 64 * 1024
+
+// ExportUsers streams every user that matches the request as JSONL or CSV
+func (s *UserServiceServer) ExportUsers(req *pb.ExportUsersRequest, stream pb.UserService_ExportUsersServer) error {
+	ctx := stream.Context()
+	slog.Info("Exporting users", "format", req.Format)
+
+	format, err := export.ParseFormat(req.Format)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	opts := storage.ExportOptions{Blocked: req.IsBlocked, ShowDeleted: req.ShowDeleted}
+	if req.CreatedAfter != nil {
+		opts.CreatedFrom = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		opts.CreatedTo = req.CreatedBefore.AsTime()
+	}
+	if !opts.CreatedFrom.IsZero() && !opts.CreatedTo.IsZero() && !opts.CreatedFrom.Before(opts.CreatedTo) {
+		return status.Error(codes.InvalidArgument, "created_after must be before created_before")
+	}
+
+	w := bufio.NewWriterSize(chunkWriter{stream}, exportChunkSize)
+	enc := export.NewEncoder(w, format)
+	count := 0
+	err = s.storage.ExportUsers(ctx, opts, func(user *domain.User) error {
+		count++
+		return enc.Encode(user)
+	})
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return status.FromContextError(err).Err()
+		}
+		slog.Error("Failed to export users", "exported", count, "error", err)
+		return status.Error(codes.Internal, "failed to export users")
+	}
+
+	slog.Info("Users exported successfully", "count", count)
+	return nil
+}
+
+// chunkWriter sends everything written to it as ExportUsersChunk messages
+type chunkWriter struct {
+	stream pb.UserService_ExportUsersServer
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&pb.ExportUsersChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
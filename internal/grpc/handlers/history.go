@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// userHistoryScope binds GetUserHistory page tokens to one user
+const userHistoryScope = "GetUserHistory:"
+
+// GetUserHistory lists the versions of a user, newest first. It works for
+// deleted users too, until they are purged.
+func (s *UserServiceServer) GetUserHistory(ctx context.Context, req *pb.GetUserHistoryRequest) (*pb.GetUserHistoryResponse, error) {
+	slog.Info("Getting user history", "user_id", req.UserId, "page_size", req.PageSize)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	scope := userHistoryScope + req.UserId
+	opts := storage.VersionListOptions{Limit: pageSize}
+	if req.PageToken != "" {
+		cursor, err := s.pageTokens.Decode(scope, req.PageToken)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		opts.PageToken = cursor
+	}
+
+	users, nextCursor, err := s.storage.ListUserVersions(ctx, req.UserId, opts)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		slog.Error("Failed to get user history", "error", err)
+		return nil, status.Error(codes.Internal, "failed to get user history")
+	}
+	if len(users) == 0 && req.PageToken == "" {
+		return nil, status.Error(codes.NotFound, "no versions of the user found")
+	}
+
+	versions := make([]*pb.UserVersion, len(users))
+	for i, user := range users {
+		versions[i] = userVersionToProto(user)
+	}
+	return &pb.GetUserHistoryResponse{
+		Versions:      versions,
+		NextPageToken: s.pageTokens.Encode(scope, nextCursor),
+	}, nil
+}
+
+// GetUserAtTime returns the version of a user that was current at the given
+// time
+func (s *UserServiceServer) GetUserAtTime(ctx context.Context, req *pb.GetUserAtTimeRequest) (*pb.GetUserAtTimeResponse, error) {
+	slog.Info("Getting user at time", "user_id", req.UserId)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if req.Time == nil {
+		return nil, status.Error(codes.InvalidArgument, "time is required")
+	}
+
+	user, err := s.storage.GetUserAtTime(ctx, req.UserId, req.Time.AsTime())
+	if err != nil {
+		if errors.Is(err, domain.ErrVersionNotFound) {
+			return nil, status.Error(codes.NotFound, "user did not exist at that time")
+		}
+		slog.Error("Failed to get user at time", "error", err)
+		return nil, status.Error(codes.Internal, "failed to get user version")
+	}
+
+	return &pb.GetUserAtTimeResponse{
+		Version: userVersionToProto(user),
+	}, nil
+}
+
+// DiffUserVersions lists the fields that differ between two versions of a
+// user
+func (s *UserServiceServer) DiffUserVersions(ctx context.Context, req *pb.DiffUserVersionsRequest) (*pb.DiffUserVersionsResponse, error) {
+	slog.Info("Diffing user versions", "user_id", req.UserId, "from", req.FromVersion, "to", req.ToVersion)
+
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if req.FromVersion <= 0 || req.ToVersion <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "from_version and to_version are required")
+	}
+
+	from, err := s.getUserVersion(ctx, req.UserId, req.FromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getUserVersion(ctx, req.UserId, req.ToVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := domain.DiffUsers(from, to)
+	changes := make([]*pb.AuditFieldChange, len(diff))
+	for i, change := range diff {
+		changes[i] = &pb.AuditFieldChange{
+			Field:    change.Field,
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		}
+	}
+	return &pb.DiffUserVersionsResponse{
+		Changes: changes,
+	}, nil
+}
+
+// RevertUser sets a user back to an older version. The revert is a new
+// change: the user is validated again, the old email and phone must still be
+// free, and the version moves forward.
+func (s *UserServiceServer) RevertUser(ctx context.Context, req *pb.RevertUserRequest) (*pb.RevertUserResponse, error) {
+	slog.Info("Reverting user", "id", req.Id, "version", req.Version)
+	ctx = withAudit(ctx, "RevertUser", req.Reason)
+
+	if req.Version <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "version is required")
+	}
+
+	user, err := s.storage.GetUserByID(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+	if user.IsDeleted() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := checkETag(ctx, user, req.Etag); err != nil {
+		return nil, err
+	}
+
+	if req.Version >= user.Version {
+		return nil, status.Error(codes.InvalidArgument, "version must be older than the current version")
+	}
+	snapshot, err := s.getUserVersion(ctx, user.ID, req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Revert(snapshot)
+	user.CanonicalEmail = s.emails.Canonical(user.Email)
+
+	if err := user.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// Storage claims the old email and phone again if they differ from the
+	// current ones, so a revert can't take them from another user
+	if err := s.storage.UpdateUser(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrEmailAlreadyExists) || errors.Is(err, domain.ErrPhoneAlreadyExists):
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		case errors.Is(err, domain.ErrVersionConflict):
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
+		slog.Error("Failed to revert user", "error", err)
+		return nil, status.Error(codes.Internal, "failed to revert user")
+	}
+
+	slog.Info("User reverted successfully", "user_id", user.ID, "version", user.Version)
+
+	return &pb.RevertUserResponse{
+		User: domainUserToProto(user),
+	}, nil
+}
+
+// getUserVersion reads a snapshot and maps the error to a gRPC status
+func (s *UserServiceServer) getUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error) {
+	user, err := s.storage.GetUserVersion(ctx, userID, version)
+	if err != nil {
+		if errors.Is(err, domain.ErrVersionNotFound) {
+			return nil, status.Errorf(codes.NotFound, "version %d of the user not found", version)
+		}
+		slog.Error("Failed to get user version", "error", err)
+		return nil, status.Error(codes.Internal, "failed to get user version")
+	}
+	return user, nil
+}
+
+func userVersionToProto(user *domain.User) *pb.UserVersion {
+	return &pb.UserVersion{
+		Version: user.Version,
+		User:    domainUserToProto(user),
+	}
+}
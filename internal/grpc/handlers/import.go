@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/importer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImportUsers creates users from a JSONL or CSV file streamed by the client
+// and reports the outcome of every row
+func (s *UserServiceServer) ImportUsers(stream pb.UserService_ImportUsersServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no import request received")
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Importing users", "format", first.Format, "mode", first.Mode, "dry_run", first.DryRun)
+	ctx := withAudit(stream.Context(), "ImportUsers", first.Reason)
+
+	format, err := export.ParseFormat(first.Format)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	mode, err := importer.ParseMode(first.Mode)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	r := &chunkReader{stream: stream, data: first.Data}
+	report, err := importer.Import(ctx, s.storage, s.emails.Canonical, r, importer.Options{
+		Format:      format,
+		Mode:        mode,
+		DryRun:      first.DryRun,
+		Concurrency: s.importConcurrency,
+	})
+	if err != nil {
+		switch {
+		case r.err != nil && r.err != io.EOF:
+			// Already a status error from the stream
+			return r.err
+		case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+			return status.FromContextError(err).Err()
+		default:
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	slog.Info("Import finished", "dry_run", report.DryRun, "created", report.Created,
+		"updated", report.Updated, "unchanged", report.Unchanged, "skipped", report.Skipped, "failed", report.Failed)
+	return stream.SendAndClose(importReportToProto(report))
+}
+
+// chunkReader reads the file sent in the data of ImportUsersRequest messages
+type chunkReader struct {
+	stream pb.UserService_ImportUsersServer
+	data   []byte
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		req, err := r.stream.Recv()
+		if err != nil {
+			r.err = err
+			continue
+		}
+		r.data = req.Data
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func importReportToProto(report *importer.Report) *pb.ImportUsersResponse {
+	rows := make([]*pb.ImportRowResult, len(report.Rows))
+	for i, row := range report.Rows {
+		rows[i] = &pb.ImportRowResult{
+			Line:   int64(row.Line),
+			Status: string(row.Status),
+			UserId: row.UserID,
+			Error:  importRowError(row),
+		}
+	}
+	return &pb.ImportUsersResponse{
+		DryRun:    report.DryRun,
+		Created:   int32(report.Created),
+		Updated:   int32(report.Updated),
+		Unchanged: int32(report.Unchanged),
+		Skipped:   int32(report.Skipped),
+		Failed:    int32(report.Failed),
+		Rows:      rows,
+	}
+}
+
+// importRowError describes why a row failed without exposing storage errors
+// to the client
+func importRowError(row importer.RowResult) string {
+	switch {
+	case row.Err == nil:
+		return ""
+	case errors.Is(row.Err, importer.ErrStorage):
+		slog.Error("Failed to import user", "line", row.Line, "error", row.Err)
+		return "failed to import user"
+	default:
+		return row.Err.Error()
+	}
+}
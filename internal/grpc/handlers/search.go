@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SearchUsers finds users by name prefix or by part of their email or phone
+func (s *UserServiceServer) SearchUsers(ctx context.Context, req *pb.SearchUsersRequest) (*pb.SearchUsersResponse, error) {
+	slog.Info("Searching users", "query", req.Query, "page_size", req.PageSize)
+
+	query, err := search.ParseQuery(req.Query)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// Page tokens only continue the search they came from
+	scope := fmt.Sprintf("SearchUsers:%t:%s", req.ShowDeleted, query)
+	cursor := ""
+	if req.PageToken != "" {
+		cursor, err = s.pageTokens.Decode(scope, req.PageToken)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	users, nextCursor, err := s.storage.SearchUsers(ctx, storage.SearchOptions{
+		Query:       query,
+		Limit:       pageSize,
+		PageToken:   cursor,
+		ShowDeleted: req.ShowDeleted,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPageToken) || errors.Is(err, domain.ErrSearchTooBroad) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		slog.Error("Failed to search users", "error", err)
+		return nil, status.Error(codes.Internal, "failed to search users")
+	}
+
+	protoUsers := make([]*pb.User, len(users))
+	for i, user := range users {
+		protoUsers[i] = domainUserToProto(user)
+	}
+	return &pb.SearchUsersResponse{
+		Users:         protoUsers,
+		NextPageToken: s.pageTokens.Encode(scope, nextCursor),
+	}, nil
+}
@@ -2,31 +2,50 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 
+	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
 	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/emailnorm"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/pagetoken"
 	"github.com/Divyansh031/user-service/internal/storage"
-	pb "github.com/Divyansh031/user-service/api/proto/user/v1"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// listUsersScope binds ListUsers page tokens so they can't be replayed
+// against other paginated RPCs.
+const listUsersScope = "ListUsers"
+
 type UserServiceServer struct {
 	pb.UnimplementedUserServiceServer
-	storage storage.Storage
+	storage    storage.Storage
+	pageTokens *pagetoken.Codec
+	emails     *emailnorm.Normalizer
+	// importConcurrency is the most rows of an import written at once
+	importConcurrency int
 }
 
-func NewUserServiceServer(storage storage.Storage) *UserServiceServer {
+func NewUserServiceServer(storage storage.Storage, pageTokens *pagetoken.Codec, emails *emailnorm.Normalizer, importConcurrency int) *UserServiceServer {
 	return &UserServiceServer{
-		storage: storage,
+		storage:           storage,
+		pageTokens:        pageTokens,
+		emails:            emails,
+		importConcurrency: importConcurrency,
 	}
 }
 
 // CreateUser creates a new user
 func (s *UserServiceServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
 	slog.Info("Creating user", "email", req.Email, "phone", req.PhoneNumber)
+	ctx = withAudit(ctx, "CreateUser", req.Reason)
 
 	user := domain.NewUser(
 		req.FirstName,
@@ -36,6 +55,7 @@ func (s *UserServiceServer) CreateUser(ctx context.Context, req *pb.CreateUserRe
 		req.PhoneNumber,
 		req.Email,
 	)
+	user.CanonicalEmail = s.emails.Canonical(user.Email)
 
 	if err := user.Validate(); err != nil {
 		slog.Error("Validation failed", "error", err)
@@ -43,7 +63,7 @@ func (s *UserServiceServer) CreateUser(ctx context.Context, req *pb.CreateUserRe
 	}
 
 	if err := s.storage.CreateUser(ctx, user); err != nil {
-		if err == domain.ErrEmailAlreadyExists || err == domain.ErrPhoneAlreadyExists {
+		if errors.Is(err, domain.ErrEmailAlreadyExists) || errors.Is(err, domain.ErrPhoneAlreadyExists) {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
 		slog.Error("Failed to create user", "error", err)
@@ -63,12 +83,15 @@ func (s *UserServiceServer) GetUser(ctx context.Context, req *pb.GetUserRequest)
 
 	user, err := s.storage.GetUserByID(ctx, req.Id)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		slog.Error("Failed to get user", "error", err)
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() && !req.ShowDeleted {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
 
 	return &pb.GetUserResponse{
 		User: domainUserToProto(user),
@@ -78,14 +101,22 @@ func (s *UserServiceServer) GetUser(ctx context.Context, req *pb.GetUserRequest)
 // UpdateUser updates an existing user
 func (s *UserServiceServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
 	slog.Info("Updating user", "id", req.Id)
+	ctx = withAudit(ctx, "UpdateUser", req.Reason)
 
 	user, err := s.storage.GetUserByID(ctx, req.Id)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := checkETag(ctx, user, req.Etag); err != nil {
+		return nil, err
+	}
 
 	// Only update fields that are provided (non-empty)
 	firstName := req.FirstName
@@ -115,6 +146,9 @@ func (s *UserServiceServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRe
 	}
 
 	if err := s.storage.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
 		slog.Error("Failed to update user", "error", err)
 		return nil, status.Error(codes.Internal, "failed to update user")
 	}
@@ -126,13 +160,29 @@ func (s *UserServiceServer) UpdateUser(ctx context.Context, req *pb.UpdateUserRe
 	}, nil
 }
 
-// DeleteUser deletes a user
+// DeleteUser soft-deletes a user. The user can be restored with RestoreUser
+// until the purger removes it.
 func (s *UserServiceServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
 	slog.Info("Deleting user", "id", req.Id)
+	ctx = withAudit(ctx, "DeleteUser", req.Reason)
+
+	// The storage checks the version in the same write that deletes the
+	// user, so an update in between can't be lost
+	var version *int64
+	if etag := requestETag(ctx, req.Etag); etag != "" {
+		v, err := domain.ParseETag(etag)
+		if err != nil {
+			return nil, status.Error(codes.Aborted, "etag does not match the current version of the user")
+		}
+		version = &v
+	}
 
-	if err := s.storage.DeleteUser(ctx, req.Id); err != nil {
-		if err == domain.ErrUserNotFound {
+	if err := s.storage.DeleteUser(ctx, req.Id, version); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
 			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, domain.ErrVersionConflict):
+			return nil, status.Error(codes.Aborted, "etag does not match the current version of the user")
 		}
 		slog.Error("Failed to delete user", "error", err)
 		return nil, status.Error(codes.Internal, "failed to delete user")
@@ -143,17 +193,61 @@ func (s *UserServiceServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRe
 	return &emptypb.Empty{}, nil
 }
 
+// RestoreUser restores a soft-deleted user
+func (s *UserServiceServer) RestoreUser(ctx context.Context, req *pb.RestoreUserRequest) (*pb.RestoreUserResponse, error) {
+	slog.Info("Restoring user", "id", req.Id)
+	ctx = withAudit(ctx, "RestoreUser", req.Reason)
+
+	// As in DeleteUser, the storage checks the version in the same write
+	var version *int64
+	if etag := requestETag(ctx, req.Etag); etag != "" {
+		v, err := domain.ParseETag(etag)
+		if err != nil {
+			return nil, status.Error(codes.Aborted, "etag does not match the current version of the user")
+		}
+		version = &v
+	}
+
+	user, err := s.storage.RestoreUser(ctx, req.Id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			return nil, status.Error(codes.NotFound, "user not found")
+		case errors.Is(err, domain.ErrUserNotDeleted):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case errors.Is(err, domain.ErrVersionConflict):
+			return nil, status.Error(codes.Aborted, "etag does not match the current version of the user")
+		}
+		slog.Error("Failed to restore user", "error", err)
+		return nil, status.Error(codes.Internal, "failed to restore user")
+	}
+
+	slog.Info("User restored successfully", "user_id", user.ID)
+
+	return &pb.RestoreUserResponse{
+		User: domainUserToProto(user),
+	}, nil
+}
+
 // BlockUser blocks a user
 func (s *UserServiceServer) BlockUser(ctx context.Context, req *pb.BlockUserRequest) (*pb.BlockUserResponse, error) {
 	slog.Info("Blocking user", "id", req.Id)
+	ctx = withAudit(ctx, "BlockUser", req.Reason)
 
 	user, err := s.storage.GetUserByID(ctx, req.Id)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := checkETag(ctx, user, req.Etag); err != nil {
+		return nil, err
+	}
 
 	if user.IsBlocked {
 		return nil, status.Error(codes.FailedPrecondition, "user is already blocked")
@@ -162,6 +256,9 @@ func (s *UserServiceServer) BlockUser(ctx context.Context, req *pb.BlockUserRequ
 	user.Block()
 
 	if err := s.storage.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
 		slog.Error("Failed to block user", "error", err)
 		return nil, status.Error(codes.Internal, "failed to block user")
 	}
@@ -176,14 +273,22 @@ func (s *UserServiceServer) BlockUser(ctx context.Context, req *pb.BlockUserRequ
 // UnblockUser unblocks a user
 func (s *UserServiceServer) UnblockUser(ctx context.Context, req *pb.UnblockUserRequest) (*pb.UnblockUserResponse, error) {
 	slog.Info("Unblocking user", "id", req.Id)
+	ctx = withAudit(ctx, "UnblockUser", req.Reason)
 
 	user, err := s.storage.GetUserByID(ctx, req.Id)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := checkETag(ctx, user, req.Etag); err != nil {
+		return nil, err
+	}
 
 	if !user.IsBlocked {
 		return nil, status.Error(codes.FailedPrecondition, "user is not blocked")
@@ -192,6 +297,9 @@ func (s *UserServiceServer) UnblockUser(ctx context.Context, req *pb.UnblockUser
 	user.Unblock()
 
 	if err := s.storage.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrVersionConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
 		slog.Error("Failed to unblock user", "error", err)
 		return nil, status.Error(codes.Internal, "failed to unblock user")
 	}
@@ -206,14 +314,22 @@ func (s *UserServiceServer) UnblockUser(ctx context.Context, req *pb.UnblockUser
 // UpdateUserContact updates user's phone number or email
 func (s *UserServiceServer) UpdateUserContact(ctx context.Context, req *pb.UpdateUserContactRequest) (*pb.UpdateUserContactResponse, error) {
 	slog.Info("Updating user contact", "id", req.Id)
+	ctx = withAudit(ctx, "UpdateUserContact", req.Reason)
 
 	user, err := s.storage.GetUserByID(ctx, req.Id)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+
+	if err := checkETag(ctx, user, req.Etag); err != nil {
+		return nil, err
+	}
 
 	var phone, email *string
 	if req.PhoneNumber != nil {
@@ -224,8 +340,17 @@ func (s *UserServiceServer) UpdateUserContact(ctx context.Context, req *pb.Updat
 	}
 
 	user.UpdateContact(phone, email)
+	if email != nil {
+		user.CanonicalEmail = s.emails.Canonical(user.Email)
+	}
 
 	if err := s.storage.UpdateUser(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrEmailAlreadyExists) || errors.Is(err, domain.ErrPhoneAlreadyExists) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		if errors.Is(err, domain.ErrVersionConflict) {
+			return nil, status.Error(codes.Aborted, err.Error())
+		}
 		slog.Error("Failed to update user contact", "error", err)
 		return nil, status.Error(codes.Internal, "failed to update user contact")
 	}
@@ -243,12 +368,15 @@ func (s *UserServiceServer) GetUserByPhone(ctx context.Context, req *pb.GetUserB
 
 	user, err := s.storage.GetUserByPhone(ctx, req.PhoneNumber)
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		slog.Error("Failed to get user by phone", "error", err)
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() && !req.ShowDeleted {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
 
 	return &pb.GetUserResponse{
 		User: domainUserToProto(user),
@@ -259,23 +387,35 @@ func (s *UserServiceServer) GetUserByPhone(ctx context.Context, req *pb.GetUserB
 func (s *UserServiceServer) GetUserByEmail(ctx context.Context, req *pb.GetUserByEmailRequest) (*pb.GetUserResponse, error) {
 	slog.Info("Getting user by email", "email", req.Email)
 
-	user, err := s.storage.GetUserByEmail(ctx, req.Email)
+	user, err := s.storage.GetUserByEmail(ctx, s.emails.Canonical(req.Email))
 	if err != nil {
-		if err == domain.ErrUserNotFound {
+		if errors.Is(err, domain.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
 		}
 		slog.Error("Failed to get user by email", "error", err)
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
+	if user.IsDeleted() && !req.ShowDeleted {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
 
 	return &pb.GetUserResponse{
 		User: domainUserToProto(user),
 	}, nil
 }
 
-// ListUsers lists all users with pagination
+// ListUsers lists users with pagination, filtered and ordered as requested
 func (s *UserServiceServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
-	slog.Info("Listing users", "page_size", req.PageSize)
+	slog.Info("Listing users", "page_size", req.PageSize, "filter", req.Filter, "order_by", req.OrderBy)
+
+	filter, err := listquery.ParseFilter(req.Filter)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid filter: "+err.Error())
+	}
+	order, err := listquery.ParseOrder(req.OrderBy)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid order_by: "+err.Error())
+	}
 
 	pageSize := int(req.PageSize)
 	if pageSize <= 0 {
@@ -285,12 +425,48 @@ func (s *UserServiceServer) ListUsers(ctx context.Context, req *pb.ListUsersRequ
 		pageSize = 100
 	}
 
-	users, nextToken, err := s.storage.ListUsers(ctx, pageSize, req.PageToken)
+	// Page tokens only continue a listing with the same filter, order and
+	// show_deleted
+	scope := listUsersScope
+	if req.Filter != "" || req.OrderBy != "" || req.ShowDeleted {
+		scope = fmt.Sprintf("%s:%t:%q:%q", listUsersScope, req.ShowDeleted, req.Filter, req.OrderBy)
+	}
+	cursor := ""
+	if req.PageToken != "" {
+		cursor, err = s.pageTokens.Decode(scope, req.PageToken)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	users, nextCursor, err := s.storage.ListUsers(ctx, storage.ListOptions{
+		Limit:       pageSize,
+		PageToken:   cursor,
+		ShowDeleted: req.ShowDeleted,
+		Filter:      filter,
+		Order:       order,
+	})
 	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPageToken) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 		slog.Error("Failed to list users", "error", err)
 		return nil, status.Error(codes.Internal, "failed to list users")
 	}
 
+	// CountUsers only counts users that are not deleted, by blocked state, so
+	// any other filter leaves the total unset rather than wrong
+	var totalCount *int32
+	if filter.BlockedOnly() && !req.ShowDeleted {
+		total, err := s.storage.CountUsers(ctx, filter.Blocked)
+		if err != nil {
+			slog.Error("Failed to count users", "error", err)
+			return nil, status.Error(codes.Internal, "failed to list users")
+		}
+		count := int32(total)
+		totalCount = &count
+	}
+
 	protoUsers := make([]*pb.User, len(users))
 	for i, user := range users {
 		protoUsers[i] = domainUserToProto(user)
@@ -298,14 +474,64 @@ func (s *UserServiceServer) ListUsers(ctx context.Context, req *pb.ListUsersRequ
 
 	return &pb.ListUsersResponse{
 		Users:         protoUsers,
-		NextPageToken: nextToken,
-		TotalCount:    int32(len(users)),
+		NextPageToken: s.pageTokens.Encode(scope, nextCursor),
+		TotalCount:    totalCount,
+	}, nil
+}
+
+// CountUsers returns the number of users, optionally filtered by blocked state
+func (s *UserServiceServer) CountUsers(ctx context.Context, req *pb.CountUsersRequest) (*pb.CountUsersResponse, error) {
+	slog.Info("Counting users")
+
+	count, err := s.storage.CountUsers(ctx, req.IsBlocked)
+	if err != nil {
+		slog.Error("Failed to count users", "error", err)
+		return nil, status.Error(codes.Internal, "failed to count users")
+	}
+
+	return &pb.CountUsersResponse{
+		Count: count,
 	}, nil
 }
 
+// requestETag returns the etag a mutating request is conditional on: the etag
+// field if set, otherwise an If-Match header (forwarded by the REST gateway
+// as grpcgateway-if-match). An empty result means the request is
+// unconditional.
+func requestETag(ctx context.Context, etag string) string {
+	if etag != "" {
+		return etag
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, key := range []string{"if-match", "grpcgateway-if-match"} {
+		if values := md.Get(key); len(values) > 0 {
+			value := strings.TrimSpace(values[0])
+			if value == "*" {
+				return ""
+			}
+			return strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+		}
+	}
+	return ""
+}
+
+// checkETag rejects the request with ABORTED if the client's etag no longer
+// matches the stored user
+func checkETag(ctx context.Context, user *domain.User, etag string) error {
+	etag = requestETag(ctx, etag)
+	if etag != "" && etag != user.ETag() {
+		return status.Error(codes.Aborted, "etag does not match the current version of the user")
+	}
+	return nil
+}
+
 // Helper function to convert domain user to proto
 func domainUserToProto(user *domain.User) *pb.User {
-	return &pb.User{
+	protoUser := &pb.User{
 		Id:          user.ID,
 		FirstName:   user.FirstName,
 		LastName:    user.LastName,
@@ -316,5 +542,10 @@ func domainUserToProto(user *domain.User) *pb.User {
 		IsBlocked:   user.IsBlocked,
 		CreatedAt:   timestamppb.New(user.CreatedAt),
 		UpdatedAt:   timestamppb.New(user.UpdatedAt),
+		Etag:        user.ETag(),
 	}
-}
\ No newline at end of file
+	if user.IsDeleted() {
+		protoUser.DeletedAt = timestamppb.New(user.DeletedAt)
+	}
+	return protoUser
+}
@@ -0,0 +1,387 @@
+// internal/importer/importer.go
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/pkg/validator"
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultConcurrency is the number of rows written at once when Options
+// doesn't say
+const DefaultConcurrency = 8
+
+// ErrStorage marks a row that failed on an unexpected storage error rather
+// than on something wrong with the row
+var ErrStorage = errors.New("storage error")
+
+// errDeletedUser is reported for an upsert that matches a soft-deleted user.
+// The user has to be restored first.
+var errDeletedUser = errors.New("matches a deleted user")
+
+// Mode decides what happens to a row whose email or phone is already taken
+type Mode string
+
+const (
+	// ModeCreate fails the row
+	ModeCreate Mode = "create"
+	// ModeSkipDuplicates leaves the existing user alone and reports the row
+	// as skipped
+	ModeSkipDuplicates Mode = "skip-duplicates"
+	// ModeUpsert updates the user that has the email or phone
+	ModeUpsert Mode = "upsert"
+)
+
+// ParseMode parses a mode name. The empty string means ModeCreate.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(s)); m {
+	case "":
+		return ModeCreate, nil
+	case ModeCreate, ModeSkipDuplicates, ModeUpsert:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown import mode %q, expected create, skip-duplicates or upsert", s)
+	}
+}
+
+// Options controls an import
+type Options struct {
+	Format export.Format
+	Mode   Mode
+	// DryRun validates every row and checks it against storage without
+	// writing anything
+	DryRun bool
+	// Concurrency is the most rows written at once
+	Concurrency int
+}
+
+// Status is the outcome of one row
+type Status string
+
+const (
+	StatusCreated   Status = "created"
+	StatusUpdated   Status = "updated"
+	StatusUnchanged Status = "unchanged"
+	StatusSkipped   Status = "skipped"
+	StatusFailed    Status = "failed"
+)
+
+// RowResult is the outcome of one row of the file. In a dry run it is the
+// outcome the row would have had.
+type RowResult struct {
+	// Line is where the row starts in the file, counting from 1
+	Line   int
+	Status Status
+	// UserID is the user the row created or matched. A dry run creates
+	// nothing, so created rows have none.
+	UserID string
+	// Err says why the row failed or was skipped
+	Err error
+}
+
+// Report lists the outcome of every row, in file order
+type Report struct {
+	DryRun    bool
+	Rows      []RowResult
+	Created   int
+	Updated   int
+	Unchanged int
+	Skipped   int
+	Failed    int
+}
+
+// item is a valid row on its way to storage
+type item struct {
+	line    int
+	user    *domain.User
+	blocked *bool
+}
+
+type importer struct {
+	db   storage.Storage
+	opts Options
+}
+
+// Import reads users from r and creates them in db, up to opts.Concurrency at
+// a time. Each row is validated like a CreateUser request and its email is
+// reduced to canonical form first. Rows fail, or are skipped, on their own;
+// the error is only for a file that can't be read any further or a cancelled
+// ctx, and comes with the report of the rows handled until then.
+//
+// Within the file, the first row with an email or phone claims it and later
+// rows with the same one are treated as duplicates, whatever the mode.
+func Import(ctx context.Context, db storage.Storage, canonical func(string) string, r io.Reader, opts Options) (*Report, error) {
+	rows, err := newRowReader(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	imp := &importer{db: db, opts: opts}
+
+	var (
+		mu      sync.Mutex
+		results []RowResult
+	)
+	add := func(result RowResult) {
+		mu.Lock()
+		results = append(results, result)
+		mu.Unlock()
+	}
+
+	emails := make(map[string]int)
+	phones := make(map[string]int)
+	var g errgroup.Group
+	g.SetLimit(opts.Concurrency)
+	for ctx.Err() == nil {
+		rw, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			g.Wait()
+			return newReport(opts, results), fmt.Errorf("failed to read import file: %w", err)
+		}
+
+		it, err := prepare(rw, canonical)
+		if err != nil {
+			add(RowResult{Line: rw.line, Status: StatusFailed, Err: err})
+			continue
+		}
+		if err := claim(emails, phones, it); err != nil {
+			add(imp.duplicate(it, err))
+			continue
+		}
+		g.Go(func() error {
+			add(imp.importRow(ctx, it))
+			return nil
+		})
+	}
+	g.Wait()
+	return newReport(opts, results), ctx.Err()
+}
+
+// prepare turns a row into a valid user
+func prepare(rw row, canonical func(string) string) (item, error) {
+	if rw.err != nil {
+		return item{}, rw.err
+	}
+	dob, err := parseDate(rw.rec.DateOfBirth)
+	if err != nil {
+		return item{}, domain.ErrInvalidDateOfBirth
+	}
+	user := domain.NewUser(
+		rw.rec.FirstName,
+		rw.rec.LastName,
+		rw.rec.Gender,
+		dob,
+		rw.rec.PhoneNumber,
+		rw.rec.Email,
+	)
+	user.CanonicalEmail = canonical(user.Email)
+	if rw.rec.IsBlocked != nil {
+		user.IsBlocked = *rw.rec.IsBlocked
+	}
+	if err := validate(user); err != nil {
+		return item{}, err
+	}
+	return item{line: rw.line, user: user, blocked: rw.rec.IsBlocked}, nil
+}
+
+// parseDate accepts a date on its own or an RFC 3339 timestamp, as written
+// by an export
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// validate applies the domain rules and the stricter format checks of
+// pkg/validator
+func validate(user *domain.User) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	switch {
+	case !validator.ValidateFirstName(user.FirstName):
+		return domain.ErrInvalidFirstName
+	case !validator.ValidateLastName(user.LastName):
+		return domain.ErrInvalidLastName
+	case !validator.ValidateGender(user.Gender):
+		return domain.ErrInvalidGender
+	case !validator.ValidatePhoneNumber(user.PhoneNumber):
+		return domain.ErrInvalidPhoneNumber
+	case !validator.ValidateEmail(user.Email):
+		return domain.ErrInvalidEmail
+	}
+	return nil
+}
+
+// claim records the row's email and phone as taken within the file
+func claim(emails, phones map[string]int, it item) error {
+	if line, ok := emails[it.user.EmailKey()]; ok {
+		return fmt.Errorf("%w on line %d", domain.ErrEmailAlreadyExists, line)
+	}
+	if line, ok := phones[it.user.PhoneNumber]; ok {
+		return fmt.Errorf("%w on line %d", domain.ErrPhoneAlreadyExists, line)
+	}
+	emails[it.user.EmailKey()] = it.line
+	phones[it.user.PhoneNumber] = it.line
+	return nil
+}
+
+// importRow writes one row according to the mode
+func (imp *importer) importRow(ctx context.Context, it item) RowResult {
+	if imp.opts.Mode == ModeUpsert {
+		existing, err := imp.findExisting(ctx, it.user)
+		if err != nil {
+			return failed(it, "", storageError(err))
+		}
+		if existing != nil {
+			return imp.update(ctx, it, existing)
+		}
+	}
+
+	var err error
+	if imp.opts.DryRun {
+		err = imp.checkUnique(ctx, it.user)
+	} else {
+		err = imp.db.CreateUser(ctx, it.user)
+	}
+	switch {
+	case err == nil && imp.opts.DryRun:
+		return RowResult{Line: it.line, Status: StatusCreated}
+	case err == nil:
+		return RowResult{Line: it.line, Status: StatusCreated, UserID: it.user.ID}
+	case errors.Is(err, domain.ErrEmailAlreadyExists) || errors.Is(err, domain.ErrPhoneAlreadyExists):
+		return imp.duplicate(it, err)
+	default:
+		return failed(it, "", storageError(err))
+	}
+}
+
+// findExisting returns the user that has the row's email or phone, or nil.
+// An email and phone that belong to two different users can't be upserted.
+func (imp *importer) findExisting(ctx context.Context, user *domain.User) (*domain.User, error) {
+	byEmail, err := imp.db.GetUserByEmail(ctx, user.EmailKey())
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+	byPhone, err := imp.db.GetUserByPhone(ctx, user.PhoneNumber)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+
+	existing := byEmail
+	switch {
+	case byEmail == nil:
+		existing = byPhone
+	case byPhone != nil && byPhone.ID != byEmail.ID:
+		return nil, domain.ErrPhoneAlreadyExists
+	}
+	if existing != nil && existing.IsDeleted() {
+		return nil, errDeletedUser
+	}
+	return existing, nil
+}
+
+// update applies the row to the user that has its email or phone. A row
+// that changes nothing leaves the user, and its version, as it is.
+func (imp *importer) update(ctx context.Context, it item, existing *domain.User) RowResult {
+	user := *existing
+	row := it.user
+	user.Update(row.FirstName, row.LastName, row.Gender, row.DateOfBirth)
+	user.UpdateContact(&row.PhoneNumber, &row.Email)
+	user.CanonicalEmail = row.CanonicalEmail
+	if it.blocked != nil {
+		user.IsBlocked = *it.blocked
+	}
+	if len(domain.DiffUsers(existing, &user)) == 0 {
+		return RowResult{Line: it.line, Status: StatusUnchanged, UserID: user.ID}
+	}
+
+	if !imp.opts.DryRun {
+		if err := imp.db.UpdateUser(ctx, &user); err != nil {
+			return failed(it, user.ID, storageError(err))
+		}
+	}
+	return RowResult{Line: it.line, Status: StatusUpdated, UserID: user.ID}
+}
+
+// checkUnique reports the error CreateUser would return for a taken email
+// or phone
+func (imp *importer) checkUnique(ctx context.Context, user *domain.User) error {
+	exists, err := imp.db.CheckEmailExists(ctx, user.EmailKey())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrEmailAlreadyExists
+	}
+	exists, err = imp.db.CheckPhoneExists(ctx, user.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return domain.ErrPhoneAlreadyExists
+	}
+	return nil
+}
+
+// duplicate is the result of a row whose email or phone is taken
+func (imp *importer) duplicate(it item, err error) RowResult {
+	if imp.opts.Mode == ModeSkipDuplicates {
+		return RowResult{Line: it.line, Status: StatusSkipped, Err: err}
+	}
+	return failed(it, "", err)
+}
+
+func failed(it item, userID string, err error) RowResult {
+	return RowResult{Line: it.line, Status: StatusFailed, UserID: userID, Err: err}
+}
+
+// storageError wraps err in ErrStorage unless it is one of the errors that
+// say what is wrong with the row
+func storageError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrEmailAlreadyExists), errors.Is(err, domain.ErrPhoneAlreadyExists),
+		errors.Is(err, domain.ErrVersionConflict), errors.Is(err, errDeletedUser):
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrStorage, err)
+}
+
+func newReport(opts Options, results []RowResult) *Report {
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Line < results[j].Line
+	})
+	report := &Report{DryRun: opts.DryRun, Rows: results}
+	for _, result := range results {
+		switch result.Status {
+		case StatusCreated:
+			report.Created++
+		case StatusUpdated:
+			report.Updated++
+		case StatusUnchanged:
+			report.Unchanged++
+		case StatusSkipped:
+			report.Skipped++
+		case StatusFailed:
+			report.Failed++
+		}
+	}
+	return report
+}
@@ -0,0 +1,145 @@
+// internal/importer/reader.go
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/export"
+)
+
+// requiredColumns must be in the header of a CSV import. is_blocked is
+// optional; any other column, such as the bookkeeping columns of an export,
+// is ignored.
+var requiredColumns = []string{"first_name", "last_name", "gender", "date_of_birth", "phone_number", "email"}
+
+// record is one user as read from an import file
+type record struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Gender      string `json:"gender"`
+	DateOfBirth string `json:"date_of_birth"`
+	PhoneNumber string `json:"phone_number"`
+	Email       string `json:"email"`
+	// IsBlocked is nil when the file doesn't say, which leaves the blocked
+	// state of an upserted user alone
+	IsBlocked *bool `json:"is_blocked"`
+}
+
+// row is a record and the line it starts on. err is set when the row could
+// not be parsed; reading carries on with the next one.
+type row struct {
+	line int
+	rec  record
+	err  error
+}
+
+// rowReader reads the rows of an import file. It returns io.EOF after the
+// last row and any other error when the rest of the file can't be read.
+type rowReader interface {
+	next() (row, error)
+}
+
+func newRowReader(r io.Reader, f export.Format) (rowReader, error) {
+	if f == export.FormatCSV {
+		return newCSVReader(r)
+	}
+	return &jsonlReader{r: bufio.NewReader(r)}, nil
+}
+
+// jsonlReader reads one JSON object per line and skips blank lines
+type jsonlReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (r *jsonlReader) next() (row, error) {
+	for {
+		b, err := r.r.ReadBytes('\n')
+		if len(b) == 0 {
+			return row{}, err
+		}
+		r.line++
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		rw := row{line: r.line}
+		if err := json.Unmarshal(b, &rw.rec); err != nil {
+			rw.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		return rw, nil
+	}
+}
+
+// csvReader reads a header row followed by one row per user. Columns are
+// matched by name, so they can come in any order.
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file has no header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	// Spreadsheets tend to start UTF-8 files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", name)
+		}
+	}
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (r *csvReader) next() (row, error) {
+	fields, err := r.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return row{line: perr.StartLine, err: perr.Err}, nil
+		}
+		return row{}, err
+	}
+
+	line, _ := r.r.FieldPos(0)
+	get := func(name string) string {
+		if i, ok := r.columns[name]; ok {
+			return fields[i]
+		}
+		return ""
+	}
+	rw := row{line: line, rec: record{
+		FirstName:   get("first_name"),
+		LastName:    get("last_name"),
+		Gender:      get("gender"),
+		DateOfBirth: get("date_of_birth"),
+		PhoneNumber: get("phone_number"),
+		Email:       get("email"),
+	}}
+	if s := get("is_blocked"); s != "" {
+		blocked, err := strconv.ParseBool(s)
+		if err != nil {
+			rw.err = fmt.Errorf("invalid is_blocked %q", s)
+		}
+		rw.rec.IsBlocked = &blocked
+	}
+	return rw, nil
+}
@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// backfillPageSize is the number of users read per ListUsers call
+const backfillPageSize = 500
+
+// backfillAttempts bounds the retries of a user updated concurrently
+const backfillAttempts = 3
+
+// EmailConflict is a user whose canonical email already belongs to another
+// user. Both were allowed before normalization; one of them has to change
+// their email before the conflict can be resolved.
+type EmailConflict struct {
+	UserID         string
+	Email          string
+	CanonicalEmail string
+	// OwnerID is the user that has the canonical email, if it could be read
+	OwnerID string
+}
+
+// EmailBackfillReport summarizes a backfill run
+type EmailBackfillReport struct {
+	Scanned int
+	// Updated counts the users given a new canonical email, or that would be
+	// in a dry run
+	Updated   int
+	Conflicts []EmailConflict
+}
+
+// BackfillCanonicalEmails sets the canonical email of every user, deleted
+// ones included, whose stored form differs from canonical(Email). It runs
+// after canonical emails are introduced and again whenever the rules change.
+// Each user goes through UpdateUser, so uniqueness and the lookup tables are
+// handled as for any other email change. With dryRun set nothing is written.
+func BackfillCanonicalEmails(ctx context.Context, db storage.Storage, canonical func(string) string, dryRun bool) (*EmailBackfillReport, error) {
+	ctx = audit.WithInfo(ctx, audit.Info{
+		Method: "BackfillCanonicalEmails",
+		Actor:  audit.SystemActor,
+		Reason: "canonical email backfill",
+	})
+	report := &EmailBackfillReport{}
+	opts := storage.ListOptions{Limit: backfillPageSize, ShowDeleted: true}
+	for {
+		users, next, err := db.ListUsers(ctx, opts)
+		if err != nil {
+			return report, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range users {
+			report.Scanned++
+			want := canonical(user.Email)
+			if user.CanonicalEmail == want {
+				continue
+			}
+
+			ownerID, err := backfillUser(ctx, db, user, canonical, dryRun)
+			switch {
+			case err == nil:
+				report.Updated++
+			case errors.Is(err, domain.ErrEmailAlreadyExists):
+				conflict := EmailConflict{UserID: user.ID, Email: user.Email, CanonicalEmail: want, OwnerID: ownerID}
+				slog.Warn("Canonical email belongs to another user",
+					"user_id", conflict.UserID, "email", conflict.Email,
+					"canonical_email", conflict.CanonicalEmail, "owner_id", conflict.OwnerID)
+				report.Conflicts = append(report.Conflicts, conflict)
+			default:
+				return report, fmt.Errorf("failed to backfill user %s: %w", user.ID, err)
+			}
+		}
+		if next == "" {
+			return report, nil
+		}
+		opts.PageToken = next
+	}
+}
+
+// backfillUser gives user the canonical email want. On a conflict it returns
+// domain.ErrEmailAlreadyExists and, if it can be read, the ID of the user
+// that has want.
+func backfillUser(ctx context.Context, db storage.Storage, user *domain.User, canonical func(string) string, dryRun bool) (string, error) {
+	want := canonical(user.Email)
+	if dryRun {
+		owner, err := db.GetUserByEmail(ctx, want)
+		switch {
+		case err == nil && owner.ID != user.ID:
+			return owner.ID, domain.ErrEmailAlreadyExists
+		case err != nil && !errors.Is(err, domain.ErrUserNotFound):
+			return "", err
+		}
+		return "", nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		user.CanonicalEmail = want
+		err := db.UpdateUser(ctx, user)
+		if errors.Is(err, domain.ErrEmailAlreadyExists) {
+			if owner, err := db.GetUserByEmail(ctx, want); err == nil {
+				return owner.ID, domain.ErrEmailAlreadyExists
+			}
+			return "", domain.ErrEmailAlreadyExists
+		}
+		if !errors.Is(err, domain.ErrVersionConflict) || attempt == backfillAttempts {
+			return "", err
+		}
+
+		// Changed meanwhile; an email change through the service already
+		// stored the canonical form
+		if user, err = db.GetUserByID(ctx, user.ID); err != nil {
+			if errors.Is(err, domain.ErrUserNotFound) {
+				return "", nil
+			}
+			return "", err
+		}
+		want = canonical(user.Email)
+		if user.CanonicalEmail == want {
+			return "", nil
+		}
+	}
+}
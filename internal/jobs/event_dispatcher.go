@@ -0,0 +1,112 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/events"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// DispatchStats counts event dispatcher activity since the job was created
+type DispatchStats struct {
+	Published uint64 `json:"published"`
+	Failed    uint64 `json:"failed"`
+}
+
+// EventDispatcher periodically publishes the events in the storage outbox.
+// An event is acknowledged only after it was published, so delivery is at
+// least once: a crash or a failed acknowledgement publishes it again.
+type EventDispatcher struct {
+	outbox    storage.Outbox
+	publisher events.Publisher
+	interval  time.Duration
+	batchSize int
+
+	published atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// NewEventDispatcher creates a new event dispatcher that reads up to
+// batchSize events from the outbox at a time
+func NewEventDispatcher(outbox storage.Outbox, publisher events.Publisher, interval time.Duration, batchSize int) *EventDispatcher {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &EventDispatcher{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run dispatches once immediately and then on every interval until ctx is
+// done
+func (d *EventDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to dispatch user events", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce publishes pending events in order until the outbox is empty.
+// It stops at the first event that fails to publish, so that event and the
+// ones after it are retried, in order, by the next run. It returns the number
+// of events published.
+func (d *EventDispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		pending, err := d.outbox.PendingEvents(ctx, d.batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		var acked []string
+		var publishErr error
+		for _, event := range pending {
+			if publishErr = d.publisher.Publish(ctx, event); publishErr != nil {
+				d.failed.Add(1)
+				publishErr = fmt.Errorf("failed to publish event %s: %w", event.ID, publishErr)
+				break
+			}
+			acked = append(acked, event.ID)
+		}
+		total += len(acked)
+		d.published.Add(uint64(len(acked)))
+
+		if err := d.outbox.AckEvents(ctx, acked); err != nil {
+			return total, err
+		}
+		if publishErr != nil {
+			return total, publishErr
+		}
+		if len(pending) < d.batchSize {
+			if total > 0 {
+				slog.Debug("Dispatched user events", "count", total)
+			}
+			return total, nil
+		}
+	}
+}
+
+// Stats returns a snapshot of the counters
+func (d *EventDispatcher) Stats() DispatchStats {
+	return DispatchStats{
+		Published: d.published.Load(),
+		Failed:    d.failed.Load(),
+	}
+}
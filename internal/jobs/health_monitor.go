@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger is a storage backend that can report whether it is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Reconnector is a storage backend that can replace its connection. Backends
+// whose driver reconnects on its own don't implement it.
+type Reconnector interface {
+	Reconnect() error
+}
+
+// HealthOptions controls the storage health monitor
+type HealthOptions struct {
+	// Interval is the time between pings; each ping is bounded by it too
+	Interval time.Duration
+	// Failures is the number of failed pings in a row after which the
+	// storage counts as lost
+	Failures int
+	// Backoff is the delay after the first failed reconnect. It doubles with
+	// every further failure up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// HealthStats describes the storage health since the monitor was created
+type HealthStats struct {
+	Serving    bool   `json:"serving"`
+	Failures   uint64 `json:"failures"`
+	Reconnects uint64 `json:"reconnects"`
+}
+
+// HealthMonitor pings the storage backend and reports through setServing
+// whether it is reachable. Once the backend has failed opts.Failures pings
+// in a row it is reported as not serving and, if it is a Reconnector,
+// reconnected with backoff until a ping succeeds again.
+type HealthMonitor struct {
+	target     Pinger
+	opts       HealthOptions
+	setServing func(serving bool)
+
+	serving    atomic.Bool
+	failures   atomic.Uint64
+	reconnects atomic.Uint64
+	// streak counts failed pings in a row; only Run touches it
+	streak int
+}
+
+// NewHealthMonitor creates a monitor for a backend that is reachable now
+func NewHealthMonitor(target Pinger, opts HealthOptions, setServing func(serving bool)) *HealthMonitor {
+	opts.Failures = max(opts.Failures, 1)
+	m := &HealthMonitor{
+		target:     target,
+		opts:       opts,
+		setServing: setServing,
+	}
+	m.serving.Store(true)
+	return m
+}
+
+// Run pings on every interval until ctx is done
+func (m *HealthMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if m.CheckOnce(ctx) || m.streak < m.opts.Failures {
+			continue
+		}
+		m.recover(ctx)
+	}
+}
+
+// CheckOnce pings the backend once, reporting it as not serving once
+// opts.Failures pings in a row have failed. It returns whether the ping
+// succeeded.
+func (m *HealthMonitor) CheckOnce(ctx context.Context) bool {
+	err := m.ping(ctx)
+	if err == nil {
+		m.streak = 0
+		m.markServing(true)
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+
+	m.streak++
+	m.failures.Add(1)
+	slog.Warn("Storage ping failed", "failures_in_a_row", m.streak, "error", err)
+	if m.streak >= m.opts.Failures {
+		m.markServing(false)
+	}
+	return false
+}
+
+// recover reconnects with backoff until a ping succeeds or ctx is done
+func (m *HealthMonitor) recover(ctx context.Context) {
+	reconnector, canReconnect := m.target.(Reconnector)
+	delay := m.opts.Backoff
+	for {
+		if canReconnect {
+			if err := reconnector.Reconnect(); err != nil {
+				slog.Warn("Failed to reconnect to storage", "retry_in", delay, "error", err)
+			} else {
+				m.reconnects.Add(1)
+				slog.Info("Reconnected to storage")
+			}
+		}
+		if m.CheckOnce(ctx) {
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = min(delay*2, m.opts.MaxBackoff)
+	}
+}
+
+func (m *HealthMonitor) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, m.opts.Interval)
+	defer cancel()
+	return m.target.Ping(ctx)
+}
+
+func (m *HealthMonitor) markServing(serving bool) {
+	if m.serving.Swap(serving) == serving {
+		return
+	}
+	if serving {
+		slog.Info("Storage is reachable again")
+	} else {
+		slog.Error("Storage is unreachable, reporting NOT_SERVING", "failures_in_a_row", m.streak)
+	}
+	m.setServing(serving)
+}
+
+// Stats returns a snapshot of the counters
+func (m *HealthMonitor) Stats() HealthStats {
+	return HealthStats{
+		Serving:    m.serving.Load(),
+		Failures:   m.failures.Load(),
+		Reconnects: m.reconnects.Load(),
+	}
+}
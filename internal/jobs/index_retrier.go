@@ -0,0 +1,95 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+)
+
+// IndexOpRetrier retries lookup table writes that failed earlier
+type IndexOpRetrier interface {
+	RetryIndexOps(ctx context.Context, opts scylla.RetryOptions) (*scylla.RetryReport, error)
+}
+
+// IndexRetryStats counts index retry activity since the job was created
+type IndexRetryStats struct {
+	// Depth is the queue depth seen by the latest run
+	Depth     int64  `json:"depth"`
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	// Finished counts changes of interrupted updates written by the job
+	Finished uint64 `json:"finished"`
+}
+
+// IndexRetrier periodically works through the queue of failed lookup writes
+type IndexRetrier struct {
+	target   IndexOpRetrier
+	interval time.Duration
+	opts     scylla.RetryOptions
+
+	depth     atomic.Int64
+	succeeded atomic.Uint64
+	failed    atomic.Uint64
+	finished  atomic.Uint64
+}
+
+// NewIndexRetrier creates a new index retry job
+func NewIndexRetrier(target IndexOpRetrier, interval time.Duration, opts scylla.RetryOptions) *IndexRetrier {
+	return &IndexRetrier{
+		target:   target,
+		interval: interval,
+		opts:     opts,
+	}
+}
+
+// Run retries once immediately and then on every interval until ctx is done
+func (r *IndexRetrier) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RetryOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to retry pending index operations", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RetryOnce makes one pass over the queue and updates the stats
+func (r *IndexRetrier) RetryOnce(ctx context.Context) (*scylla.RetryReport, error) {
+	report, err := r.target.RetryIndexOps(ctx, r.opts)
+	if report == nil {
+		return nil, err
+	}
+	r.succeeded.Add(uint64(report.Succeeded))
+	r.failed.Add(uint64(report.Failed))
+	r.finished.Add(uint64(report.Finished))
+	// A run cut short by an error hasn't seen the whole queue
+	if err == nil {
+		r.depth.Store(int64(report.Depth))
+	}
+	if report.Succeeded > 0 || report.Failed > 0 || report.Finished > 0 {
+		slog.Info("Retried pending index operations",
+			"succeeded", report.Succeeded, "failed", report.Failed, "depth", report.Depth,
+			"finished_changes", report.Finished)
+	}
+	return report, err
+}
+
+// Stats returns a snapshot of the counters
+func (r *IndexRetrier) Stats() IndexRetryStats {
+	return IndexRetryStats{
+		Depth:     r.depth.Load(),
+		Succeeded: r.succeeded.Load(),
+		Failed:    r.failed.Load(),
+		Finished:  r.finished.Load(),
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// Purger periodically hard-deletes users that have been soft-deleted for
+// longer than the retention window
+type Purger struct {
+	storage   storage.Storage
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewPurger creates a new purger
+func NewPurger(storage storage.Storage, retention, interval time.Duration) *Purger {
+	return &Purger{
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges once immediately and then on every interval until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.PurgeOnce(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Failed to purge deleted users", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeOnce removes every user deleted before the retention cutoff
+func (p *Purger) PurgeOnce(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-p.retention)
+	purged, err := p.storage.PurgeDeletedUsers(ctx, cutoff)
+	if purged > 0 {
+		slog.Info("Purged deleted users", "count", purged, "cutoff", cutoff)
+	}
+	return purged, err
+}
@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+)
+
+// LookupReconciler checks lookup tables against the users table
+type LookupReconciler interface {
+	Reconcile(ctx context.Context, opts scylla.ReconcileOptions) (*scylla.ReconcileReport, error)
+}
+
+// Reconciler periodically runs a lookup reconciliation. With Repair unset in
+// its options it only reports what it finds.
+type Reconciler struct {
+	target   LookupReconciler
+	interval time.Duration
+	opts     scylla.ReconcileOptions
+}
+
+// NewReconciler creates a new reconciler job
+func NewReconciler(target LookupReconciler, interval time.Duration, opts scylla.ReconcileOptions) *Reconciler {
+	return &Reconciler{
+		target:   target,
+		interval: interval,
+		opts:     opts,
+	}
+}
+
+// Run reconciles on every interval until ctx is done. The first run waits
+// one interval so it doesn't compete with startup traffic.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := r.target.Reconcile(ctx, r.opts)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Lookup reconciliation failed", "error", err)
+			}
+			continue
+		}
+		slog.Info("Lookup reconciliation finished",
+			"users", report.UsersScanned,
+			"lookups", report.LookupsScanned,
+			"issues", len(report.Issues),
+			"repaired", report.Repaired,
+			"dry_run", !r.opts.Repair,
+		)
+	}
+}
@@ -0,0 +1,410 @@
+// internal/listquery/listquery.go
+package listquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/pkg/validator"
+)
+
+// Fields that can be filtered or ordered on
+const (
+	FieldIsBlocked   = "is_blocked"
+	FieldGender      = "gender"
+	FieldCreatedAt   = "created_at"
+	FieldUpdatedAt   = "updated_at"
+	FieldDateOfBirth = "date_of_birth"
+	FieldLastName    = "last_name"
+)
+
+// dateLayout is the format of date_of_birth values
+const dateLayout = "2006-01-02"
+
+// Range is a half-open time range: From is inclusive, To exclusive. A zero
+// time leaves that end open.
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// IsZero reports whether the range is open at both ends
+func (r Range) IsZero() bool {
+	return r.From.IsZero() && r.To.IsZero()
+}
+
+// Contains reports whether t lies in the range
+func (r Range) Contains(t time.Time) bool {
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !t.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// narrow raises From to from and lowers To to to, whichever is tighter
+func (r *Range) narrow(from, to time.Time) {
+	if !from.IsZero() && (r.From.IsZero() || from.After(r.From)) {
+		r.From = from
+	}
+	if !to.IsZero() && (r.To.IsZero() || to.Before(r.To)) {
+		r.To = to
+	}
+}
+
+// Filter restricts which users ListUsers returns. The zero Filter matches
+// every user.
+type Filter struct {
+	// Blocked, if not nil, keeps only users in that blocked state
+	Blocked *bool
+	// Gender, if not empty, keeps only users of that gender
+	Gender      string
+	CreatedAt   Range
+	UpdatedAt   Range
+	DateOfBirth Range
+}
+
+// Match reports whether user passes the filter. Soft-deleted users are left
+// to the caller.
+func (f Filter) Match(user *domain.User) bool {
+	switch {
+	case f.Blocked != nil && user.IsBlocked != *f.Blocked:
+		return false
+	case f.Gender != "" && user.Gender != f.Gender:
+		return false
+	case !f.CreatedAt.Contains(user.CreatedAt):
+		return false
+	case !f.UpdatedAt.Contains(user.UpdatedAt):
+		return false
+	case !f.DateOfBirth.Contains(user.DateOfBirth):
+		return false
+	}
+	return true
+}
+
+// BlockedOnly reports whether f restricts nothing but the blocked state, so
+// the users it matches can be counted by blocked state alone
+func (f Filter) BlockedOnly() bool {
+	return f.Gender == "" && f.CreatedAt.IsZero() && f.UpdatedAt.IsZero() && f.DateOfBirth.IsZero()
+}
+
+// ParseFilter parses a filter in the AIP-160 subset ListUsers supports:
+// comparisons joined by AND, which is required between every two, such as
+//
+//	is_blocked = false AND created_at >= "2025-01-01T00:00:00Z"
+//
+// is_blocked takes true or false with = or !=, gender one of the valid
+// genders with =. created_at and updated_at take RFC 3339 timestamps and
+// date_of_birth dates (YYYY-MM-DD), with =, <, <=, > or >=. Comparisons on
+// the same time field narrow its range. OR, NOT and parentheses are not
+// supported. An empty string is the zero Filter.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return Filter{}, err
+	}
+
+	var f Filter
+	for i := 0; i < len(tokens); {
+		if i > 0 {
+			if tokens[i].quoted || tokens[i].text != "AND" {
+				if tokens[i].text == "OR" && !tokens[i].quoted {
+					return Filter{}, errors.New("OR is not supported in filters")
+				}
+				return Filter{}, fmt.Errorf("expected AND between comparisons, got %q", tokens[i].text)
+			}
+			i++
+		}
+		if i+3 > len(tokens) {
+			return Filter{}, errors.New("filter ends in an incomplete comparison")
+		}
+		field, op, value := tokens[i], tokens[i+1], tokens[i+2]
+		i += 3
+
+		if field.quoted || !isOperator(op.text) || op.quoted {
+			switch field.text {
+			case "OR", "NOT":
+				return Filter{}, fmt.Errorf("%s is not supported in filters", field.text)
+			}
+			return Filter{}, fmt.Errorf("expected a comparison such as is_blocked = true, got %q", field.text+" "+op.text)
+		}
+		if err := f.apply(field.text, op.text, value.text); err != nil {
+			return Filter{}, err
+		}
+	}
+	return f, nil
+}
+
+// apply narrows f by one comparison
+func (f *Filter) apply(field, op, value string) error {
+	switch field {
+	case FieldIsBlocked:
+		if f.Blocked != nil {
+			return fmt.Errorf("%s appears more than once", field)
+		}
+		var blocked bool
+		switch value {
+		case "true":
+			blocked = true
+		case "false":
+		default:
+			return fmt.Errorf("%s takes true or false, got %q", field, value)
+		}
+		switch op {
+		case "=":
+		case "!=":
+			blocked = !blocked
+		default:
+			return fmt.Errorf("%s only supports = and !=", field)
+		}
+		f.Blocked = &blocked
+	case FieldGender:
+		if f.Gender != "" {
+			return fmt.Errorf("%s appears more than once", field)
+		}
+		if op != "=" {
+			return fmt.Errorf("%s only supports =", field)
+		}
+		if !validator.ValidateGender(value) {
+			return fmt.Errorf("%s must be male, female or other, got %q", field, value)
+		}
+		f.Gender = value
+	case FieldCreatedAt, FieldUpdatedAt:
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("%s takes an RFC 3339 timestamp, got %q", field, value)
+		}
+		from, to, err := bounds(field, op, t, t.Add(time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		if field == FieldCreatedAt {
+			f.CreatedAt.narrow(from, to)
+		} else {
+			f.UpdatedAt.narrow(from, to)
+		}
+	case FieldDateOfBirth:
+		d, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return fmt.Errorf("%s takes a date such as 1990-01-15, got %q", field, value)
+		}
+		from, to, err := bounds(field, op, d, d.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		f.DateOfBirth.narrow(from, to)
+	default:
+		return fmt.Errorf("unknown filter field %q, expected %s, %s, %s, %s or %s",
+			field, FieldIsBlocked, FieldGender, FieldCreatedAt, FieldUpdatedAt, FieldDateOfBirth)
+	}
+	return nil
+}
+
+// bounds turns a comparison with v into a range. next is the smallest value
+// after v: the next nanosecond for timestamps, the next day for dates.
+func bounds(field, op string, v, next time.Time) (from, to time.Time, err error) {
+	switch op {
+	case "=":
+		return v, next, nil
+	case ">":
+		return next, time.Time{}, nil
+	case ">=":
+		return v, time.Time{}, nil
+	case "<":
+		return time.Time{}, v, nil
+	case "<=":
+		return time.Time{}, next, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("%s does not support %s", field, op)
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "=", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+// tokenize splits a filter into words, quoted strings and operators
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			return nil, errors.New("parentheses are not supported in filters")
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("filter has an unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+		case strings.IndexByte("=!<>", c) >= 0:
+			end := i + 1
+			if end < len(s) && s[end] == '=' {
+				end++
+			}
+			if !isOperator(s[i:end]) {
+				return nil, fmt.Errorf("unknown operator %q", s[i:end])
+			}
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		default:
+			end := i
+			for end < len(s) && strings.IndexByte(" \t\n()\"=!<>", s[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// Order is the order ListUsers returns users in. The zero Order is by ID.
+// Users with the same created_at or last_name are ordered by ID, in the same
+// direction.
+type Order struct {
+	// Field is empty, FieldCreatedAt or FieldLastName
+	Field string
+	Desc  bool
+}
+
+// ParseOrder parses an AIP-132 order_by of a single field, created_at or
+// last_name, optionally followed by asc or desc. An empty string is the zero
+// Order.
+func ParseOrder(s string) (Order, error) {
+	if strings.Contains(s, ",") {
+		return Order{}, errors.New("order_by takes a single field")
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return Order{}, nil
+	}
+	if len(words) > 2 {
+		return Order{}, fmt.Errorf("order_by must be a field followed by asc or desc, got %q", s)
+	}
+
+	var o Order
+	switch words[0] {
+	case FieldCreatedAt, FieldLastName:
+		o.Field = words[0]
+	default:
+		return Order{}, fmt.Errorf("cannot order by %q, expected %s or %s", words[0], FieldCreatedAt, FieldLastName)
+	}
+	if len(words) == 2 {
+		switch words[1] {
+		case "asc":
+		case "desc":
+			o.Desc = true
+		default:
+			return Order{}, fmt.Errorf("order_by direction must be asc or desc, got %q", words[1])
+		}
+	}
+	return o, nil
+}
+
+// Key returns the value user is ordered by: a time.Time for created_at, the
+// last name folded by search.Fold for last_name, and the ID otherwise. Every
+// backend orders by these values, comparing strings byte by byte, so case
+// and accents don't split a name and a page token means the same position
+// on each.
+func (o Order) Key(user *domain.User) interface{} {
+	switch o.Field {
+	case FieldCreatedAt:
+		return user.CreatedAt
+	case FieldLastName:
+		return search.Fold(user.LastName)
+	default:
+		return user.ID
+	}
+}
+
+// Less reports whether a comes before b
+func (o Order) Less(a, b *domain.User) bool {
+	return o.compare(a, o.Key(b), b.ID) < 0
+}
+
+// After reports whether user comes after the position key, id
+func (o Order) After(user *domain.User, key interface{}, id string) bool {
+	return o.compare(user, key, id) > 0
+}
+
+// compare orders user against the position key, id
+func (o Order) compare(user *domain.User, key interface{}, id string) int {
+	c := 0
+	switch o.Field {
+	case FieldCreatedAt:
+		c = user.CreatedAt.Compare(key.(time.Time))
+	case FieldLastName:
+		c = strings.Compare(search.Fold(user.LastName), key.(string))
+	}
+	if c == 0 {
+		c = strings.Compare(user.ID, id)
+	}
+	if o.Desc {
+		c = -c
+	}
+	return c
+}
+
+// cursor is the position of the last user on a page in an Order other than
+// by ID
+type cursor struct {
+	Key string `json:"k"`
+	ID  string `json:"i"`
+}
+
+// Cursor returns the page token that continues after user. It is only used
+// for orders by a field; ordered by ID, the ID is the cursor.
+func (o Order) Cursor(user *domain.User) string {
+	c := cursor{ID: user.ID}
+	switch o.Field {
+	case FieldCreatedAt:
+		c.Key = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case FieldLastName:
+		c.Key = search.Fold(user.LastName)
+	}
+	token, _ := json.Marshal(c)
+	return string(token)
+}
+
+// ParseCursor reads a page token written by Cursor, returning the key as Key
+// would and the ID. A malformed token returns domain.ErrInvalidPageToken.
+func (o Order) ParseCursor(token string) (interface{}, string, error) {
+	var c cursor
+	if err := json.Unmarshal([]byte(token), &c); err != nil || c.ID == "" {
+		return nil, "", domain.ErrInvalidPageToken
+	}
+	if o.Field != FieldCreatedAt {
+		return c.Key, c.ID, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Key)
+	if err != nil {
+		return nil, "", domain.ErrInvalidPageToken
+	}
+	return t, c.ID, nil
+}
@@ -0,0 +1,331 @@
+// internal/search/search.go
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MinTermLength is the shortest term, in characters, a query may have.
+	// It is also the length of the ScyllaDB index partition key.
+	MinTermLength = 2
+	// MaxTerms is the most terms a query may have
+	MaxTerms = 5
+	// MaxCandidates bounds the users a search reads from the index and ranks.
+	// A search with more candidates fails rather than rank only some.
+	MaxCandidates = 1000
+	// minPhoneSuffix is the shortest phone suffix indexed, so the last four
+	// digits find a number
+	minPhoneSuffix = 4
+)
+
+// Scores of a term that matches a name word exactly, the start of a name
+// word, and the start of an email or phone token
+const (
+	scoreExactName  = 3
+	scorePrefixName = 2
+	scoreContact    = 1
+)
+
+// letters that don't decompose into a base letter and an accent
+var foldReplacer = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ı", "i")
+
+// Fold lowercases s and strips accents, so "Zoë" and "ZOE" both become
+// "zoe"
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folded, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		folded = strings.ToLower(s)
+	}
+	return foldReplacer.Replace(folded)
+}
+
+// Query is a parsed search query. A user matches when every term is the
+// start of one of their tokens.
+type Query struct {
+	Terms []string
+}
+
+// ParseQuery splits s into folded terms. Phone-like terms such as
+// "+1-202-555" keep only their digits.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	for _, field := range strings.Fields(s) {
+		term := Fold(field)
+		if isPhoneLike(term) {
+			term = digits(term)
+		}
+		if term == "" {
+			continue
+		}
+		if utf8.RuneCountInString(term) < MinTermLength {
+			return Query{}, fmt.Errorf("search terms need at least %d characters, %q is too short", MinTermLength, field)
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	if len(q.Terms) == 0 {
+		return Query{}, errors.New("search query is empty")
+	}
+	if len(q.Terms) > MaxTerms {
+		return Query{}, fmt.Errorf("search query has more than %d terms", MaxTerms)
+	}
+	return q, nil
+}
+
+// String is the canonical form of the query
+func (q Query) String() string {
+	return strings.Join(q.Terms, " ")
+}
+
+// Longest returns the longest term, which is likely the most selective one
+// to look up in an index
+func (q Query) Longest() string {
+	longest := ""
+	for _, term := range q.Terms {
+		if utf8.RuneCountInString(term) > utf8.RuneCountInString(longest) {
+			longest = term
+		}
+	}
+	return longest
+}
+
+// PrefixEnd is the upper bound of the range of tokens that start with
+// prefix: every such token sorts at or before it
+func PrefixEnd(prefix string) string {
+	return prefix + string(utf8.MaxRune)
+}
+
+// Partition is the ScyllaDB index partition of a token or term: its first
+// MinTermLength characters
+func Partition(token string) string {
+	n := 0
+	for i := range token {
+		if n == MinTermLength {
+			return token[:i]
+		}
+		n++
+	}
+	return token
+}
+
+// Tokens lists what a user is found by: the words of their names, their
+// email, its domain and the words of its local part, and every suffix of
+// their phone digits down to four digits. Tokens shorter than
+// MinTermLength are left out, since no term can match them.
+func Tokens(user *domain.User) []string {
+	names, contact := tokens(user)
+	seen := make(map[string]bool, len(names)+len(contact))
+	var all []string
+	for _, token := range append(names, contact...) {
+		if seen[token] || utf8.RuneCountInString(token) < MinTermLength {
+			continue
+		}
+		seen[token] = true
+		all = append(all, token)
+	}
+	sort.Strings(all)
+	return all
+}
+
+func tokens(user *domain.User) (names, contact []string) {
+	for _, word := range strings.Fields(Fold(user.FirstName + " " + user.LastName)) {
+		names = append(names, word)
+		// Jean-Luc and O'Brien are also found by their parts
+		parts := strings.FieldsFunc(word, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(parts) > 1 {
+			names = append(names, parts...)
+		}
+	}
+
+	for _, email := range []string{Fold(user.EmailKey()), Fold(user.Email)} {
+		contact = append(contact, email)
+		local, domainPart, ok := strings.Cut(email, "@")
+		if !ok {
+			continue
+		}
+		contact = append(contact, domainPart)
+		contact = append(contact, strings.FieldsFunc(local, func(r rune) bool {
+			return strings.ContainsRune("._-+", r)
+		})...)
+	}
+
+	phone := digits(user.PhoneNumber)
+	for i := 0; i <= len(phone)-minPhoneSuffix; i++ {
+		contact = append(contact, phone[i:])
+	}
+	return names, contact
+}
+
+// Score rates how well user matches q; 0 means it doesn't. Each term adds
+// the score of its best match, so whole names beat prefixes and names beat
+// contact details.
+func (q Query) Score(user *domain.User) int {
+	names, contact := tokens(user)
+	total := 0
+	for _, term := range q.Terms {
+		best := 0
+		for _, token := range names {
+			switch {
+			case token == term:
+				best = max(best, scoreExactName)
+			case strings.HasPrefix(token, term):
+				best = max(best, scorePrefixName)
+			}
+		}
+		if best == 0 {
+			for _, token := range contact {
+				if strings.HasPrefix(token, term) {
+					best = scoreContact
+					break
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// cursor is the position of the last user on a page in relevance order
+type cursor struct {
+	Score     int    `json:"s"`
+	LastName  string `json:"l"`
+	FirstName string `json:"f"`
+	ID        string `json:"i"`
+}
+
+// less reports whether a comes before b in relevance order
+func (a cursor) less(b cursor) bool {
+	switch {
+	case a.Score != b.Score:
+		return a.Score > b.Score
+	case a.LastName != b.LastName:
+		return a.LastName < b.LastName
+	case a.FirstName != b.FirstName:
+		return a.FirstName < b.FirstName
+	default:
+		return a.ID < b.ID
+	}
+}
+
+type match struct {
+	user *domain.User
+	key  cursor
+}
+
+// Rank drops the candidates that don't match q, orders the rest by score,
+// then last name, first name and ID, and returns the limit users after the
+// page token. Candidates come from an index, which can be stale, so each one
+// is checked against q again. More than MaxCandidates matches fail with
+// domain.ErrSearchTooBroad.
+func Rank(q Query, candidates []*domain.User, limit int, pageToken string) ([]*domain.User, string, error) {
+	var after *cursor
+	if pageToken != "" {
+		after = &cursor{}
+		if err := json.Unmarshal([]byte(pageToken), after); err != nil {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+	}
+
+	var matches []match
+	found := 0
+	for _, user := range candidates {
+		score := q.Score(user)
+		if score == 0 {
+			continue
+		}
+		if found++; found > MaxCandidates {
+			return nil, "", domain.ErrSearchTooBroad
+		}
+		m := match{user: user, key: cursor{
+			Score:     score,
+			LastName:  Fold(user.LastName),
+			FirstName: Fold(user.FirstName),
+			ID:        user.ID,
+		}}
+		if after != nil && !after.less(m.key) {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].key.less(matches[j].key)
+	})
+
+	nextToken := ""
+	if len(matches) > limit {
+		matches = matches[:limit]
+		next, err := json.Marshal(matches[limit-1].key)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to encode search cursor: %w", err)
+		}
+		nextToken = string(next)
+	}
+	users := make([]*domain.User, len(matches))
+	for i, m := range matches {
+		users[i] = m.user
+	}
+	return users, nextToken, nil
+}
+
+func isPhoneLike(term string) bool {
+	hasDigit := false
+	for _, r := range term {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case strings.ContainsRune("+-().", r):
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// Changes lists the tokens to remove from and add to the index when a user
+// changes from before to after. A nil before is a new user.
+func Changes(before, after *domain.User) (removed, added []string) {
+	old := map[string]bool{}
+	if before != nil {
+		for _, token := range Tokens(before) {
+			old[token] = true
+		}
+	}
+	for _, token := range Tokens(after) {
+		if old[token] {
+			delete(old, token)
+			continue
+		}
+		added = append(added, token)
+	}
+	for token := range old {
+		removed = append(removed, token)
+	}
+	sort.Strings(removed)
+	return removed, added
+}
@@ -0,0 +1,250 @@
+// internal/storage/cache/cache.go
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"golang.org/x/sync/singleflight"
+)
+
+var _ storage.Storage = (*CachedStorage)(nil)
+
+// loadTimeout bounds a load shared by concurrent misses. It runs detached
+// from the callers' contexts, so one caller giving up doesn't fail the rest.
+const loadTimeout = 10 * time.Second
+
+// Stats counts cache activity since the cache was created
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+type entry struct {
+	key     string
+	user    *domain.User
+	expires time.Time
+}
+
+// CachedStorage wraps a storage.Storage with a read-through LRU cache for
+// GetUserByID, GetUserByEmail and GetUserByPhone. Writes made through it
+// invalidate the affected entries; writes made by other replicas are only
+// seen once the TTL expires. Missing users are not cached.
+type CachedStorage struct {
+	storage.Storage
+
+	size  int
+	ttl   time.Duration
+	group singleflight.Group
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	// byUser tracks the keys cached for each user ID so all of them can be
+	// dropped together, including ones for an email or phone the user no
+	// longer has
+	byUser map[string]map[string]struct{}
+	// generation is bumped on every invalidation. A load that started before
+	// an invalidation may have read the old row, so its result is dropped.
+	generation uint64
+	stats      Stats
+}
+
+// NewCachedStorage creates a cache holding at most size users for up to ttl
+func NewCachedStorage(next storage.Storage, size int, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{
+		Storage: next,
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		byUser:  make(map[string]map[string]struct{}),
+	}
+}
+
+// GetUserByID retrieves a user by ID
+func (c *CachedStorage) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	return c.get(ctx, "id:"+id, func(ctx context.Context) (*domain.User, error) {
+		return c.Storage.GetUserByID(ctx, id)
+	})
+}
+
+// GetUserByPhone retrieves a user by phone number
+func (c *CachedStorage) GetUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	return c.get(ctx, "phone:"+phone, func(ctx context.Context) (*domain.User, error) {
+		return c.Storage.GetUserByPhone(ctx, phone)
+	})
+}
+
+// GetUserByEmail retrieves a user by email
+func (c *CachedStorage) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return c.get(ctx, "email:"+email, func(ctx context.Context) (*domain.User, error) {
+		return c.Storage.GetUserByEmail(ctx, email)
+	})
+}
+
+// UpdateUser updates a user and drops its cached entries
+func (c *CachedStorage) UpdateUser(ctx context.Context, user *domain.User) error {
+	defer c.invalidate(user.ID)
+	return c.Storage.UpdateUser(ctx, user)
+}
+
+// DeleteUser deletes a user and drops its cached entries
+func (c *CachedStorage) DeleteUser(ctx context.Context, id string, version *int64) error {
+	defer c.invalidate(id)
+	return c.Storage.DeleteUser(ctx, id, version)
+}
+
+// RestoreUser restores a user and drops its cached entries
+func (c *CachedStorage) RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error) {
+	defer c.invalidate(id)
+	return c.Storage.RestoreUser(ctx, id, version)
+}
+
+// PurgeDeletedUsers purges users and clears the cache, since it doesn't know
+// which users were removed
+func (c *CachedStorage) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	purged, err := c.Storage.PurgeDeletedUsers(ctx, before)
+	if purged > 0 {
+		c.clear()
+	}
+	return purged, err
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *CachedStorage) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+// get returns the cached user for key or loads it. Concurrent misses for the
+// same key share a single load, which keeps the first caller's values but not
+// its cancellation and runs for up to loadTimeout. Each caller stops waiting
+// when its own ctx is done.
+func (c *CachedStorage) get(ctx context.Context, key string, load func(context.Context) (*domain.User, error)) (*domain.User, error) {
+	if user, ok := c.lookup(key); ok {
+		return user, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	loadCtx := context.WithoutCancel(ctx)
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(loadCtx, loadTimeout)
+		defer cancel()
+		user, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, user, generation)
+		return user, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return clone(res.Val.(*domain.User)), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *CachedStorage) lookup(key string) (*domain.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(elem)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return clone(e.user), true
+}
+
+func (c *CachedStorage) store(key string, user *domain.User, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 || generation != c.generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	e := &entry{key: key, user: clone(user), expires: time.Now().Add(c.ttl)}
+	c.entries[key] = c.lru.PushFront(e)
+	keys, ok := c.byUser[user.ID]
+	if !ok {
+		keys = make(map[string]struct{})
+		c.byUser[user.ID] = keys
+	}
+	keys[key] = struct{}{}
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops every entry cached for the user
+func (c *CachedStorage) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for key := range c.byUser[id] {
+		if elem, ok := c.entries[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+func (c *CachedStorage) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.byUser = make(map[string]map[string]struct{})
+}
+
+// remove unlinks elem; the caller holds mu
+func (c *CachedStorage) remove(elem *list.Element) {
+	e := c.lru.Remove(elem).(*entry)
+	delete(c.entries, e.key)
+	if keys, ok := c.byUser[e.user.ID]; ok {
+		delete(keys, e.key)
+		if len(keys) == 0 {
+			delete(c.byUser, e.user.ID)
+		}
+	}
+}
+
+// clone copies a user so callers can't modify the cached value
+func clone(user *domain.User) *domain.User {
+	u := *user
+	return &u
+}
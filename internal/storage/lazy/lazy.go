@@ -0,0 +1,243 @@
+// internal/storage/lazy/lazy.go
+package lazy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+var _ storage.Storage = (*Storage)(nil)
+
+// Storage stands in for a backend that isn't connected yet, so the gRPC
+// server can start before the database is reachable. Every call returns
+// domain.ErrStorageUnavailable until Set is called.
+type Storage struct {
+	backend atomic.Pointer[backend]
+}
+
+// backend boxes the interface so it can be swapped atomically
+type backend struct {
+	storage.Storage
+}
+
+// New creates a Storage with no backend
+func New() *Storage {
+	return &Storage{}
+}
+
+// Set hands every further call to next
+func (s *Storage) Set(next storage.Storage) {
+	s.backend.Store(&backend{next})
+}
+
+// Ready reports whether a backend has been set
+func (s *Storage) Ready() bool {
+	return s.backend.Load() != nil
+}
+
+func (s *Storage) get() (storage.Storage, error) {
+	b := s.backend.Load()
+	if b == nil {
+		return nil, domain.ErrStorageUnavailable
+	}
+	return b.Storage, nil
+}
+
+func (s *Storage) CreateUser(ctx context.Context, user *domain.User) error {
+	next, err := s.get()
+	if err != nil {
+		return err
+	}
+	return next.CreateUser(ctx, user)
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.GetUserByID(ctx, id)
+}
+
+func (s *Storage) GetUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.GetUserByPhone(ctx, phone)
+}
+
+func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.GetUserByEmail(ctx, email)
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, user *domain.User) error {
+	next, err := s.get()
+	if err != nil {
+		return err
+	}
+	return next.UpdateUser(ctx, user)
+}
+
+func (s *Storage) DeleteUser(ctx context.Context, id string, version *int64) error {
+	next, err := s.get()
+	if err != nil {
+		return err
+	}
+	return next.DeleteUser(ctx, id, version)
+}
+
+func (s *Storage) RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.RestoreUser(ctx, id, version)
+}
+
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	next, err := s.get()
+	if err != nil {
+		return 0, err
+	}
+	return next.PurgeDeletedUsers(ctx, before)
+}
+
+func (s *Storage) ListUsers(ctx context.Context, opts storage.ListOptions) ([]*domain.User, string, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	return next.ListUsers(ctx, opts)
+}
+
+func (s *Storage) ListAuditRecords(ctx context.Context, userID string, opts storage.AuditListOptions) ([]*domain.AuditRecord, string, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	return next.ListAuditRecords(ctx, userID, opts)
+}
+
+func (s *Storage) ListUserVersions(ctx context.Context, userID string, opts storage.VersionListOptions) ([]*domain.User, string, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	return next.ListUserVersions(ctx, userID, opts)
+}
+
+func (s *Storage) GetUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.GetUserVersion(ctx, userID, version)
+}
+
+func (s *Storage) GetUserAtTime(ctx context.Context, userID string, t time.Time) (*domain.User, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, err
+	}
+	return next.GetUserAtTime(ctx, userID, t)
+}
+
+func (s *Storage) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]*domain.User, string, error) {
+	next, err := s.get()
+	if err != nil {
+		return nil, "", err
+	}
+	return next.SearchUsers(ctx, opts)
+}
+
+func (s *Storage) ExportUsers(ctx context.Context, opts storage.ExportOptions, fn func(*domain.User) error) error {
+	next, err := s.get()
+	if err != nil {
+		return err
+	}
+	return next.ExportUsers(ctx, opts, fn)
+}
+
+func (s *Storage) CountUsers(ctx context.Context, blocked *bool) (int64, error) {
+	next, err := s.get()
+	if err != nil {
+		return 0, err
+	}
+	return next.CountUsers(ctx, blocked)
+}
+
+func (s *Storage) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	next, err := s.get()
+	if err != nil {
+		return false, err
+	}
+	return next.CheckEmailExists(ctx, email)
+}
+
+func (s *Storage) CheckPhoneExists(ctx context.Context, phone string) (bool, error) {
+	next, err := s.get()
+	if err != nil {
+		return false, err
+	}
+	return next.CheckPhoneExists(ctx, phone)
+}
+
+// Close closes the backend, if one was set
+func (s *Storage) Close() error {
+	if b := s.backend.Load(); b != nil {
+		return b.Close()
+	}
+	return nil
+}
+
+// ConnectOptions controls how Connect retries
+type ConnectOptions struct {
+	// Backoff is the delay after the first failed attempt. It doubles with
+	// every further failure up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Deadline bounds all attempts together
+	Deadline time.Duration
+}
+
+// Connect calls open until it succeeds, backing off between attempts. It
+// gives up once opts.Deadline has passed or ctx is done, returning the last
+// error.
+func Connect(ctx context.Context, opts ConnectOptions, open func() (storage.Storage, error)) (storage.Storage, error) {
+	if opts.Backoff <= 0 || opts.MaxBackoff < opts.Backoff || opts.Deadline <= 0 {
+		return nil, errors.New("connect retries need 0 < backoff <= max_backoff and a positive deadline")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Deadline)
+	defer cancel()
+
+	delay := opts.Backoff
+	for attempt := 1; ; attempt++ {
+		db, err := open()
+		if err == nil {
+			return db, nil
+		}
+		slog.Warn("Storage is not reachable yet", "attempt", attempt, "retry_in", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("gave up connecting to storage after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		delay = min(delay*2, opts.MaxBackoff)
+	}
+}
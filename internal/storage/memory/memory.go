@@ -0,0 +1,471 @@
+// internal/storage/memory/memory.go
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+var (
+	_ storage.Storage = (*MemoryStorage)(nil)
+	_ storage.Outbox  = (*MemoryStorage)(nil)
+)
+
+// MemoryStorage keeps users in process memory. Data is lost on restart, so it
+// is meant for local development and tests rather than production.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	users   map[string]*domain.User
+	byEmail map[string]string
+	byPhone map[string]string
+	// outbox holds the pending user change events in the order they happened
+	outbox []*domain.Event
+	// audit holds each user's audit records, oldest first
+	audit map[string][]*domain.AuditRecord
+	// versions holds each user's snapshots, oldest first
+	versions map[string][]*domain.User
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users:    make(map[string]*domain.User),
+		byEmail:  make(map[string]string),
+		byPhone:  make(map[string]string),
+		audit:    make(map[string][]*domain.AuditRecord),
+		versions: make(map[string][]*domain.User),
+	}
+}
+
+// CreateUser creates a new user
+func (m *MemoryStorage) CreateUser(ctx context.Context, user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.byEmail[user.EmailKey()]; ok {
+		return domain.ErrEmailAlreadyExists
+	}
+	if _, ok := m.byPhone[user.PhoneNumber]; ok {
+		return domain.ErrPhoneAlreadyExists
+	}
+
+	m.users[user.ID] = clone(user)
+	m.byEmail[user.EmailKey()] = user.ID
+	m.byPhone[user.PhoneNumber] = user.ID
+	m.record(ctx, nil, user)
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (m *MemoryStorage) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return clone(user), nil
+}
+
+// GetUserByPhone retrieves a user by phone number
+func (m *MemoryStorage) GetUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byPhone[phone]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return clone(m.users[id]), nil
+}
+
+// GetUserByEmail retrieves a user by canonical email
+func (m *MemoryStorage) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.byEmail[email]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return clone(m.users[id]), nil
+}
+
+// UpdateUser updates an existing user if its stored version still matches
+// user.Version, and bumps the version on success
+func (m *MemoryStorage) UpdateUser(ctx context.Context, user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.users[user.ID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	if existing.Version != user.Version {
+		return domain.ErrVersionConflict
+	}
+	if id, ok := m.byEmail[user.EmailKey()]; ok && id != user.ID {
+		return domain.ErrEmailAlreadyExists
+	}
+	if id, ok := m.byPhone[user.PhoneNumber]; ok && id != user.ID {
+		return domain.ErrPhoneAlreadyExists
+	}
+
+	if existing.EmailKey() != user.EmailKey() {
+		delete(m.byEmail, existing.EmailKey())
+		m.byEmail[user.EmailKey()] = user.ID
+	}
+	if existing.PhoneNumber != user.PhoneNumber {
+		delete(m.byPhone, existing.PhoneNumber)
+		m.byPhone[user.PhoneNumber] = user.ID
+	}
+	user.Version++
+	m.record(ctx, existing, user)
+	m.users[user.ID] = clone(user)
+	return nil
+}
+
+// DeleteUser soft-deletes a user
+func (m *MemoryStorage) DeleteUser(ctx context.Context, id string, version *int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok || user.IsDeleted() {
+		return domain.ErrUserNotFound
+	}
+	if version != nil && user.Version != *version {
+		return domain.ErrVersionConflict
+	}
+	before := clone(user)
+	user.Delete()
+	user.Version++
+	m.record(ctx, before, user)
+	return nil
+}
+
+// RestoreUser undoes a soft delete
+func (m *MemoryStorage) RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.IsDeleted() {
+		return nil, domain.ErrUserNotDeleted
+	}
+	if version != nil && user.Version != *version {
+		return nil, domain.ErrVersionConflict
+	}
+	before := clone(user)
+	user.Restore()
+	user.Version++
+	m.record(ctx, before, user)
+	return clone(user), nil
+}
+
+// PurgeDeletedUsers hard-deletes users soft-deleted before the cutoff
+func (m *MemoryStorage) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for id, user := range m.users {
+		if !user.IsDeleted() || !user.DeletedAt.Before(before) {
+			continue
+		}
+		delete(m.byEmail, user.EmailKey())
+		delete(m.byPhone, user.PhoneNumber)
+		delete(m.users, id)
+		delete(m.versions, id)
+		purged++
+	}
+	return purged, nil
+}
+
+// ListUsers lists the matching users in opts.Order, by ID if not set. The
+// page token is the ID of the last user on the previous page, or its
+// listquery cursor when ordered by a field; callers should wrap it in an
+// opaque token before handing it to clients.
+func (m *MemoryStorage) ListUsers(ctx context.Context, opts storage.ListOptions) ([]*domain.User, string, error) {
+	order := opts.Order
+	var afterKey interface{}
+	afterID := opts.PageToken
+	if opts.PageToken != "" && order.Field != "" {
+		var err error
+		afterKey, afterID, err = order.ParseCursor(opts.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := make([]*domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		if user.IsDeleted() && !opts.ShowDeleted {
+			continue
+		}
+		if !opts.Filter.Match(user) {
+			continue
+		}
+		if afterID != "" && !order.After(user, afterKey, afterID) {
+			continue
+		}
+		matches = append(matches, user)
+	}
+	sort.Slice(matches, func(i, j int) bool { return order.Less(matches[i], matches[j]) })
+
+	nextToken := ""
+	if len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+		last := matches[len(matches)-1]
+		nextToken = last.ID
+		if order.Field != "" {
+			nextToken = order.Cursor(last)
+		}
+	}
+
+	users := make([]*domain.User, len(matches))
+	for i, user := range matches {
+		users[i] = clone(user)
+	}
+	return users, nextToken, nil
+}
+
+// ExportUsers calls fn for every matching user, ordered by ID. The users are
+// copied first, so fn runs without the lock held.
+func (m *MemoryStorage) ExportUsers(ctx context.Context, opts storage.ExportOptions, fn func(*domain.User) error) error {
+	m.mu.RLock()
+	users := make([]*domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		if opts.Match(user) {
+			users = append(users, clone(user))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SearchUsers ranks every user against the query; the in-memory backend
+// needs no index. As on the database backends, more than
+// search.MaxCandidates matches fail with domain.ErrSearchTooBroad.
+func (m *MemoryStorage) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]*domain.User, string, error) {
+	m.mu.RLock()
+	candidates := make([]*domain.User, 0, len(m.users))
+	for _, user := range m.users {
+		if user.IsDeleted() && !opts.ShowDeleted {
+			continue
+		}
+		candidates = append(candidates, clone(user))
+	}
+	m.mu.RUnlock()
+
+	return search.Rank(opts.Query, candidates, opts.Limit, opts.PageToken)
+}
+
+// CountUsers counts users that are not deleted, optionally only those in the
+// given blocked state
+func (m *MemoryStorage) CountUsers(ctx context.Context, blocked *bool) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var count int64
+	for _, user := range m.users {
+		if user.IsDeleted() {
+			continue
+		}
+		if blocked == nil || user.IsBlocked == *blocked {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CheckEmailExists checks if a canonical email exists
+func (m *MemoryStorage) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.byEmail[email]
+	return ok, nil
+}
+
+// CheckPhoneExists checks if phone exists
+func (m *MemoryStorage) CheckPhoneExists(ctx context.Context, phone string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.byPhone[phone]
+	return ok, nil
+}
+
+// record adds the change event, the audit record and the snapshot of a change
+// from before to after. The caller holds the write lock.
+func (m *MemoryStorage) record(ctx context.Context, before, after *domain.User) {
+	event := domain.NewUserEvent(before, after)
+	m.outbox = append(m.outbox, event)
+	m.audit[after.ID] = append(m.audit[after.ID], audit.NewRecord(ctx, event))
+	m.versions[after.ID] = append(m.versions[after.ID], clone(after))
+}
+
+// ListAuditRecords lists a user's audit records, newest first. The page
+// token is the ID of the last record on the previous page.
+func (m *MemoryStorage) ListAuditRecords(ctx context.Context, userID string, opts storage.AuditListOptions) ([]*domain.AuditRecord, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	records := m.audit[userID]
+	end := len(records)
+	if opts.PageToken != "" {
+		end = -1
+		for i, record := range records {
+			if record.ID == opts.PageToken {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+	}
+
+	var page []*domain.AuditRecord
+	for i := end - 1; i >= 0; i-- {
+		record := records[i]
+		if !opts.Since.IsZero() && record.OccurredAt.Before(opts.Since) {
+			continue
+		}
+		if !opts.Until.IsZero() && !record.OccurredAt.Before(opts.Until) {
+			continue
+		}
+		if len(page) == opts.Limit {
+			return page, page[len(page)-1].ID, nil
+		}
+		page = append(page, record)
+	}
+	return page, "", nil
+}
+
+// ListUserVersions lists a user's snapshots, newest first. The page token is
+// the version of the last snapshot on the previous page.
+func (m *MemoryStorage) ListUserVersions(ctx context.Context, userID string, opts storage.VersionListOptions) ([]*domain.User, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.versions[userID]
+	end := len(versions)
+	if opts.PageToken != "" {
+		last, err := strconv.ParseInt(opts.PageToken, 10, 64)
+		if err != nil {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+		end = sort.Search(len(versions), func(i int) bool { return versions[i].Version >= last })
+	}
+	start := end - opts.Limit
+	if start < 0 {
+		start = 0
+	}
+
+	page := make([]*domain.User, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		page = append(page, clone(versions[i]))
+	}
+
+	nextToken := ""
+	if start > 0 && len(page) > 0 {
+		nextToken = strconv.FormatInt(page[len(page)-1].Version, 10)
+	}
+	return page, nextToken, nil
+}
+
+// GetUserVersion returns the snapshot of a user at version
+func (m *MemoryStorage) GetUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.versions[userID]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].Version >= version })
+	if i == len(versions) || versions[i].Version != version {
+		return nil, domain.ErrVersionNotFound
+	}
+	return clone(versions[i]), nil
+}
+
+// GetUserAtTime returns the newest snapshot of a user updated at or before t
+func (m *MemoryStorage) GetUserAtTime(ctx context.Context, userID string, t time.Time) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	versions := m.versions[userID]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].UpdatedAt.After(t) {
+			return clone(versions[i]), nil
+		}
+	}
+	return nil, domain.ErrVersionNotFound
+}
+
+// PendingEvents returns up to limit pending events, oldest first
+func (m *MemoryStorage) PendingEvents(ctx context.Context, limit int) ([]*domain.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if limit > len(m.outbox) {
+		limit = len(m.outbox)
+	}
+	events := make([]*domain.Event, limit)
+	copy(events, m.outbox[:limit])
+	return events, nil
+}
+
+// AckEvents removes delivered events from the outbox
+func (m *MemoryStorage) AckEvents(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	acked := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		acked[id] = true
+	}
+	pending := m.outbox[:0]
+	for _, event := range m.outbox {
+		if !acked[event.ID] {
+			pending = append(pending, event)
+		}
+	}
+	clear(m.outbox[len(pending):])
+	m.outbox = pending
+	return nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+// clone returns a copy so callers can't mutate stored users in place
+func clone(user *domain.User) *domain.User {
+	u := *user
+	return &u
+}
@@ -0,0 +1,84 @@
+// internal/storage/postgres/postgres.go
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/sqlstore"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+var _ storage.Storage = (*PostgresDB)(nil)
+
+//go:embed schema.sql
+var schema string
+
+// Names of the unique constraints in schema.sql, used to tell which value
+// was a duplicate
+const (
+	emailConstraint = "users_email_canonical_key"
+	phoneConstraint = "users_phone_number_key"
+)
+
+var dialect = sqlstore.Dialect{
+	Placeholder: func(n int) string {
+		return "$" + strconv.Itoa(n)
+	},
+	ForUpdate:      " FOR UPDATE",
+	DuplicateError: duplicateError,
+}
+
+// PostgresDB stores users in PostgreSQL
+type PostgresDB struct {
+	*sqlstore.Store
+}
+
+// NewPostgresDB connects to PostgreSQL and creates the schema if it does not
+// exist yet
+func NewPostgresDB(dsn string, maxOpenConns int) (*PostgresDB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL: %w", err)
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxOpenConns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &PostgresDB{Store: sqlstore.New(db, dialect)}, nil
+}
+
+// duplicateError maps a unique violation to the matching domain error, or
+// returns nil if err is not one
+func duplicateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return nil
+	}
+	switch pgErr.ConstraintName {
+	case emailConstraint:
+		return domain.ErrEmailAlreadyExists
+	case phoneConstraint:
+		return domain.ErrPhoneAlreadyExists
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+CREATE TABLE IF NOT EXISTS users (
+    id text PRIMARY KEY,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    -- The last name folded by search.Fold, which ListUsers orders by. The C
+    -- collation compares bytes, as the other backends do.
+    last_name_key text COLLATE "C" NOT NULL,
+    gender text NOT NULL,
+    date_of_birth timestamptz NOT NULL,
+    phone_number text NOT NULL CONSTRAINT users_phone_number_key UNIQUE,
+    -- Uniqueness and lookups go by the canonical email, not the one entered
+    email text NOT NULL,
+    email_canonical text NOT NULL CONSTRAINT users_email_canonical_key UNIQUE,
+    is_blocked boolean NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL,
+    version bigint NOT NULL DEFAULT 1,
+    deleted_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- ListUsers orders by created_at or last_name, with the ID as tie-breaker
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS users_last_name_key_idx ON users (last_name_key, id);
+
+-- User change events waiting to be published by the event dispatcher. They
+-- are written in the same transaction as the change and deleted once
+-- published.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    seq bigserial PRIMARY KEY,
+    id text NOT NULL UNIQUE,
+    event_type text NOT NULL,
+    user_id text NOT NULL,
+    payload text NOT NULL,
+    occurred_at timestamptz NOT NULL
+);
+
+-- Audit trail of every change to a user, kept after the user is purged
+CREATE TABLE IF NOT EXISTS user_audit (
+    seq bigserial PRIMARY KEY,
+    id text NOT NULL UNIQUE,
+    user_id text NOT NULL,
+    action text NOT NULL,
+    method text NOT NULL,
+    actor text NOT NULL,
+    reason text NOT NULL,
+    request_id text NOT NULL,
+    changes text NOT NULL,
+    occurred_at timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_audit_user_id_idx ON user_audit (user_id, seq);
+
+-- Snapshot of a user after every change, removed when the user is purged
+CREATE TABLE IF NOT EXISTS user_versions (
+    user_id text NOT NULL,
+    version bigint NOT NULL,
+    updated_at timestamptz NOT NULL,
+    payload text NOT NULL,
+    PRIMARY KEY (user_id, version)
+);
+
+-- Search tokens of every user (see internal/search), written with every
+-- change and removed when the user is purged. Prefix searches are ranges on
+-- the primary key, which compares bytes under the C collation.
+CREATE TABLE IF NOT EXISTS user_search_tokens (
+    token text COLLATE "C" NOT NULL,
+    user_id text NOT NULL,
+    PRIMARY KEY (token, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_search_tokens_user_id_idx ON user_search_tokens (user_id);
@@ -0,0 +1,117 @@
+// internal/storage/scylla/audit.go
+package scylla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/gocql/gocql"
+)
+
+// change is what a write adds besides the user's rows: the outbox event, the
+// audit record and the snapshots. It is built once, so writing it again after a failed
+// batch rewrites the same rows.
+type change struct {
+	// id is the pending_changes row recorded for the change, if any
+	id     gocql.UUID
+	event  *domain.Event
+	record *domain.AuditRecord
+}
+
+func newChange(ctx context.Context, before, after *domain.User) *change {
+	event := domain.NewUserEvent(before, after)
+	return &change{event: event, record: audit.NewRecord(ctx, event)}
+}
+
+// addTo adds the outbox, audit, snapshot, search index and list table writes
+// to batch, and the removal of the change's pending_changes row
+func (c *change) addTo(batch *gocql.Batch) error {
+	if c.id != (gocql.UUID{}) {
+		batch.Query(`DELETE FROM pending_changes WHERE bucket = ? AND id = ?`, changeBucket(c.id.Time()), c.id)
+	}
+	if err := addEvent(batch, c.event); err != nil {
+		return err
+	}
+	if err := addVersions(batch, c.event.Before, c.event.After); err != nil {
+		return err
+	}
+	addSearchTokens(batch, c.event.Before, c.event.After)
+	addListRows(batch, c.event.Before, c.event.After)
+	changes, err := json.Marshal(c.record.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+	r := c.record
+	batch.Query(`INSERT INTO user_audit (user_id, id, action, method, actor, reason, request_id, changes, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.UserID, r.ID, string(r.Action), r.Method, r.Actor, r.Reason, r.RequestID, string(changes), r.OccurredAt)
+	return nil
+}
+
+// ListAuditRecords reads a user's audit records from their partition of
+// user_audit, newest first. The IDs are time-based UUIDs, so the time range
+// and the page token are both bounds on the clustering column. The page
+// token is the ID of the last record on the previous page.
+func (db *ScyllaDB) ListAuditRecords(ctx context.Context, userID string, opts storage.AuditListOptions) ([]*domain.AuditRecord, string, error) {
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	switch {
+	case opts.PageToken != "":
+		// The previous page already stayed below Until
+		cursor, err := gocql.ParseUUID(opts.PageToken)
+		if err != nil || cursor.Version() != 1 {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+		conditions = append(conditions, "id < ?")
+		args = append(args, cursor)
+	case !opts.Until.IsZero():
+		conditions = append(conditions, "id < minTimeuuid(?)")
+		args = append(args, opts.Until)
+	}
+	if !opts.Since.IsZero() {
+		conditions = append(conditions, "id >= minTimeuuid(?)")
+		args = append(args, opts.Since)
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, opts.Limit+1)
+
+	query := `SELECT id, user_id, action, method, actor, reason, request_id, changes, occurred_at
+		FROM user_audit WHERE ` + strings.Join(conditions, " AND ") + ` LIMIT ?`
+	iter := db.read(OpList, query, args...).WithContext(ctx).Iter()
+
+	var records []*domain.AuditRecord
+	var id gocql.UUID
+	var action, changes string
+	for {
+		var record domain.AuditRecord
+		if !iter.Scan(&id, &record.UserID, &action, &record.Method, &record.Actor,
+			&record.Reason, &record.RequestID, &changes, &record.OccurredAt) {
+			break
+		}
+		record.ID = id.String()
+		record.Action = domain.EventType(action)
+		if err := json.Unmarshal([]byte(changes), &record.Changes); err != nil {
+			iter.Close()
+			return nil, "", fmt.Errorf("failed to decode audit changes: %w", err)
+		}
+		records = append(records, &record)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to list audit records: %w", err)
+	}
+
+	nextToken := ""
+	if len(records) > opts.Limit {
+		records = records[:opts.Limit]
+		nextToken = records[opts.Limit-1].ID
+	}
+	return records, nextToken, nil
+}
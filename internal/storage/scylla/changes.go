@@ -0,0 +1,144 @@
+// internal/storage/scylla/changes.go
+package scylla
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/gocql/gocql"
+)
+
+// changeBucketSize is the span of IDs kept in one pending_changes partition
+const changeBucketSize = time.Hour
+
+// changeLookback is how far back the index retry job looks for interrupted
+// changes. An older one is still finished by the next write of its user.
+const changeLookback = 24 * time.Hour
+
+func changeBucket(t time.Time) time.Time {
+	return t.UTC().Truncate(changeBucketSize)
+}
+
+// pendingChange is the payload of a pending_changes row
+type pendingChange struct {
+	Event  *domain.Event       `json:"event"`
+	Record *domain.AuditRecord `json:"record"`
+}
+
+// recordChange writes c to pending_changes and gives it the ID the users row
+// write stores in change_id. Once the users row holds the ID, the change can
+// be finished from the recorded copy even if the process dies before its
+// batch is written.
+func (db *ScyllaDB) recordChange(ctx context.Context, c *change) error {
+	payload, err := json.Marshal(pendingChange{Event: c.event, Record: c.record})
+	if err != nil {
+		return fmt.Errorf("failed to encode change: %w", err)
+	}
+	c.id = gocql.TimeUUID()
+	query := `INSERT INTO pending_changes (bucket, id, user_id, version, payload) VALUES (?, ?, ?, ?, ?)`
+	if err := db.query(OpWrite, query, changeBucket(c.id.Time()), c.id, c.event.UserID,
+		c.event.After.Version, string(payload)).WithContext(ctx).Exec(); err != nil {
+		return fmt.Errorf("failed to record change: %w", err)
+	}
+	return nil
+}
+
+// discardChange removes the recorded copy of a change whose users row write
+// lost its version check. A failure only leaves the row for the index retry
+// job to drop.
+func (db *ScyllaDB) discardChange(ctx context.Context, id gocql.UUID) {
+	err := db.query(OpWrite, `DELETE FROM pending_changes WHERE bucket = ? AND id = ?`, changeBucket(id.Time()), id).
+		WithContext(context.WithoutCancel(ctx)).Exec()
+	if err != nil {
+		slog.Warn("Failed to discard pending change", "change_id", id, "error", err)
+	}
+}
+
+// finishChange writes the change with the given ID if it is still pending. A
+// write that moves a users row past a version calls it with the row's
+// change_id first: after that the change could no longer be told apart from
+// one that lost its version check.
+func (db *ScyllaDB) finishChange(ctx context.Context, id gocql.UUID) error {
+	if id == (gocql.UUID{}) {
+		return nil
+	}
+	var payload string
+	err := db.read(OpWrite, `SELECT payload FROM pending_changes WHERE bucket = ? AND id = ?`, changeBucket(id.Time()), id).
+		WithContext(ctx).Scan(&payload)
+	switch {
+	case err == gocql.ErrNotFound:
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read pending change: %w", err)
+	}
+	return db.replayChange(ctx, id, payload)
+}
+
+// replayChange writes a recorded change again. Its rows are keyed by the
+// event, audit record and version IDs it was recorded with, so this rewrites
+// the same rows the interrupted batch would have written.
+func (db *ScyllaDB) replayChange(ctx context.Context, id gocql.UUID, payload string) error {
+	var p pendingChange
+	if err := json.Unmarshal([]byte(payload), &p); err != nil {
+		return fmt.Errorf("failed to decode pending change: %w", err)
+	}
+	c := &change{id: id, event: p.Event, record: p.Record}
+	batch := db.newBatch(ctx, gocql.LoggedBatch)
+	if err := c.addTo(batch); err != nil {
+		return err
+	}
+	if err := db.executeLoggedBatch(batch); err != nil {
+		return fmt.Errorf("failed to finish change: %w", err)
+	}
+	slog.Warn("Finished interrupted user change",
+		"user_id", p.Event.UserID, "version", p.Event.After.Version, "event_id", p.Event.ID)
+	return nil
+}
+
+// finishChanges goes through the pending_changes rows of the last
+// changeLookback. A row whose ID is the change_id of its users row belongs to
+// an update that died after writing the row, and is finished. Any other row
+// belongs to an update that lost its version check or was reverted, and is
+// dropped. Rows younger than readRepairGrace may belong to an update still in
+// flight and are left alone.
+func (db *ScyllaDB) finishChanges(ctx context.Context) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-readRepairGrace)
+	query := `SELECT id, user_id, payload FROM pending_changes WHERE bucket = ? AND id < maxTimeuuid(?)`
+
+	finished := 0
+	for bucket := changeBucket(now.Add(-changeLookback)); !bucket.After(cutoff); bucket = bucket.Add(changeBucketSize) {
+		iter := db.query(OpList, query, bucket, cutoff).WithContext(ctx).Iter()
+		var id gocql.UUID
+		var userID, payload string
+		for iter.Scan(&id, &userID, &payload) {
+			_, current, err := db.getUserRow(ctx, OpWrite, userID)
+			if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+				iter.Close()
+				return finished, err
+			}
+			if err == nil && current == id {
+				if err := db.replayChange(ctx, id, payload); err != nil {
+					iter.Close()
+					return finished, err
+				}
+				finished++
+				continue
+			}
+			if err := db.query(OpWrite, `DELETE FROM pending_changes WHERE bucket = ? AND id = ?`, bucket, id).
+				WithContext(ctx).Exec(); err != nil {
+				iter.Close()
+				return finished, fmt.Errorf("failed to drop pending change: %w", err)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return finished, fmt.Errorf("failed to scan pending changes: %w", err)
+		}
+	}
+	return finished, nil
+}
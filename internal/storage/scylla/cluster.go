@@ -0,0 +1,240 @@
+// internal/storage/scylla/cluster.go
+package scylla
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// ClusterOptions describes how to connect to a ScyllaDB cluster
+type ClusterOptions struct {
+	Hosts    []string
+	Port     int
+	Keyspace string
+	// Consistency is the default consistency level, e.g. QUORUM or
+	// LOCAL_QUORUM. Scylla accepts EACH_QUORUM for writes only, so it can
+	// only be the default if every read operation overrides it.
+	Consistency string
+	// SerialConsistency is used by lightweight transactions: SERIAL or
+	// LOCAL_SERIAL
+	SerialConsistency string
+
+	Username string
+	Password string
+	TLS      TLSOptions
+
+	// LocalDC keeps queries in one datacenter. Empty means round robin
+	// over every host.
+	LocalDC string
+	// TokenAware sends each query to a replica that owns its partition
+	TokenAware bool
+
+	ProtoVersion int
+	// RetryPolicy is "none", "simple" or "exponential". RetryAttempts is
+	// the number of retries; the backoff bounds only apply to exponential.
+	RetryPolicy     string
+	RetryAttempts   int
+	RetryMinBackoff time.Duration
+	RetryMaxBackoff time.Duration
+	// SpeculativeAttempts is the number of extra attempts sent for reads
+	// that are slow to answer, SpeculativeDelay apart. Zero turns it off.
+	SpeculativeAttempts int
+	SpeculativeDelay    time.Duration
+
+	// NumConns is the number of connections per host
+	NumConns       int
+	ConnectTimeout time.Duration
+	// Timeout bounds each query
+	Timeout time.Duration
+
+	// ExportWorkers is the number of token ranges ExportUsers scans in
+	// parallel; zero means 8
+	ExportWorkers int
+
+	// Operations overrides the consistency level and adds a timeout per
+	// operation. Operations not listed use the defaults above.
+	Operations map[Operation]OperationOptions
+}
+
+// TLSOptions configures TLS to the cluster. CertFile and KeyFile are only
+// needed if the cluster requires client certificates.
+type TLSOptions struct {
+	Enabled            bool
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// NewClusterConfig builds the gocql cluster config for opts. It fails on any
+// unknown or inconsistent value, before anything is dialled.
+func NewClusterConfig(opts ClusterOptions) (*gocql.ClusterConfig, error) {
+	if len(opts.Hosts) == 0 {
+		return nil, errors.New("no ScyllaDB hosts configured")
+	}
+
+	consistency, err := ParseConsistency(opts.Consistency)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := operationSettings(opts); err != nil {
+		return nil, err
+	}
+	serial, err := parseSerialConsistency(opts.SerialConsistency)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ProtoVersion != 3 && opts.ProtoVersion != 4 {
+		return nil, fmt.Errorf("unsupported protocol version %d, expected 3 or 4", opts.ProtoVersion)
+	}
+	if opts.NumConns < 1 {
+		return nil, fmt.Errorf("num_conns must be at least 1, got %d", opts.NumConns)
+	}
+	if opts.ConnectTimeout <= 0 || opts.Timeout <= 0 {
+		return nil, errors.New("connect_timeout and timeout must be positive")
+	}
+	retryPolicy, err := newRetryPolicy(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SpeculativeAttempts < 0 || (opts.SpeculativeAttempts > 0 && opts.SpeculativeDelay <= 0) {
+		return nil, errors.New("speculative_attempts must not be negative and needs a positive speculative_delay")
+	}
+
+	cluster := gocql.NewCluster(opts.Hosts...)
+	cluster.Port = opts.Port
+	cluster.Keyspace = opts.Keyspace
+	cluster.Consistency = consistency
+	cluster.SerialConsistency = serial
+	cluster.ProtoVersion = opts.ProtoVersion
+	cluster.NumConns = opts.NumConns
+	cluster.ConnectTimeout = opts.ConnectTimeout
+	cluster.Timeout = opts.Timeout
+	cluster.RetryPolicy = retryPolicy
+
+	if opts.Username != "" || opts.Password != "" {
+		if opts.Username == "" || opts.Password == "" {
+			return nil, errors.New("ScyllaDB username and password must be set together")
+		}
+		cluster.Authenticator = gocql.PasswordAuthenticator{Username: opts.Username, Password: opts.Password}
+	}
+
+	if opts.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(opts.TLS)
+		if err != nil {
+			return nil, err
+		}
+		cluster.SslOpts = &gocql.SslOptions{Config: tlsConfig, EnableHostVerification: !opts.TLS.InsecureSkipVerify}
+	}
+
+	hostPolicy := gocql.RoundRobinHostPolicy()
+	if opts.LocalDC != "" {
+		hostPolicy = gocql.DCAwareRoundRobinPolicy(opts.LocalDC)
+	}
+	if opts.TokenAware {
+		hostPolicy = gocql.TokenAwareHostPolicy(hostPolicy)
+	}
+	cluster.PoolConfig.HostSelectionPolicy = hostPolicy
+
+	return cluster, nil
+}
+
+// ParseConsistency parses a consistency level name such as LOCAL_QUORUM
+func ParseConsistency(consistency string) (gocql.Consistency, error) {
+	switch strings.ToUpper(consistency) {
+	case "ANY":
+		return gocql.Any, nil
+	case "ONE":
+		return gocql.One, nil
+	case "TWO":
+		return gocql.Two, nil
+	case "THREE":
+		return gocql.Three, nil
+	case "QUORUM":
+		return gocql.Quorum, nil
+	case "ALL":
+		return gocql.All, nil
+	case "LOCAL_QUORUM":
+		return gocql.LocalQuorum, nil
+	case "EACH_QUORUM":
+		return gocql.EachQuorum, nil
+	case "LOCAL_ONE":
+		return gocql.LocalOne, nil
+	default:
+		return 0, fmt.Errorf("unknown consistency level %q", consistency)
+	}
+}
+
+func parseSerialConsistency(consistency string) (gocql.SerialConsistency, error) {
+	switch strings.ToUpper(consistency) {
+	case "SERIAL":
+		return gocql.Serial, nil
+	case "LOCAL_SERIAL":
+		return gocql.LocalSerial, nil
+	default:
+		return 0, fmt.Errorf("unknown serial consistency level %q, expected SERIAL or LOCAL_SERIAL", consistency)
+	}
+}
+
+func newRetryPolicy(opts ClusterOptions) (gocql.RetryPolicy, error) {
+	if opts.RetryAttempts < 0 {
+		return nil, fmt.Errorf("retry_attempts must not be negative, got %d", opts.RetryAttempts)
+	}
+	switch strings.ToLower(opts.RetryPolicy) {
+	case "none":
+		return &gocql.SimpleRetryPolicy{NumRetries: 0}, nil
+	case "simple":
+		return &gocql.SimpleRetryPolicy{NumRetries: opts.RetryAttempts}, nil
+	case "exponential":
+		if opts.RetryMinBackoff <= 0 || opts.RetryMaxBackoff < opts.RetryMinBackoff {
+			return nil, errors.New("exponential retries need 0 < retry_min_backoff <= retry_max_backoff")
+		}
+		return &gocql.ExponentialBackoffRetryPolicy{
+			NumRetries: opts.RetryAttempts,
+			Min:        opts.RetryMinBackoff,
+			Max:        opts.RetryMaxBackoff,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown retry policy %q, expected none, simple or exponential", opts.RetryPolicy)
+	}
+}
+
+func newTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, errors.New("TLS cert_file and key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,115 @@
+// internal/storage/scylla/export.go
+package scylla
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// defaultExportWorkers is used when ClusterOptions.ExportWorkers is unset
+	defaultExportWorkers = 8
+	// exportRangesPerWorker splits the ring finer than the number of
+	// workers, so a worker that finishes early picks up another range
+	exportRangesPerWorker = 4
+	// exportPageSize is the number of rows fetched per page of a range scan
+	exportPageSize = 1000
+)
+
+// tokenRange is an inclusive range of Murmur3 partition tokens
+type tokenRange struct {
+	start, end int64
+}
+
+// splitTokenRing divides the whole Murmur3 token ring into n contiguous
+// ranges
+func splitTokenRing(n int) []tokenRange {
+	step := math.MaxUint64 / uint64(n)
+	ranges := make([]tokenRange, n)
+	for i := range ranges {
+		// Offsets are counted from the lowest token; int64 arithmetic wraps,
+		// so adding them to MinInt64 lands on the right token
+		ranges[i].start = math.MinInt64 + int64(uint64(i)*step)
+		if i > 0 {
+			ranges[i-1].end = ranges[i].start - 1
+		}
+	}
+	ranges[n-1].end = math.MaxInt64
+	return ranges
+}
+
+// ExportUsers scans the users table in parallel token ranges and calls fn for
+// every matching user. fn is only called from the calling goroutine. The
+// filters are applied after reading, since none of them is part of the
+// partition key. Each page is bounded by the cluster timeout; the list
+// timeout would cut a full scan short.
+func (db *ScyllaDB) ExportUsers(ctx context.Context, opts storage.ExportOptions, fn func(*domain.User) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	all := splitTokenRing(db.exportWorkers * exportRangesPerWorker)
+	ranges := make(chan tokenRange, len(all))
+	for _, r := range all {
+		ranges <- r
+	}
+	close(ranges)
+
+	g, scanCtx := errgroup.WithContext(ctx)
+	users := make(chan *domain.User, exportPageSize)
+	for i := 0; i < db.exportWorkers; i++ {
+		g.Go(func() error {
+			for r := range ranges {
+				if err := db.scanTokenRange(scanCtx, r, opts, users); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	scanErr := make(chan error, 1)
+	go func() {
+		scanErr <- g.Wait()
+		close(users)
+	}()
+
+	for user := range users {
+		if err := fn(user); err != nil {
+			// Stop the scans and let the blocked ones see it
+			cancel()
+			for range users {
+			}
+			<-scanErr
+			return err
+		}
+	}
+	return <-scanErr
+}
+
+// scanTokenRange sends the matching users whose partition token falls in r
+func (db *ScyllaDB) scanTokenRange(ctx context.Context, r tokenRange, opts storage.ExportOptions, users chan<- *domain.User) error {
+	query := `SELECT ` + userColumns + ` FROM users WHERE token(id) >= ? AND token(id) <= ?`
+	iter := db.read(OpList, query, r.start, r.end).WithContext(ctx).PageSize(exportPageSize).Iter()
+
+	var user domain.User
+	for iter.Scan(userFields(&user)...) {
+		if !opts.Match(&user) {
+			continue
+		}
+		u := user
+		select {
+		case users <- &u:
+		case <-ctx.Done():
+			iter.Close()
+			return ctx.Err()
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf("failed to scan token range [%d, %d]: %w", r.start, r.end, err)
+	}
+	return nil
+}
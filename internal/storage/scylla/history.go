@@ -0,0 +1,123 @@
+// internal/storage/scylla/history.go
+package scylla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/gocql/gocql"
+)
+
+// addVersions adds the snapshots of before and after to batch. Rewriting the
+// snapshot of before stores the same row again, and fills it in for users
+// last changed before snapshots were kept.
+func addVersions(batch *gocql.Batch, before, after *domain.User) error {
+	for _, user := range []*domain.User{before, after} {
+		if user == nil {
+			continue
+		}
+		payload, err := json.Marshal(user)
+		if err != nil {
+			return fmt.Errorf("failed to encode user version: %w", err)
+		}
+		batch.Query(`INSERT INTO user_versions (user_id, version, updated_at, payload) VALUES (?, ?, ?, ?)`,
+			user.ID, user.Version, user.UpdatedAt, string(payload))
+	}
+	return nil
+}
+
+// ListUserVersions reads a user's snapshots from their partition of
+// user_versions, newest first. The page token is the version of the last
+// snapshot on the previous page.
+func (db *ScyllaDB) ListUserVersions(ctx context.Context, userID string, opts storage.VersionListOptions) ([]*domain.User, string, error) {
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	query := `SELECT payload FROM user_versions WHERE user_id = ?`
+	args := []interface{}{userID}
+	if opts.PageToken != "" {
+		last, err := strconv.ParseInt(opts.PageToken, 10, 64)
+		if err != nil {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+		query += ` AND version < ?`
+		args = append(args, last)
+	}
+	// One extra row tells whether there is a next page
+	query += ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	iter := db.read(OpList, query, args...).WithContext(ctx).Iter()
+	var versions []*domain.User
+	var payload string
+	for iter.Scan(&payload) {
+		user, err := decodeVersion(payload)
+		if err != nil {
+			iter.Close()
+			return nil, "", err
+		}
+		versions = append(versions, user)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to list user versions: %w", err)
+	}
+
+	nextToken := ""
+	if len(versions) > opts.Limit {
+		versions = versions[:opts.Limit]
+		nextToken = strconv.FormatInt(versions[opts.Limit-1].Version, 10)
+	}
+	return versions, nextToken, nil
+}
+
+// GetUserVersion returns the snapshot of a user at version
+func (db *ScyllaDB) GetUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error) {
+	ctx, cancel := db.withTimeout(ctx, OpRead)
+	defer cancel()
+
+	var payload string
+	query := `SELECT payload FROM user_versions WHERE user_id = ? AND version = ?`
+	if err := db.read(OpRead, query, userID, version).WithContext(ctx).Scan(&payload); err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, domain.ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to get user version: %w", err)
+	}
+	return decodeVersion(payload)
+}
+
+// GetUserAtTime returns the newest snapshot of a user updated at or before t.
+// updated_at is not part of the key, so it walks the user's partition from
+// the newest version down.
+func (db *ScyllaDB) GetUserAtTime(ctx context.Context, userID string, t time.Time) (*domain.User, error) {
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	query := `SELECT updated_at, payload FROM user_versions WHERE user_id = ?`
+	iter := db.read(OpList, query, userID).WithContext(ctx).Iter()
+	var updatedAt time.Time
+	var payload string
+	for iter.Scan(&updatedAt, &payload) {
+		if !updatedAt.After(t) {
+			iter.Close()
+			return decodeVersion(payload)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to get user version: %w", err)
+	}
+	return nil, domain.ErrVersionNotFound
+}
+
+func decodeVersion(payload string) (*domain.User, error) {
+	var user domain.User
+	if err := json.Unmarshal([]byte(payload), &user); err != nil {
+		return nil, fmt.Errorf("failed to decode user version: %w", err)
+	}
+	return &user, nil
+}
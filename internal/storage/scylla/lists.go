@@ -0,0 +1,435 @@
+// internal/storage/scylla/lists.go
+package scylla
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/gocql/gocql"
+)
+
+// listFetchSize is the number of rows read per page of a list table
+const listFetchSize = 100
+
+// listScanLimit is about the most rows one ListUsers call reads to fill a
+// page. A filter that few users match gets a short or empty page and a token
+// to go on from instead of a scan of the whole table.
+const listScanLimit = 1000
+
+// monthLayout formats the buckets of users_by_created_at
+const monthLayout = "2006-01"
+
+// Buckets of users_by_last_name are the first lastNamePrefixLength
+// characters of the folded last name, each split into lastNameShards shards
+// by a hash of the user ID
+const (
+	lastNamePrefixLength = 2
+	lastNameShards       = 8
+)
+
+// listTable is a copy of users for one ListUsers order. Rows are clustered by
+// the key column and the ID within buckets, and the buckets in use are
+// listed in user_list_buckets under the table name. A bucket belongs to a
+// group; groups sort in the same order as the keys in them, and the buckets
+// of one group are shards whose rows are merged when read.
+type listTable struct {
+	name  string
+	order listquery.Order
+	// column is the clustering column holding order.Key of the user
+	column string
+	// group returns the bucket group of a value of column
+	group func(key interface{}) string
+	// shards is the number of buckets a group is split into, 1 if it isn't
+	shards int
+}
+
+var (
+	createdAtList = listTable{
+		name:   "users_by_created_at",
+		order:  listquery.Order{Field: listquery.FieldCreatedAt},
+		column: "created_at",
+		group: func(key interface{}) string {
+			return key.(time.Time).UTC().Format(monthLayout)
+		},
+		shards: 1,
+	}
+	lastNameList = listTable{
+		name:   "users_by_last_name",
+		order:  listquery.Order{Field: listquery.FieldLastName},
+		column: "last_name_key",
+		group: func(key interface{}) string {
+			prefix := []rune(key.(string))
+			return string(prefix[:min(len(prefix), lastNamePrefixLength)])
+		},
+		shards: lastNameShards,
+	}
+	listTables = []listTable{createdAtList, lastNameList}
+)
+
+// bucket returns the bucket of the row with the given key value and user ID
+func (t listTable) bucket(key interface{}, id string) string {
+	group := t.group(key)
+	if t.shards <= 1 {
+		return group
+	}
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return group + "/" + strconv.Itoa(int(h.Sum32()%uint32(t.shards)))
+}
+
+// bucketGroup returns the group of a bucket
+func (t listTable) bucketGroup(bucket string) string {
+	if t.shards <= 1 {
+		return bucket
+	}
+	if i := strings.LastIndexByte(bucket, '/'); i >= 0 {
+		return bucket[:i]
+	}
+	return bucket
+}
+
+// compareKeys orders two values of a key column as they are stored.
+// Timestamps are stored in milliseconds.
+func compareKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return cmp.Compare(a.UnixMilli(), b.(time.Time).UnixMilli())
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+const insertListBucketQuery = `INSERT INTO user_list_buckets (list, bucket) VALUES (?, ?)`
+
+// userValues returns the values of userColumns for user
+func userValues(user *domain.User) []interface{} {
+	return []interface{}{
+		user.ID,
+		user.FirstName,
+		user.LastName,
+		user.Gender,
+		user.DateOfBirth,
+		user.PhoneNumber,
+		user.Email,
+		user.EmailKey(),
+		user.IsBlocked,
+		user.CreatedAt,
+		user.UpdatedAt,
+		user.Version,
+		nullTime(user.DeletedAt),
+	}
+}
+
+// addListRows adds the list table writes of a change from before to after to
+// batch. A user whose key changes is moved to its new row.
+func addListRows(batch *gocql.Batch, before, after *domain.User) {
+	for _, t := range listTables {
+		key := t.order.Key(after)
+		bucket := t.bucket(key, after.ID)
+		moved := before == nil
+		if before != nil {
+			oldKey := t.order.Key(before)
+			if oldBucket := t.bucket(oldKey, before.ID); oldBucket != bucket || compareKeys(oldKey, key) != 0 {
+				batch.Query(`DELETE FROM `+t.name+` WHERE bucket = ? AND `+t.column+` = ? AND id = ?`,
+					oldBucket, oldKey, before.ID)
+				moved = true
+			}
+		}
+		columns, values := `bucket, `+userColumns, append([]interface{}{bucket}, userValues(after)...)
+		if t.column != t.order.Field {
+			columns, values = columns+`, `+t.column, append(values, key)
+		}
+		batch.Query(`INSERT INTO `+t.name+` (`+columns+`) VALUES (`+placeholders(len(values))+`)`, values...)
+		if moved {
+			batch.Query(insertListBucketQuery, t.name, bucket)
+		}
+	}
+}
+
+// deleteListRows adds the deletes of a purged user's list table rows to batch
+func deleteListRows(batch *gocql.Batch, user *domain.User) {
+	for _, t := range listTables {
+		key := t.order.Key(user)
+		batch.Query(`DELETE FROM `+t.name+` WHERE bucket = ? AND `+t.column+` = ? AND id = ?`,
+			t.bucket(key, user.ID), key, user.ID)
+	}
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// listFromTable lists users in the order of t, in the direction of
+// opts.Order, walking its bucket groups from the one holding the page token
+// and merging the shards of each. A created_at range only reads the buckets
+// and rows inside it; every other filter is applied after reading. After
+// listScanLimit rows the page ends where the reading stopped, even if it
+// holds fewer than opts.Limit users.
+func (db *ScyllaDB) listFromTable(ctx context.Context, t listTable, opts storage.ListOptions) ([]*domain.User, string, error) {
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	order := t.order
+	order.Desc = opts.Order.Desc
+	var afterKey interface{}
+	var afterID, afterGroup string
+	if opts.PageToken != "" {
+		var err error
+		afterKey, afterID, err = order.ParseCursor(opts.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		afterGroup = t.group(afterKey)
+	}
+
+	groups, err := db.listGroups(ctx, t)
+	if err != nil {
+		return nil, "", err
+	}
+	if order.Desc {
+		slices.Reverse(groups)
+	}
+
+	created := opts.Filter.CreatedAt
+	if t.name != createdAtList.name {
+		created = listquery.Range{}
+	}
+	op, direction := ">", ""
+	if order.Desc {
+		op, direction = "<", " ORDER BY "+t.column+" DESC, id DESC"
+	}
+
+	users := make([]*domain.User, 0, opts.Limit+1)
+	var last *domain.User
+	scanned := 0
+	for _, group := range groups {
+		if afterID != "" && ((!order.Desc && group.name < afterGroup) || (order.Desc && group.name > afterGroup)) {
+			continue
+		}
+		if t.name == createdAtList.name && !monthOverlaps(group.name, created) {
+			continue
+		}
+
+		// A slice has at most one bound on each side; the page token is
+		// already inside the range
+		var lower, upper []interface{}
+		lowerCond, upperCond := "", ""
+		if !created.From.IsZero() {
+			lowerCond, lower = "(created_at) >= (?)", []interface{}{ceilMillis(created.From)}
+		}
+		if !created.To.IsZero() {
+			upperCond, upper = "(created_at) < (?)", []interface{}{ceilMillis(created.To)}
+		}
+		if afterID != "" && group.name == afterGroup {
+			cond := fmt.Sprintf("(%s, id) %s (?, ?)", t.column, op)
+			if order.Desc {
+				upperCond, upper = cond, []interface{}{afterKey, afterID}
+			} else {
+				lowerCond, lower = cond, []interface{}{afterKey, afterID}
+			}
+		}
+		conditions := []string{"bucket = ?"}
+		var args []interface{}
+		if lowerCond != "" {
+			conditions = append(conditions, lowerCond)
+			args = append(args, lower...)
+		}
+		if upperCond != "" {
+			conditions = append(conditions, upperCond)
+			args = append(args, upper...)
+		}
+
+		query := `SELECT ` + userColumns + ` FROM ` + t.name + ` WHERE ` + strings.Join(conditions, " AND ") + direction
+		err := db.mergeBuckets(ctx, t, order.Desc, query, group.buckets, args, func(user *domain.User) bool {
+			last = user
+			scanned++
+			if (user.IsDeleted() && !opts.ShowDeleted) || !opts.Filter.Match(user) {
+				return scanned < listScanLimit
+			}
+			users = append(users, user)
+			return len(users) <= opts.Limit && scanned < listScanLimit
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list users: %w", err)
+		}
+		if len(users) > opts.Limit || scanned >= listScanLimit {
+			break
+		}
+	}
+
+	// The extra user tells whether there is a next page; without one, a
+	// page cut short by the scan limit goes on after the last row read
+	nextPageToken := ""
+	switch {
+	case len(users) > opts.Limit:
+		users = users[:opts.Limit]
+		nextPageToken = order.Cursor(users[len(users)-1])
+	case scanned >= listScanLimit:
+		nextPageToken = order.Cursor(last)
+	}
+	return users, nextPageToken, nil
+}
+
+// listShard is the next row of one bucket being merged
+type listShard struct {
+	iter *gocql.Iter
+	user *domain.User
+	key  interface{}
+}
+
+func (s *listShard) next(t listTable) {
+	var user domain.User
+	if !s.iter.Scan(userFields(&user)...) {
+		s.user = nil
+		return
+	}
+	s.user, s.key = &user, t.order.Key(&user)
+}
+
+// mergeBuckets runs query, whose first condition is on the bucket, on each
+// of buckets, and calls fn with their rows merged in order until fn returns
+// false or the rows run out
+func (db *ScyllaDB) mergeBuckets(ctx context.Context, t listTable, desc bool, query string, buckets []string,
+	args []interface{}, fn func(*domain.User) bool) error {
+	shards := make([]*listShard, len(buckets))
+	for i, bucket := range buckets {
+		iter := db.read(OpList, query, append([]interface{}{bucket}, args...)...).
+			WithContext(ctx).PageSize(listFetchSize).Iter()
+		shards[i] = &listShard{iter: iter}
+		shards[i].next(t)
+	}
+
+	for {
+		var first *listShard
+		for _, shard := range shards {
+			if shard.user == nil {
+				continue
+			}
+			if first == nil {
+				first = shard
+				continue
+			}
+			c := compareKeys(shard.key, first.key)
+			if c == 0 {
+				c = strings.Compare(shard.user.ID, first.user.ID)
+			}
+			if (c < 0) != desc && c != 0 {
+				first = shard
+			}
+		}
+		if first == nil {
+			break
+		}
+		user := first.user
+		first.next(t)
+		if !fn(user) {
+			break
+		}
+	}
+
+	var err error
+	for _, shard := range shards {
+		if cerr := shard.iter.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// listGroup is a bucket group of a list table and its buckets
+type listGroup struct {
+	name    string
+	buckets []string
+}
+
+// listGroups returns the bucket groups of t in ascending order
+func (db *ScyllaDB) listGroups(ctx context.Context, t listTable) ([]listGroup, error) {
+	buckets, err := db.listBuckets(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	var groups []listGroup
+	index := make(map[string]int)
+	for _, bucket := range buckets {
+		name := t.bucketGroup(bucket)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, listGroup{name: name})
+		}
+		groups[i].buckets = append(groups[i].buckets, bucket)
+	}
+	// Bucket names only sort like their groups without shards: "a-/0" comes
+	// before "a/0"
+	slices.SortFunc(groups, func(a, b listGroup) int { return strings.Compare(a.name, b.name) })
+	return groups, nil
+}
+
+// listBuckets returns the buckets of t in ascending order
+func (db *ScyllaDB) listBuckets(ctx context.Context, t listTable) ([]string, error) {
+	iter := db.read(OpList, `SELECT bucket FROM user_list_buckets WHERE list = ?`, t.name).WithContext(ctx).Iter()
+	var buckets []string
+	var bucket string
+	for iter.Scan(&bucket) {
+		buckets = append(buckets, bucket)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read list buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+// monthOverlaps reports whether any time in the month bucket lies in r
+func monthOverlaps(bucket string, r listquery.Range) bool {
+	start, err := time.Parse(monthLayout, bucket)
+	if err != nil {
+		return true
+	}
+	end := start.AddDate(0, 1, 0)
+	if !r.From.IsZero() && !end.After(r.From) {
+		return false
+	}
+	if !r.To.IsZero() && !start.Before(r.To) {
+		return false
+	}
+	return true
+}
+
+// ceilMillis rounds t up to the millisecond. Stored timestamps are whole
+// milliseconds, so a stored time is at or after t exactly when it is at or
+// after ceilMillis(t).
+func ceilMillis(t time.Time) time.Time {
+	truncated := t.Truncate(time.Millisecond)
+	if truncated.Before(t) {
+		return truncated.Add(time.Millisecond)
+	}
+	return truncated
+}
+
+// RebuildListTables writes the list table rows of every user, deleted ones
+// included, for users created before the tables existed. Rows are
+// overwritten, so it is safe to run again.
+func (db *ScyllaDB) RebuildListTables(ctx context.Context) (int, error) {
+	written := 0
+	err := db.ExportUsers(ctx, storage.ExportOptions{ShowDeleted: true}, func(user *domain.User) error {
+		batch := db.newBatch(ctx, gocql.UnloggedBatch)
+		addListRows(batch, nil, user)
+		if err := db.session.Load().ExecuteBatch(batch); err != nil {
+			return fmt.Errorf("failed to write list rows of user %s: %w", user.ID, err)
+		}
+		written++
+		return nil
+	})
+	return written, err
+}
@@ -0,0 +1,444 @@
+// internal/storage/scylla/migrate.go
+package scylla
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/google/uuid"
+)
+
+//go:embed migrations/*.cql
+var migrationFiles embed.FS
+
+const (
+	// migrationLockTTL bounds how long a crashed runner can block others
+	migrationLockTTL = 10 * time.Minute
+	// migrationLockRetry is how often a runner checks whether the lock is free
+	migrationLockRetry = 2 * time.Second
+)
+
+// Migration is one versioned schema change. Up and Down hold the individual
+// CQL statements, in the order they run.
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Replication holds the keyspace replication settings. With DataCenters set,
+// each data center gets its own replication factor and Factor is ignored.
+type Replication struct {
+	Class       string
+	Factor      int
+	DataCenters map[string]int
+}
+
+// cql renders the replication map of a CREATE KEYSPACE statement
+func (r Replication) cql() (string, error) {
+	switch r.Class {
+	case "SimpleStrategy", "NetworkTopologyStrategy":
+	default:
+		return "", fmt.Errorf("unknown replication class %q", r.Class)
+	}
+
+	options := []string{fmt.Sprintf("'class': '%s'", r.Class)}
+	if len(r.DataCenters) > 0 && r.Class == "NetworkTopologyStrategy" {
+		dcs := make([]string, 0, len(r.DataCenters))
+		for dc := range r.DataCenters {
+			dcs = append(dcs, dc)
+		}
+		sort.Strings(dcs)
+		for _, dc := range dcs {
+			options = append(options, fmt.Sprintf("'%s': %d", strings.ReplaceAll(dc, "'", "''"), r.DataCenters[dc]))
+		}
+	} else {
+		if r.Factor < 1 {
+			return "", fmt.Errorf("replication factor must be at least 1, got %d", r.Factor)
+		}
+		options = append(options, fmt.Sprintf("'replication_factor': %d", r.Factor))
+	}
+	return "{" + strings.Join(options, ", ") + "}", nil
+}
+
+// Migrations returns the embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations")
+}
+
+// loadMigrations reads NNNN_name.up.cql and NNNN_name.down.cql pairs from dir.
+// Versions must start at 1 and have no gaps, so two branches adding the same
+// version fail on load instead of half-applying.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.cql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.cql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected file in migrations: %s", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".cql")
+		prefix, label, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s must be named NNNN_name.%s.cql", name, direction)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		}
+		if m.Name != label {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = splitStatements(string(data))
+		} else {
+			m.Down = splitStatements(string(data))
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %d is missing", version)
+		}
+		if len(m.Up) == 0 {
+			return nil, fmt.Errorf("migration %d has no up statements", version)
+		}
+		migrations = append(migrations, *m)
+	}
+	return migrations, nil
+}
+
+// splitStatements splits a CQL script on semicolons, dropping comments and
+// blank statements. Semicolons inside string literals are left alone.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	inString := false
+
+	for _, line := range strings.Split(script, "\n") {
+		if !inString {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+		}
+		for _, r := range line {
+			switch {
+			case r == '\'':
+				inString = !inString
+			case r == ';' && !inString:
+				if stmt := strings.TrimSpace(current.String()); stmt != "" {
+					statements = append(statements, stmt)
+				}
+				current.Reset()
+				continue
+			}
+			current.WriteRune(r)
+		}
+		current.WriteByte('\n')
+	}
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+// Migrator applies the embedded migrations to a keyspace and records them in
+// its schema_migrations table
+type Migrator struct {
+	session    *gocql.Session
+	migrations []Migration
+	owner      string
+}
+
+// NewMigrator creates the keyspace if needed and connects to it. Schema
+// changes are slow, so queries get at least 30 seconds whatever opts says.
+func NewMigrator(opts ClusterOptions, replication Replication) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	keyspace := opts.Keyspace
+	if !validIdentifier(keyspace) {
+		return nil, fmt.Errorf("invalid keyspace name %q", keyspace)
+	}
+	replicationCQL, err := replication.cql()
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Keyspace = ""
+	cluster, err := NewClusterConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if cluster.Timeout < 30*time.Second {
+		cluster.Timeout = 30 * time.Second
+	}
+
+	// The keyspace may not exist yet, so create it from a session that is
+	// not bound to one
+	session, err := cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ScyllaDB: %w", err)
+	}
+	query := fmt.Sprintf(`CREATE KEYSPACE IF NOT EXISTS %s WITH replication = %s`, keyspace, replicationCQL)
+	err = session.Query(query).Exec()
+	session.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keyspace: %w", err)
+	}
+
+	cluster.Keyspace = keyspace
+	session, err = cluster.CreateSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ScyllaDB: %w", err)
+	}
+
+	m := &Migrator{session: session, migrations: migrations, owner: uuid.New().String()}
+	if err := m.createTables(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Migrator) createTables() error {
+	for _, query := range []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version int PRIMARY KEY,
+			name text,
+			applied_at timestamp
+		)`,
+		`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id text PRIMARY KEY,
+			owner text
+		)`,
+	} {
+		if err := m.session.Query(query).Exec(); err != nil {
+			return fmt.Errorf("failed to create migration tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses[i] = MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in order and returns the ones it ran
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+			if err := m.run(ctx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			query := `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`
+			if err := m.session.Query(query, migration.Version, migration.Name, time.Now()).
+				WithContext(ctx).Exec(); err != nil {
+				return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down rolls back the latest steps applied migrations and returns the ones
+// it reverted. Rolling back drops data, so callers are expected to guard it.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("steps must be at least 1, got %d", steps)
+	}
+
+	var reverted []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if len(migration.Down) == 0 {
+				return fmt.Errorf("migration %d (%s) cannot be rolled back", migration.Version, migration.Name)
+			}
+			slog.Warn("Rolling back migration", "version", migration.Version, "name", migration.Name)
+			if err := m.run(ctx, migration.Down); err != nil {
+				return fmt.Errorf("rollback of migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+			}
+			query := `DELETE FROM schema_migrations WHERE version = ?`
+			if err := m.session.Query(query, migration.Version).WithContext(ctx).Exec(); err != nil {
+				return fmt.Errorf("failed to record rollback of migration %d: %w", migration.Version, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Close closes the migrator's session
+func (m *Migrator) Close() {
+	m.session.Close()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	iter := m.session.Query(`SELECT version, applied_at FROM schema_migrations`).WithContext(ctx).Iter()
+	var version int
+	var appliedAt time.Time
+	for iter.Scan(&version, &appliedAt) {
+		applied[version] = appliedAt
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// run executes statements in order. Adding a column the table already has
+// counts as done, since CQL has no ADD IF NOT EXISTS: keyspaces set up
+// before migrations may have some of the columns later migrations add, and a
+// migration that failed part way through is run again from the start.
+func (m *Migrator) run(ctx context.Context, statements []string) error {
+	for _, stmt := range statements {
+		err := m.session.Query(stmt).WithContext(ctx).Exec()
+		if err != nil && addsColumn(stmt) && isExistingColumn(err) {
+			slog.Info("Column already exists", "statement", stmt)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addsColumn reports whether stmt is an ALTER TABLE ... ADD
+func addsColumn(stmt string) bool {
+	words := strings.Fields(strings.ToUpper(stmt))
+	return len(words) > 3 && words[0] == "ALTER" && words[1] == "TABLE" && words[3] == "ADD"
+}
+
+// isExistingColumn reports whether err is Scylla refusing to add a column
+// that is already there
+func isExistingColumn(err error) bool {
+	var reqErr gocql.RequestError
+	return errors.As(err, &reqErr) && reqErr.Code() == gocql.ErrCodeInvalid &&
+		strings.Contains(reqErr.Message(), "conflicts with an existing column")
+}
+
+// withLock runs fn while holding the migration lock, so replicas starting
+// together don't apply the same migration twice. It waits for the lock until
+// ctx is done. The lock row expires on its own if the holder dies.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	query := `INSERT INTO schema_migrations_lock (id, owner) VALUES ('lock', ?) IF NOT EXISTS USING TTL ?`
+	for {
+		existing := make(map[string]interface{})
+		applied, err := m.session.Query(query, m.owner, int(migrationLockTTL.Seconds())).
+			WithContext(ctx).MapScanCAS(existing)
+		if err != nil {
+			return fmt.Errorf("failed to take migration lock: %w", err)
+		}
+		if applied {
+			break
+		}
+
+		slog.Info("Waiting for migration lock", "owner", existing["owner"])
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("migrations are locked by another runner (%v): %w", existing["owner"], ctx.Err())
+		case <-time.After(migrationLockRetry):
+		}
+	}
+	defer func() {
+		query := `DELETE FROM schema_migrations_lock WHERE id = 'lock' IF owner = ?`
+		if _, err := m.session.Query(query, m.owner).WithContext(context.WithoutCancel(ctx)).
+			MapScanCAS(make(map[string]interface{})); err != nil {
+			slog.Warn("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	return fn()
+}
+
+// validIdentifier reports whether name can be used unquoted in CQL
+func validIdentifier(name string) bool {
+	if name == "" || len(name) > 48 {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+// internal/storage/scylla/operations.go
+package scylla
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// Operation groups the queries that share a consistency level and timeout
+type Operation string
+
+const (
+	// OpRead covers GetUserByID, GetUserByEmail, GetUserByPhone and
+	// CountUsers
+	OpRead Operation = "read"
+	// OpWrite covers every write: users rows, lookup claims and releases,
+	// logged batches and counters
+	OpWrite Operation = "write"
+	// OpList covers ListUsers and the full-table scans of the background jobs
+	OpList Operation = "list"
+	// OpCheck covers CheckEmailExists and CheckPhoneExists, which back the
+	// uniqueness checks
+	OpCheck Operation = "check"
+)
+
+var operations = []Operation{OpRead, OpWrite, OpList, OpCheck}
+
+// OperationOptions overrides the cluster defaults for one operation. An
+// empty Consistency uses ClusterOptions.Consistency. A zero Timeout adds no
+// deadline beyond the per-query ClusterOptions.Timeout; otherwise it bounds
+// the whole method, across all the queries it makes. The background jobs'
+// scans are not bound by it.
+type OperationOptions struct {
+	Consistency string
+	Timeout     time.Duration
+}
+
+type opSettings struct {
+	consistency gocql.Consistency
+	// readConsistency is used for reads made as part of the operation. It
+	// differs only for EACH_QUORUM writes, whose rows are read back at
+	// LOCAL_QUORUM; that still overlaps a quorum in every datacenter.
+	readConsistency gocql.Consistency
+	timeout         time.Duration
+}
+
+// operationSettings resolves the settings of every operation from opts
+func operationSettings(opts ClusterOptions) (map[Operation]opSettings, error) {
+	for op := range opts.Operations {
+		if !knownOperation(op) {
+			return nil, fmt.Errorf("unknown ScyllaDB operation %q", op)
+		}
+	}
+
+	settings := make(map[Operation]opSettings, len(operations))
+	for _, op := range operations {
+		override := opts.Operations[op]
+		name := opts.Consistency
+		if override.Consistency != "" {
+			name = override.Consistency
+		}
+		consistency, err := ParseConsistency(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s consistency: %w", op, err)
+		}
+		// Scylla rejects reads at EACH_QUORUM
+		if consistency == gocql.EachQuorum && op != OpWrite {
+			return nil, fmt.Errorf("%s consistency: EACH_QUORUM is only valid for writes", op)
+		}
+		if override.Timeout < 0 {
+			return nil, fmt.Errorf("%s timeout must not be negative", op)
+		}
+		readConsistency := consistency
+		if consistency == gocql.EachQuorum {
+			readConsistency = gocql.LocalQuorum
+		}
+		settings[op] = opSettings{consistency: consistency, readConsistency: readConsistency, timeout: override.Timeout}
+	}
+	return settings, nil
+}
+
+func knownOperation(op Operation) bool {
+	for _, known := range operations {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// query builds a query at the consistency level of op
+func (db *ScyllaDB) query(op Operation, stmt string, values ...interface{}) *gocql.Query {
+	return db.session.Load().Query(stmt, values...).Consistency(db.ops[op].consistency)
+}
+
+// read builds a read-only query, marked idempotent so the speculative
+// execution policy applies to it
+func (db *ScyllaDB) read(op Operation, stmt string, values ...interface{}) *gocql.Query {
+	return db.session.Load().Query(stmt, values...).
+		Consistency(db.ops[op].readConsistency).
+		Idempotent(true).
+		SetSpeculativeExecutionPolicy(db.speculative)
+}
+
+// newBatch starts a batch at the write consistency level
+func (db *ScyllaDB) newBatch(ctx context.Context, typ gocql.BatchType) *gocql.Batch {
+	batch := db.session.Load().NewBatch(typ).WithContext(ctx)
+	batch.SetConsistency(db.ops[OpWrite].consistency)
+	return batch
+}
+
+// withTimeout applies the timeout configured for op, if any
+func (db *ScyllaDB) withTimeout(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	if timeout := db.ops[op].timeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
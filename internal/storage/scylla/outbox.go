@@ -0,0 +1,150 @@
+// internal/storage/scylla/outbox.go
+package scylla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/gocql/gocql"
+)
+
+var _ storage.Outbox = (*ScyllaDB)(nil)
+
+// outboxBucketSize is the span of event IDs kept in one outbox partition
+const outboxBucketSize = 5 * time.Minute
+
+// outboxBucketGrace is how long after its end a bucket may still receive
+// events, from batches the cluster replays from its batch log. An empty
+// bucket is only dropped from outbox_buckets after that.
+const outboxBucketGrace = 10 * time.Minute
+
+func outboxBucket(t time.Time) time.Time {
+	return t.UTC().Truncate(outboxBucketSize)
+}
+
+// addEvent adds the outbox write for event to batch, together with the
+// listing of its bucket. Writing it again with the same ID is harmless, so a
+// batch retried after a timeout can't duplicate it.
+func addEvent(batch *gocql.Batch, event *domain.Event) error {
+	id, err := gocql.ParseUUID(event.ID)
+	if err != nil || id.Version() != 1 {
+		return fmt.Errorf("event ID %q is not a time-based UUID", event.ID)
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	bucket := outboxBucket(id.Time())
+	batch.Query(`INSERT INTO outbox (bucket, id, event_type, user_id, payload, occurred_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		bucket, id, string(event.Type), event.UserID, string(payload), event.OccurredAt)
+	batch.Query(`INSERT INTO outbox_buckets (shard, bucket) VALUES (0, ?)`, bucket)
+	return nil
+}
+
+// PendingEvents returns up to limit pending events, oldest first. It reads
+// the buckets listed in outbox_buckets, oldest first, until it has limit
+// events, and removes buckets that are empty and past outboxBucketGrace from
+// the listing. Events of one user returned together are ordered by version,
+// which unlike the timestamp can't be skewed by clocks.
+func (db *ScyllaDB) PendingEvents(ctx context.Context, limit int) ([]*domain.Event, error) {
+	var buckets []time.Time
+	iter := db.query(OpList, `SELECT bucket FROM outbox_buckets WHERE shard = 0`).WithContext(ctx).Iter()
+	var bucket time.Time
+	for iter.Scan(&bucket) {
+		buckets = append(buckets, bucket)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to list outbox buckets: %w", err)
+	}
+
+	var events []*domain.Event
+	now := time.Now()
+	for _, bucket := range buckets {
+		if len(events) >= limit {
+			break
+		}
+		iter := db.query(OpList, `SELECT payload FROM outbox WHERE bucket = ? LIMIT ?`, bucket, limit-len(events)).
+			WithContext(ctx).Iter()
+		found, err := scanEvents(iter)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, found...)
+		if len(found) > 0 || now.Before(bucket.Add(outboxBucketSize+outboxBucketGrace)) {
+			continue
+		}
+		if err := db.query(OpWrite, `DELETE FROM outbox_buckets WHERE shard = 0 AND bucket = ?`, bucket).
+			WithContext(ctx).Exec(); err != nil {
+			return nil, fmt.Errorf("failed to drop outbox bucket: %w", err)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].OccurredAt.Before(events[j].OccurredAt)
+	})
+	orderByVersion(events)
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func scanEvents(iter *gocql.Iter) ([]*domain.Event, error) {
+	var events []*domain.Event
+	var payload string
+	for iter.Scan(&payload) {
+		var event domain.Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			iter.Close()
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to scan outbox: %w", err)
+	}
+	return events, nil
+}
+
+// orderByVersion puts the events of each user in version order, keeping the
+// positions their events take up in the slice
+func orderByVersion(events []*domain.Event) {
+	slots := make(map[string][]int)
+	for i, event := range events {
+		slots[event.UserID] = append(slots[event.UserID], i)
+	}
+	for _, idx := range slots {
+		if len(idx) < 2 {
+			continue
+		}
+		group := make([]*domain.Event, len(idx))
+		for k, i := range idx {
+			group[k] = events[i]
+		}
+		sort.Slice(group, func(a, b int) bool { return group[a].After.Version < group[b].After.Version })
+		for k, i := range idx {
+			events[i] = group[k]
+		}
+	}
+}
+
+// AckEvents removes delivered events from the outbox. The bucket of an event
+// is in its ID.
+func (db *ScyllaDB) AckEvents(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		uuid, err := gocql.ParseUUID(id)
+		if err != nil || uuid.Version() != 1 {
+			return fmt.Errorf("event ID %q is not a time-based UUID", id)
+		}
+		query := db.query(OpWrite, `DELETE FROM outbox WHERE bucket = ? AND id = ?`, outboxBucket(uuid.Time()), uuid)
+		if err := query.WithContext(ctx).Exec(); err != nil {
+			return fmt.Errorf("failed to acknowledge event: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// internal/storage/scylla/pending.go
+package scylla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/gocql/gocql"
+)
+
+// Kinds of pending index operation
+const (
+	// opPut makes the lookup row point at the user
+	opPut = "put"
+	// opDelete removes the lookup row if it still points at the user
+	opDelete = "delete"
+)
+
+// pendingOp is a lookup table write that failed and is queued for retry
+type pendingOp struct {
+	table  lookupTable
+	value  string
+	userID string
+	op     string
+}
+
+// RetryOptions controls a run over the pending index operations
+type RetryOptions struct {
+	// MinBackoff is the delay after the first failed retry. It doubles with
+	// every further failure up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// RetryReport summarizes a run over the pending index operations
+type RetryReport struct {
+	// Depth is the number of operations still queued after the run
+	Depth     int
+	Succeeded int
+	Failed    int
+	// Finished is the number of interrupted changes written by the run
+	Finished int
+}
+
+// enqueueIndexOps records lookup writes that failed with cause so the index
+// retry job can finish them. All operations are queued in one logged batch,
+// together with the change that made them if there is one.
+func (db *ScyllaDB) enqueueIndexOps(ctx context.Context, cause error, change *change, ops ...pendingOp) error {
+	query := `INSERT INTO pending_index_ops (id, table_name, value, user_id, op, attempts,
+		next_attempt_at, last_error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	now := time.Now()
+	batch := db.newBatch(context.WithoutCancel(ctx), gocql.LoggedBatch)
+	if change != nil {
+		if err := change.addTo(batch); err != nil {
+			return err
+		}
+	}
+	for _, op := range ops {
+		batch.Query(query, gocql.TimeUUID(), op.table.name, op.value, op.userID, op.op, 0, now, cause.Error(), now)
+	}
+	if err := db.executeLoggedBatch(batch); err != nil {
+		return fmt.Errorf("failed to queue index operations: %w", err)
+	}
+	for _, op := range ops {
+		slog.Warn("Queued lookup write for retry",
+			"table", op.table.name, "value", op.value, "user_id", op.userID, "op", op.op, "error", cause)
+	}
+	return nil
+}
+
+// RetryIndexOps finishes the changes of interrupted updates (see
+// finishChanges), then retries every queued operation whose backoff has
+// passed. Operations that go through are removed from the queue; failed ones
+// are rescheduled. Each operation is checked against the users row first, so
+// one made obsolete by a later write is dropped instead of applied.
+func (db *ScyllaDB) RetryIndexOps(ctx context.Context, opts RetryOptions) (*RetryReport, error) {
+	report := &RetryReport{}
+	finished, err := db.finishChanges(ctx)
+	report.Finished = finished
+	if err != nil {
+		return report, err
+	}
+
+	query := `SELECT id, table_name, value, user_id, op, attempts, next_attempt_at FROM pending_index_ops`
+	iter := db.query(OpList, query).WithContext(ctx).PageSize(1000).Iter()
+
+	now := time.Now()
+	var id gocql.UUID
+	var tableName, value, userID, op string
+	var attempts int
+	var nextAttemptAt time.Time
+	for iter.Scan(&id, &tableName, &value, &userID, &op, &attempts, &nextAttemptAt) {
+		report.Depth++
+		if nextAttemptAt.After(now) {
+			continue
+		}
+
+		err := db.applyIndexOp(ctx, tableName, value, userID, op)
+		if err == nil {
+			if err := db.query(OpWrite, `DELETE FROM pending_index_ops WHERE id = ?`, id).WithContext(ctx).Exec(); err != nil {
+				iter.Close()
+				return report, fmt.Errorf("failed to dequeue index operation: %w", err)
+			}
+			report.Depth--
+			report.Succeeded++
+			continue
+		}
+
+		attempts++
+		report.Failed++
+		slog.Warn("Retry of lookup write failed",
+			"table", tableName, "value", value, "user_id", userID, "op", op, "attempts", attempts, "error", err)
+		if err := db.query(OpWrite, `UPDATE pending_index_ops SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+			attempts, now.Add(backoff(attempts, opts)), err.Error(), id).WithContext(ctx).Exec(); err != nil {
+			iter.Close()
+			return report, fmt.Errorf("failed to reschedule index operation: %w", err)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return report, fmt.Errorf("failed to scan pending index operations: %w", err)
+	}
+	return report, nil
+}
+
+// applyIndexOp carries out one queued operation
+func (db *ScyllaDB) applyIndexOp(ctx context.Context, tableName, value, userID, op string) error {
+	table, ok := findLookupTable(tableName)
+	if !ok {
+		slog.Error("Dropping index operation for unknown table", "table", tableName, "value", value)
+		return nil
+	}
+
+	owns := false
+	user, err := db.getUser(ctx, OpWrite, userID)
+	switch {
+	case err == nil:
+		owns = contactValue(table, user) == value
+	case !errors.Is(err, domain.ErrUserNotFound):
+		return err
+	}
+
+	switch op {
+	case opPut:
+		if !owns {
+			// The user has moved on or is gone
+			return nil
+		}
+		claimed, err := db.claimLookup(ctx, table, value, userID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			slog.Error("Lookup value belongs to another user; reconcile to resolve",
+				"table", table.name, "value", value, "user_id", userID)
+		}
+		return nil
+	case opDelete:
+		if owns {
+			// The user has the value again, so the row is right
+			return nil
+		}
+		query := fmt.Sprintf(`DELETE FROM %s WHERE %s = ? IF user_id = ?`, table.name, table.column)
+		_, err := db.query(OpWrite, query, value, userID).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+		return err
+	default:
+		slog.Error("Dropping index operation of unknown kind", "op", op, "table", tableName, "value", value)
+		return nil
+	}
+}
+
+func findLookupTable(name string) (lookupTable, bool) {
+	for _, table := range lookupTables {
+		if table.name == name {
+			return table, true
+		}
+	}
+	return lookupTable{}, false
+}
+
+// backoff returns the delay before the next retry of an operation that has
+// failed attempts times
+func backoff(attempts int, opts RetryOptions) time.Duration {
+	delay := opts.MinBackoff
+	for i := 1; i < attempts && delay < opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > opts.MaxBackoff {
+		delay = opts.MaxBackoff
+	}
+	return delay
+}
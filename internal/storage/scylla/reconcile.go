@@ -0,0 +1,258 @@
+// internal/storage/scylla/reconcile.go
+package scylla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+)
+
+// IssueKind classifies a lookup row that disagrees with the users table
+type IssueKind string
+
+const (
+	// IssueMissing means a user has an email or phone with no lookup row
+	IssueMissing IssueKind = "missing"
+	// IssueOrphaned means a lookup row points at a user that doesn't exist
+	IssueOrphaned IssueKind = "orphaned"
+	// IssueStale means a lookup row points at a user that no longer has
+	// that email or phone
+	IssueStale IssueKind = "stale"
+	// IssueDuplicate means several users share an email or phone. It can't
+	// be repaired automatically.
+	IssueDuplicate IssueKind = "duplicate"
+)
+
+// LookupIssue is one inconsistency found by Reconcile
+type LookupIssue struct {
+	Kind  IssueKind
+	Table string
+	Value string
+	// LookupUserID is the user the lookup row points at, if there is a row
+	LookupUserID string
+	// UserIDs are the users whose current email or phone is Value
+	UserIDs  []string
+	Repaired bool
+}
+
+// ReconcileOptions controls a reconciliation run
+type ReconcileOptions struct {
+	// Repair fixes what can be fixed; otherwise the run only reports
+	Repair bool
+	// GracePeriod skips rows written this recently. CreateUser and
+	// UpdateUser briefly leave lookups ahead of the users row, and those
+	// in-flight writes must not be "repaired".
+	GracePeriod time.Duration
+}
+
+// ReconcileReport summarizes a reconciliation run
+type ReconcileReport struct {
+	UsersScanned   int
+	LookupsScanned int
+	Issues         []LookupIssue
+	Repaired       int
+}
+
+// userContact is the part of a users row the reconciler compares
+type userContact struct {
+	// email is the canonical email, the key of users_by_email
+	email     string
+	phone     string
+	updatedAt time.Time
+}
+
+// lookupTable describes one of the lookup tables
+type lookupTable struct {
+	name   string
+	column string
+	value  func(u userContact) string
+}
+
+var (
+	emailLookup  = lookupTable{name: "users_by_email", column: "email", value: func(u userContact) string { return u.email }}
+	phoneLookup  = lookupTable{name: "users_by_phone", column: "phone_number", value: func(u userContact) string { return u.phone }}
+	lookupTables = []lookupTable{emailLookup, phoneLookup}
+)
+
+// Reconcile scans the users table and both lookup tables and reports every
+// lookup row that disagrees with the users table. With opts.Repair set it
+// also fixes them: missing rows are inserted, orphaned and stale rows are
+// pointed at the user that now owns the value or deleted. Every repair is a
+// conditional write, so it never overwrites a row changed during the scan.
+func (db *ScyllaDB) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	users, err := db.scanUserContacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconcileReport{UsersScanned: len(users)}
+	cutoff := time.Now().Add(-opts.GracePeriod)
+	for _, table := range lookupTables {
+		scanned, err := db.reconcileTable(ctx, table, users, cutoff, opts.Repair, report)
+		report.LookupsScanned += scanned
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, issue := range report.Issues {
+		slog.Warn("Lookup inconsistency",
+			"kind", issue.Kind,
+			"table", issue.Table,
+			"value", issue.Value,
+			"lookup_user_id", issue.LookupUserID,
+			"user_ids", issue.UserIDs,
+			"repaired", issue.Repaired,
+		)
+	}
+	return report, nil
+}
+
+func (db *ScyllaDB) scanUserContacts(ctx context.Context) (map[string]userContact, error) {
+	users := make(map[string]userContact)
+	iter := db.query(OpList, `SELECT id, email, email_canonical, phone_number, updated_at FROM users`).
+		WithContext(ctx).PageSize(1000).Iter()
+
+	var id string
+	var user domain.User
+	var u userContact
+	for iter.Scan(&id, &user.Email, &user.CanonicalEmail, &u.phone, &u.updatedAt) {
+		u.email = user.EmailKey()
+		users[id] = u
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+	return users, nil
+}
+
+func (db *ScyllaDB) reconcileTable(ctx context.Context, table lookupTable, users map[string]userContact,
+	cutoff time.Time, repair bool, report *ReconcileReport) (int, error) {
+	owners := make(map[string][]string)
+	for id, u := range users {
+		if value := table.value(u); value != "" {
+			owners[value] = append(owners[value], id)
+		}
+	}
+
+	query := fmt.Sprintf(`SELECT %s, user_id, created_at FROM %s`, table.column, table.name)
+	iter := db.query(OpList, query).WithContext(ctx).PageSize(1000).Iter()
+
+	scanned := 0
+	seen := make(map[string]bool)
+	var value, userID string
+	var createdAt time.Time
+	for iter.Scan(&value, &userID, &createdAt) {
+		scanned++
+		seen[value] = true
+		if createdAt.After(cutoff) {
+			continue
+		}
+
+		u, ok := users[userID]
+		if ok && table.value(u) == value {
+			if len(owners[value]) > 1 {
+				report.Issues = append(report.Issues, LookupIssue{
+					Kind: IssueDuplicate, Table: table.name, Value: value,
+					LookupUserID: userID, UserIDs: owners[value],
+				})
+			}
+			continue
+		}
+
+		issue := LookupIssue{Kind: IssueStale, Table: table.name, Value: value,
+			LookupUserID: userID, UserIDs: owners[value]}
+		if !ok {
+			issue.Kind = IssueOrphaned
+		}
+		if repair {
+			issue.Repaired = db.repairLookupRow(ctx, table, issue)
+		}
+		report.addIssue(issue)
+	}
+	if err := iter.Close(); err != nil {
+		return scanned, fmt.Errorf("failed to scan %s: %w", table.name, err)
+	}
+
+	for value, ids := range owners {
+		if seen[value] {
+			continue
+		}
+		issue := LookupIssue{Kind: IssueMissing, Table: table.name, Value: value, UserIDs: ids}
+		if len(ids) > 1 {
+			issue.Kind = IssueDuplicate
+			report.addIssue(issue)
+			continue
+		}
+		if users[ids[0]].updatedAt.After(cutoff) {
+			continue
+		}
+		if repair {
+			issue.Repaired = db.insertMissingLookup(ctx, table, value, ids[0])
+		}
+		report.addIssue(issue)
+	}
+	return scanned, nil
+}
+
+func (r *ReconcileReport) addIssue(issue LookupIssue) {
+	r.Issues = append(r.Issues, issue)
+	if issue.Repaired {
+		r.Repaired++
+	}
+}
+
+// repairLookupRow fixes an orphaned or stale lookup row. If exactly one user
+// owns the value the row is pointed at it, otherwise it is deleted.
+func (db *ScyllaDB) repairLookupRow(ctx context.Context, table lookupTable, issue LookupIssue) bool {
+	// Re-read the user the row points at; it may have been written since
+	// the scan
+	if user, err := db.getUser(ctx, OpWrite, issue.LookupUserID); err == nil {
+		if contactValue(table, user) == issue.Value {
+			return false
+		}
+	} else if !errors.Is(err, domain.ErrUserNotFound) {
+		slog.Error("Failed to re-check lookup row", "table", table.name, "value", issue.Value, "error", err)
+		return false
+	}
+
+	var query string
+	var args []interface{}
+	if len(issue.UserIDs) == 1 {
+		query = fmt.Sprintf(`UPDATE %s SET user_id = ?, created_at = ? WHERE %s = ? IF user_id = ?`, table.name, table.column)
+		args = []interface{}{issue.UserIDs[0], time.Now(), issue.Value, issue.LookupUserID}
+	} else {
+		query = fmt.Sprintf(`DELETE FROM %s WHERE %s = ? IF user_id = ?`, table.name, table.column)
+		args = []interface{}{issue.Value, issue.LookupUserID}
+	}
+
+	applied, err := db.query(OpWrite, query, args...).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		slog.Error("Failed to repair lookup row", "table", table.name, "value", issue.Value, "error", err)
+		return false
+	}
+	return applied
+}
+
+// insertMissingLookup adds the lookup row for a user that has none
+func (db *ScyllaDB) insertMissingLookup(ctx context.Context, table lookupTable, value string, userID string) bool {
+	user, err := db.getUser(ctx, OpWrite, userID)
+	if err != nil || contactValue(table, user) != value {
+		return false
+	}
+
+	claimed, err := db.claimLookup(ctx, table, value, userID)
+	if err != nil {
+		slog.Error("Failed to repair lookup row", "table", table.name, "value", value, "error", err)
+		return false
+	}
+	return claimed
+}
+
+func contactValue(table lookupTable, user *domain.User) string {
+	return table.value(userContact{email: user.EmailKey(), phone: user.PhoneNumber})
+}
@@ -3,223 +3,652 @@ package scylla
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sync/atomic"
 	"time"
 
 	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
 	"github.com/gocql/gocql"
 )
 
+// userColumns lists the users table columns in the order userFields scans them
+const userColumns = `id, first_name, last_name, gender, date_of_birth, 
+	phone_number, email, email_canonical, is_blocked, created_at, updated_at, version, deleted_at`
+
+func userFields(user *domain.User) []interface{} {
+	return []interface{}{
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Gender,
+		&user.DateOfBirth,
+		&user.PhoneNumber,
+		&user.Email,
+		&user.CanonicalEmail,
+		&user.IsBlocked,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+		&user.DeletedAt,
+	}
+}
+
+// expectedVersion is the value to compare in an IF version = ? condition.
+// Rows written before the version column existed hold null, which scans as 0.
+func expectedVersion(version int64) interface{} {
+	if version == 0 {
+		return nil
+	}
+	return version
+}
+
+// nullTime binds a zero time as null rather than the epoch
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// nullUUID binds a zero UUID as null, which a timeuuid column requires
+func nullUUID(id gocql.UUID) interface{} {
+	if id == (gocql.UUID{}) {
+		return nil
+	}
+	return id
+}
+
 type ScyllaDB struct {
-	session *gocql.Session
+	cluster *gocql.ClusterConfig
+	// session is replaced by Reconnect
+	session atomic.Pointer[gocql.Session]
+	// speculative is applied to reads, which are the only queries safe to
+	// send more than once
+	speculative gocql.SpeculativeExecutionPolicy
+	ops         map[Operation]opSettings
+	// exportWorkers is the number of token ranges ExportUsers scans at once
+	exportWorkers int
+
+	staleLookups    atomic.Uint64
+	repairedLookups atomic.Uint64
 }
 
-func NewScyllaDB(hosts []string, port int, keyspace string, consistency string) (*ScyllaDB, error) {
-	cluster := gocql.NewCluster(hosts...)
-	cluster.Port = port
-	cluster.Keyspace = keyspace
-	cluster.Consistency = parseConsistency(consistency)
-	cluster.ProtoVersion = 4
-	cluster.ConnectTimeout = time.Second * 10
-	cluster.Timeout = time.Second * 10
+// NewScyllaDB connects to the cluster described by opts
+func NewScyllaDB(opts ClusterOptions) (*ScyllaDB, error) {
+	cluster, err := NewClusterConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := operationSettings(opts)
+	if err != nil {
+		return nil, err
+	}
 
 	session, err := cluster.CreateSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ScyllaDB: %w", err)
 	}
 
-	return &ScyllaDB{session: session}, nil
+	db := &ScyllaDB{
+		cluster:       cluster,
+		speculative:   gocql.NonSpeculativeExecution{},
+		ops:           ops,
+		exportWorkers: opts.ExportWorkers,
+	}
+	if db.exportWorkers <= 0 {
+		db.exportWorkers = defaultExportWorkers
+	}
+	db.session.Store(session)
+	if opts.SpeculativeAttempts > 0 {
+		db.speculative = &gocql.SimpleSpeculativeExecution{
+			NumAttempts:  opts.SpeculativeAttempts,
+			TimeoutDelay: opts.SpeculativeDelay,
+		}
+	}
+	return db, nil
 }
 
-func parseConsistency(consistency string) gocql.Consistency {
-	switch consistency {
-	case "ONE":
-		return gocql.One
-	case "QUORUM":
-		return gocql.Quorum
-	case "ALL":
-		return gocql.All
-	default:
-		return gocql.Quorum
+// Ping checks that the cluster answers a query
+func (db *ScyllaDB) Ping(ctx context.Context) error {
+	var now gocql.UUID
+	return db.session.Load().Query(`SELECT now() FROM system.local`).
+		Consistency(gocql.One).WithContext(ctx).Scan(&now)
+}
+
+// Reconnect opens a new session and swaps it in. Queries already running on
+// the old session fail; later ones use the new one.
+func (db *ScyllaDB) Reconnect() error {
+	session, err := db.cluster.CreateSession()
+	if err != nil {
+		return fmt.Errorf("failed to reconnect to ScyllaDB: %w", err)
 	}
+	db.session.Swap(session).Close()
+	return nil
 }
 
-// CreateUser creates a new user
+// CreateUser creates a new user. The email and phone lookup rows are claimed
+// with lightweight transactions first, so only one of several concurrent
+// creates with the same email or phone can succeed. The claims are the lookup
+// rows; they are never rewritten by a plain write, whose timestamp could lose
+// to the Paxos one. The users row is then written in a logged batch together
+// with the UserCreated event and the audit record. If the batch fails the
+// claims are released and domain.ErrDatabaseError is returned; if the process
+// dies first, the claims are abandoned and taken over by the next claim of
+// the value, see claimLookup.
 func (db *ScyllaDB) CreateUser(ctx context.Context, user *domain.User) error {
-	exists, err := db.CheckEmailExists(ctx, user.Email)
-	if err != nil {
+	ctx, cancel := db.withTimeout(ctx, OpWrite)
+	defer cancel()
+
+	batch := db.newBatch(ctx, gocql.LoggedBatch)
+	if err := newChange(ctx, nil, user).addTo(batch); err != nil {
 		return err
 	}
-	if exists {
-		return domain.ErrEmailAlreadyExists
-	}
 
-	exists, err = db.CheckPhoneExists(ctx, user.PhoneNumber)
+	claimed, err := db.claimEmailLookup(ctx, user.EmailKey(), user.ID)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return domain.ErrPhoneAlreadyExists
+	if !claimed {
+		return domain.ErrEmailAlreadyExists
 	}
 
-	query := `INSERT INTO users (id, first_name, last_name, gender, date_of_birth, 
-		phone_number, email, is_blocked, created_at, updated_at) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	if err := db.session.Query(query,
-		user.ID,
-		user.FirstName,
-		user.LastName,
-		user.Gender,
-		user.DateOfBirth,
-		user.PhoneNumber,
-		user.Email,
-		user.IsBlocked,
-		user.CreatedAt,
-		user.UpdatedAt,
-	).WithContext(ctx).Exec(); err != nil {
-		return fmt.Errorf("failed to insert user: %w", err)
+	claimed, err = db.claimPhoneLookup(ctx, user.PhoneNumber, user.ID)
+	if err != nil || !claimed {
+		db.releaseEmailLookup(ctx, user.EmailKey(), user.ID)
+		if err != nil {
+			return err
+		}
+		return domain.ErrPhoneAlreadyExists
 	}
 
-	if err := db.insertPhoneLookup(ctx, user.PhoneNumber, user.ID); err != nil {
-		return err
-	}
-	if err := db.insertEmailLookup(ctx, user.Email, user.ID); err != nil {
-		return err
+	query := `INSERT INTO users (` + userColumns + `) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	batch.Query(query, userValues(user)...)
+
+	if err := db.executeLoggedBatch(batch); err != nil {
+		slog.Error("Failed to write user", "user_id", user.ID, "error", err)
+		db.releaseEmailLookup(ctx, user.EmailKey(), user.ID)
+		db.releasePhoneLookup(ctx, user.PhoneNumber, user.ID)
+		return domain.ErrDatabaseError
 	}
 
+	total, blocked := counterDeltas(nil, user)
+	db.adjustCounters(ctx, total, blocked)
 	return nil
 }
 
 // GetUserByID retrieves a user by ID (string)
 func (db *ScyllaDB) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
-	query := `SELECT id, first_name, last_name, gender, date_of_birth, 
-		phone_number, email, is_blocked, created_at, updated_at 
-		FROM users WHERE id = ?`
+	ctx, cancel := db.withTimeout(ctx, OpRead)
+	defer cancel()
+	return db.getUser(ctx, OpRead, id)
+}
+
+// getUser reads a users row at the consistency level of op. Reads that a
+// write is based on use OpWrite, so they see every acknowledged write.
+func (db *ScyllaDB) getUser(ctx context.Context, op Operation, id string) (*domain.User, error) {
+	user, _, err := db.getUserRow(ctx, op, id)
+	return user, err
+}
+
+// getUserRow is getUser that also returns the ID of the change that last
+// wrote the row, zero if none was recorded
+func (db *ScyllaDB) getUserRow(ctx context.Context, op Operation, id string) (*domain.User, gocql.UUID, error) {
+	query := `SELECT ` + userColumns + `, change_id FROM users WHERE id = ?`
 
 	var user domain.User
-	if err := db.session.Query(query, id).WithContext(ctx).Scan(
-		&user.ID,
-		&user.FirstName,
-		&user.LastName,
-		&user.Gender,
-		&user.DateOfBirth,
-		&user.PhoneNumber,
-		&user.Email,
-		&user.IsBlocked,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	); err != nil {
+	var changeID gocql.UUID
+	if err := db.read(op, query, id).WithContext(ctx).Scan(append(userFields(&user), &changeID)...); err != nil {
 		if err == gocql.ErrNotFound {
-			return nil, domain.ErrUserNotFound
+			return nil, changeID, domain.ErrUserNotFound
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, changeID, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &user, nil
+	return &user, changeID, nil
 }
 
 // GetUserByPhone retrieves a user by phone number
 func (db *ScyllaDB) GetUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
-	var userID string
-	query := `SELECT user_id FROM users_by_phone WHERE phone_number = ?`
-	if err := db.session.Query(query, phone).WithContext(ctx).Scan(&userID); err != nil {
-		if err == gocql.ErrNotFound {
-			return nil, domain.ErrUserNotFound
-		}
-		return nil, fmt.Errorf("failed to lookup user by phone: %w", err)
-	}
-	return db.GetUserByID(ctx, userID)
+	return db.getUserByLookup(ctx, phoneLookup, phone)
 }
 
-// GetUserByEmail retrieves a user by email
+// GetUserByEmail retrieves a user by canonical email
 func (db *ScyllaDB) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return db.getUserByLookup(ctx, emailLookup, email)
+}
+
+// getUserByLookup resolves value through a lookup table and checks that the
+// user still has it. A lookup row pointing at a missing user or at a user
+// whose email or phone has since changed is treated as not found and repaired.
+// Soft-deleted users keep their lookup rows, so they still resolve.
+func (db *ScyllaDB) getUserByLookup(ctx context.Context, table lookupTable, value string) (*domain.User, error) {
+	ctx, cancel := db.withTimeout(ctx, OpRead)
+	defer cancel()
+
 	var userID string
-	query := `SELECT user_id FROM users_by_email WHERE email = ?`
-	if err := db.session.Query(query, email).WithContext(ctx).Scan(&userID); err != nil {
+	var createdAt time.Time
+	query := fmt.Sprintf(`SELECT user_id, created_at FROM %s WHERE %s = ?`, table.name, table.column)
+	if err := db.read(OpRead, query, value).WithContext(ctx).Scan(&userID, &createdAt); err != nil {
 		if err == gocql.ErrNotFound {
 			return nil, domain.ErrUserNotFound
 		}
-		return nil, fmt.Errorf("failed to lookup user by email: %w", err)
+		return nil, fmt.Errorf("failed to lookup user by %s: %w", table.column, err)
 	}
-	return db.GetUserByID(ctx, userID)
+
+	user, err := db.getUser(ctx, OpRead, userID)
+	switch {
+	case err == nil && contactValue(table, user) == value:
+		return user, nil
+	case err == nil:
+		db.repairStaleLookup(ctx, table, value, userID, createdAt, IssueStale)
+	case errors.Is(err, domain.ErrUserNotFound):
+		db.repairStaleLookup(ctx, table, value, userID, createdAt, IssueOrphaned)
+	default:
+		return nil, err
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+// readRepairGrace keeps read-repair and claim takeover away from lookup rows
+// this young. A claim made by an in-flight CreateUser or UpdateUser points at
+// a user that doesn't have the value yet.
+const readRepairGrace = time.Minute
+
+// Stats counts lookup rows found stale by reads since the store was opened
+type Stats struct {
+	StaleLookups    uint64 `json:"stale_lookups"`
+	RepairedLookups uint64 `json:"repaired_lookups"`
 }
 
-// UpdateUser updates an existing user
+// Stats returns a snapshot of the read-repair counters
+func (db *ScyllaDB) Stats() Stats {
+	return Stats{
+		StaleLookups:    db.staleLookups.Load(),
+		RepairedLookups: db.repairedLookups.Load(),
+	}
+}
+
+// repairStaleLookup deletes a lookup row that a read found pointing at the
+// wrong user. The delete is conditional on the row still pointing there, and
+// is queued for retry if it fails. The rightful owner's row, if any, is left
+// to the index retry job and the reconciler.
+func (db *ScyllaDB) repairStaleLookup(ctx context.Context, table lookupTable, value, userID string,
+	createdAt time.Time, kind IssueKind) {
+	db.staleLookups.Add(1)
+	repaired := false
+	if time.Since(createdAt) >= readRepairGrace {
+		repaired = db.releaseLookup(ctx, table, value, userID) == nil
+	}
+	if repaired {
+		db.repairedLookups.Add(1)
+	}
+	slog.Warn("Stale lookup row",
+		"kind", kind,
+		"table", table.name,
+		"value", value,
+		"lookup_user_id", userID,
+		"repaired", repaired,
+	)
+}
+
+// UpdateUser updates an existing user if its stored version still matches
+// user.Version, and bumps the version on success. A changed email or phone is
+// claimed before the users row is written. The version check needs its own
+// lightweight transaction, so the change event, audit record, snapshots,
+// search tokens and list table rows, and deleting the old lookup rows, happen
+// in a logged batch after it.
+//
+// So that a crash between the two can't lose the change, it is first
+// recorded in pending_changes, and the users row write stores its ID in
+// change_id. The next update of the user, or the index retry job, finishes a
+// change its users row still points at; see finishChanges. Until then the
+// change is missing from the outbox, audit log, history, search and list
+// tables, and the old lookup rows are left for read-repair and the
+// reconciler.
+//
+// If the batch fails the lookup deletes are queued for the index retry job,
+// in a batch that writes the change as well; if that fails too, the users
+// row is put back and domain.ErrDatabaseError is returned.
 func (db *ScyllaDB) UpdateUser(ctx context.Context, user *domain.User) error {
-	existingUser, err := db.GetUserByID(ctx, user.ID)
+	ctx, cancel := db.withTimeout(ctx, OpWrite)
+	defer cancel()
+
+	existingUser, changeID, err := db.getUserRow(ctx, OpWrite, user.ID)
 	if err != nil {
 		return err
 	}
+	if existingUser.Version != user.Version {
+		return domain.ErrVersionConflict
+	}
+	if err := db.finishChange(ctx, changeID); err != nil {
+		return err
+	}
 
-	query := `UPDATE users SET first_name = ?, last_name = ?, gender = ?, 
-		date_of_birth = ?, phone_number = ?, email = ?, is_blocked = ?, updated_at = ? 
-		WHERE id = ?`
+	next := *user
+	next.Version++
+	change := newChange(ctx, existingUser, &next)
 
-	if err := db.session.Query(query,
-		user.FirstName, user.LastName, user.Gender,
-		user.DateOfBirth, user.PhoneNumber, user.Email,
-		user.IsBlocked, user.UpdatedAt, user.ID,
-	).WithContext(ctx).Exec(); err != nil {
-		return fmt.Errorf("failed to update user: %w", err)
+	emailChanged := existingUser.EmailKey() != user.EmailKey()
+	phoneChanged := existingUser.PhoneNumber != user.PhoneNumber
+
+	if emailChanged {
+		claimed, err := db.claimEmailLookup(ctx, user.EmailKey(), user.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return domain.ErrEmailAlreadyExists
+		}
+	}
+	if phoneChanged {
+		claimed, err := db.claimPhoneLookup(ctx, user.PhoneNumber, user.ID)
+		if err != nil || !claimed {
+			if emailChanged {
+				db.releaseEmailLookup(ctx, user.EmailKey(), user.ID)
+			}
+			if err != nil {
+				return err
+			}
+			return domain.ErrPhoneAlreadyExists
+		}
+	}
+	releaseClaims := func() {
+		if emailChanged {
+			db.releaseEmailLookup(ctx, user.EmailKey(), user.ID)
+		}
+		if phoneChanged {
+			db.releasePhoneLookup(ctx, user.PhoneNumber, user.ID)
+		}
 	}
 
-	if existingUser.PhoneNumber != user.PhoneNumber {
-		db.deletePhoneLookup(ctx, existingUser.PhoneNumber)
-		db.insertPhoneLookup(ctx, user.PhoneNumber, user.ID)
+	if err := db.recordChange(ctx, change); err != nil {
+		releaseClaims()
+		return err
+	}
+	batch := db.newBatch(ctx, gocql.LoggedBatch)
+	if err := change.addTo(batch); err != nil {
+		releaseClaims()
+		db.discardChange(ctx, change.id)
+		return err
 	}
-	if existingUser.Email != user.Email {
-		db.deleteEmailLookup(ctx, existingUser.Email)
-		db.insertEmailLookup(ctx, user.Email, user.ID)
+
+	applied, err := db.writeUserRow(ctx, user, user.Version+1, user.Version, change.id)
+	if err != nil || !applied {
+		releaseClaims()
+		if err != nil {
+			// The write may still have gone through, so the recorded change
+			// is left for the index retry job to finish or drop
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+		db.discardChange(ctx, change.id)
+		return domain.ErrVersionConflict
 	}
+
+	if emailChanged {
+		batch.Query(`DELETE FROM users_by_email WHERE email = ?`, existingUser.EmailKey())
+	}
+	if phoneChanged {
+		batch.Query(`DELETE FROM users_by_phone WHERE phone_number = ?`, existingUser.PhoneNumber)
+	}
+	if err := db.executeLoggedBatch(batch); err != nil {
+		// The users row is already written. Queue the lookup writes along
+		// with the event and audit record so the update can go ahead; only if
+		// that fails too is it undone.
+		var ops []pendingOp
+		if emailChanged {
+			ops = append(ops, pendingOp{table: emailLookup, value: existingUser.EmailKey(), userID: user.ID, op: opDelete})
+		}
+		if phoneChanged {
+			ops = append(ops, pendingOp{table: phoneLookup, value: existingUser.PhoneNumber, userID: user.ID, op: opDelete})
+		}
+		if qerr := db.enqueueIndexOps(ctx, err, change, ops...); qerr != nil {
+			slog.Error("Failed to complete user update", "user_id", user.ID, "error", err, "queue_error", qerr)
+			db.revertUserRow(ctx, existingUser, user.Version+1)
+			releaseClaims()
+			return domain.ErrDatabaseError
+		}
+	}
+	user.Version++
+
+	total, blocked := counterDeltas(existingUser, user)
+	db.adjustCounters(ctx, total, blocked)
 	return nil
 }
 
-// DeleteUser deletes a user
-func (db *ScyllaDB) DeleteUser(ctx context.Context, id string) error {
-	user, err := db.GetUserByID(ctx, id)
+// writeUserRow writes every mutable column of user with the given version
+// and change ID, if the stored version is still expected
+func (db *ScyllaDB) writeUserRow(ctx context.Context, user *domain.User, version, expected int64, changeID gocql.UUID) (bool, error) {
+	query := `UPDATE users SET first_name = ?, last_name = ?, gender = ?, 
+		date_of_birth = ?, phone_number = ?, email = ?, email_canonical = ?, is_blocked = ?, 
+		updated_at = ?, version = ?, deleted_at = ?, change_id = ? 
+		WHERE id = ? IF version = ?`
+
+	return db.query(OpWrite, query,
+		user.FirstName, user.LastName, user.Gender,
+		user.DateOfBirth, user.PhoneNumber, user.Email, user.EmailKey(),
+		user.IsBlocked, user.UpdatedAt, version, nullTime(user.DeletedAt), nullUUID(changeID),
+		user.ID, expectedVersion(expected),
+	).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+}
+
+// revertUserRow is the compensating action for an update whose lookup batch
+// failed. It writes the previous contents back under a new version, so ETags
+// handed out for the failed update stop matching, and without a change ID,
+// so the failed change is dropped rather than finished. If the row has moved
+// on again it is left alone and the reconciler has to sort out the lookups.
+func (db *ScyllaDB) revertUserRow(ctx context.Context, previous *domain.User, current int64) {
+	ctx = context.WithoutCancel(ctx)
+	applied, err := db.writeUserRow(ctx, previous, current+1, current, gocql.UUID{})
+	if err != nil || !applied {
+		slog.Error("Failed to revert user after a partial update; lookups need reconciling",
+			"user_id", previous.ID, "applied", applied, "error", err)
+	}
+}
+
+// DeleteUser soft-deletes a user. The lookup rows are kept so the email and
+// phone stay reserved until the user is purged. The write goes through
+// UpdateUser, whose lightweight transaction fails if the user changes after
+// the version is checked here.
+func (db *ScyllaDB) DeleteUser(ctx context.Context, id string, version *int64) error {
+	ctx, cancel := db.withTimeout(ctx, OpWrite)
+	defer cancel()
+
+	user, err := db.getUser(ctx, OpWrite, id)
 	if err != nil {
 		return err
 	}
-	db.deletePhoneLookup(ctx, user.PhoneNumber)
-	db.deleteEmailLookup(ctx, user.Email)
-
-	query := `DELETE FROM users WHERE id = ?`
-	if err := db.session.Query(query, id).WithContext(ctx).Exec(); err != nil {
-		return fmt.Errorf("failed to delete user: %w", err)
+	if user.IsDeleted() {
+		return domain.ErrUserNotFound
 	}
-	return nil
+	if version != nil && user.Version != *version {
+		return domain.ErrVersionConflict
+	}
+	user.Delete()
+	return db.UpdateUser(ctx, user)
 }
 
-// ListUsers lists users with pagination
-func (db *ScyllaDB) ListUsers(ctx context.Context, limit int, pageToken string) ([]*domain.User, string, error) {
-	var users []*domain.User
-	query := `SELECT id, first_name, last_name, gender, date_of_birth, 
-		phone_number, email, is_blocked, created_at, updated_at FROM users LIMIT ?`
+// RestoreUser undoes a soft delete. Like DeleteUser, the write goes through
+// UpdateUser, whose lightweight transaction fails if the user changes after
+// the version is checked here.
+func (db *ScyllaDB) RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error) {
+	ctx, cancel := db.withTimeout(ctx, OpWrite)
+	defer cancel()
 
-	iter := db.session.Query(query, limit).WithContext(ctx).Iter()
+	user, err := db.getUser(ctx, OpWrite, id)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsDeleted() {
+		return nil, domain.ErrUserNotDeleted
+	}
+	if version != nil && user.Version != *version {
+		return nil, domain.ErrVersionConflict
+	}
+	user.Restore()
+	if err := db.UpdateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeDeletedUsers scans the users table and hard-deletes users that were
+// soft-deleted before the cutoff. A change the users row still points at is
+// finished first. Each user is then fenced with a conditional version bump,
+// so a user restored mid-scan is left alone and a concurrent restore fails
+// with a version conflict. The users row, both
+// lookup rows, the user's snapshots, search tokens and list table rows are
+// then deleted in one logged batch. If the batch fails the user simply stays soft-deleted
+// and is picked up by the next run.
+func (db *ScyllaDB) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	query := `SELECT id, first_name, last_name, phone_number, email, email_canonical, created_at, version, deleted_at, change_id FROM users`
+	iter := db.query(OpList, query).WithContext(ctx).Iter()
+
+	purged := 0
+	var id string
 	var user domain.User
-	for iter.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Gender,
-		&user.DateOfBirth, &user.PhoneNumber, &user.Email, &user.IsBlocked,
-		&user.CreatedAt, &user.UpdatedAt) {
-		u := user
-		users = append(users, &u)
+	var version int64
+	var deletedAt time.Time
+	var changeID gocql.UUID
+	for iter.Scan(&id, &user.FirstName, &user.LastName, &user.PhoneNumber, &user.Email, &user.CanonicalEmail, &user.CreatedAt, &version, &deletedAt, &changeID) {
+		if deletedAt.IsZero() || !deletedAt.Before(before) {
+			continue
+		}
+		if err := db.finishChange(ctx, changeID); err != nil {
+			iter.Close()
+			return purged, fmt.Errorf("failed to purge user: %w", err)
+		}
+
+		applied, err := db.query(OpWrite, `UPDATE users SET version = ?, change_id = null WHERE id = ? IF version = ? AND deleted_at = ?`,
+			version+1, id, expectedVersion(version), deletedAt).
+			WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+		if err != nil {
+			iter.Close()
+			return purged, fmt.Errorf("failed to purge user: %w", err)
+		}
+		if !applied {
+			continue
+		}
+
+		batch := db.newBatch(ctx, gocql.LoggedBatch)
+		batch.Query(`DELETE FROM users WHERE id = ?`, id)
+		batch.Query(`DELETE FROM users_by_email WHERE email = ?`, user.EmailKey())
+		batch.Query(`DELETE FROM users_by_phone WHERE phone_number = ?`, user.PhoneNumber)
+		batch.Query(`DELETE FROM user_versions WHERE user_id = ?`, id)
+		for _, token := range search.Tokens(&user) {
+			batch.Query(deleteSearchTokenQuery, search.Partition(token), token, id)
+		}
+		user.ID = id
+		deleteListRows(batch, &user)
+		if err := db.executeLoggedBatch(batch); err != nil {
+			iter.Close()
+			return purged, fmt.Errorf("failed to purge user: %w", err)
+		}
+		purged++
+	}
+	if err := iter.Close(); err != nil {
+		return purged, fmt.Errorf("failed to scan deleted users: %w", err)
+	}
+	return purged, nil
+}
+
+// ListUsers lists users one page at a time. Ordered by created_at or
+// last_name, or filtered on a created_at range, users are read from the list
+// tables; see listFromTable. Otherwise they come from the users table in
+// token order, and the page token is the raw gocql page state returned for
+// the previous page; callers should wrap it in an opaque token before handing
+// it to clients.
+//
+// Deleted and filtered out users are dropped after reading the users table,
+// so a page can hold fewer than opts.Limit users. Pages that end up empty are
+// skipped until about listScanLimit rows have been read; then the page is
+// returned as it is, possibly empty, with a token to go on from.
+func (db *ScyllaDB) ListUsers(ctx context.Context, opts storage.ListOptions) ([]*domain.User, string, error) {
+	switch {
+	case opts.Order.Field == listquery.FieldLastName:
+		return db.listFromTable(ctx, lastNameList, opts)
+	case opts.Order.Field == listquery.FieldCreatedAt, !opts.Filter.CreatedAt.IsZero():
+		return db.listFromTable(ctx, createdAtList, opts)
+	}
+
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	users := make([]*domain.User, 0, opts.Limit)
+	query := `SELECT ` + userColumns + ` FROM users`
+
+	pageState := []byte(opts.PageToken)
+	scanned := 0
+	for {
+		// Setting a page state turns off automatic paging, so the iterator
+		// stops after a single page.
+		iter := db.read(OpList, query).WithContext(ctx).
+			PageSize(opts.Limit).
+			PageState(pageState).
+			Iter()
+		var user domain.User
+		for iter.Scan(userFields(&user)...) {
+			scanned++
+			if (user.IsDeleted() && !opts.ShowDeleted) || !opts.Filter.Match(&user) {
+				continue
+			}
+			u := user
+			users = append(users, &u)
+		}
+		pageState = iter.PageState()
+		if err := iter.Close(); err != nil {
+			if opts.PageToken != "" && isInvalidRequest(err) {
+				return nil, "", domain.ErrInvalidPageToken
+			}
+			return nil, "", fmt.Errorf("failed to list users: %w", err)
+		}
+
+		if len(users) > 0 || len(pageState) == 0 || scanned >= listScanLimit {
+			return users, string(pageState), nil
+		}
+	}
+}
+
+// CountUsers reads the user_counters table maintained by CreateUser and
+// UpdateUser, so it does not scan the users table.
+func (db *ScyllaDB) CountUsers(ctx context.Context, blocked *bool) (int64, error) {
+	ctx, cancel := db.withTimeout(ctx, OpRead)
+	defer cancel()
+
+	counts := make(map[string]int64)
+	query := `SELECT name, value FROM user_counters WHERE name IN (?, ?)`
+	iter := db.read(OpRead, query, counterTotal, counterBlocked).WithContext(ctx).Iter()
+	var name string
+	var value int64
+	for iter.Scan(&name, &value) {
+		counts[name] = value
 	}
 	if err := iter.Close(); err != nil {
-		return nil, "", fmt.Errorf("failed to list users: %w", err)
+		return 0, fmt.Errorf("failed to count users: %w", err)
 	}
 
-	nextToken := ""
-	if len(users) == limit {
-		nextToken = users[len(users)-1].ID
+	switch {
+	case blocked == nil:
+		return counts[counterTotal], nil
+	case *blocked:
+		return counts[counterBlocked], nil
+	default:
+		return counts[counterTotal] - counts[counterBlocked], nil
 	}
-	return users, nextToken, nil
 }
 
-// CheckEmailExists checks if email exists
+// CheckEmailExists checks if a canonical email exists
 func (db *ScyllaDB) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx, OpCheck)
+	defer cancel()
+
 	var userID string
 	query := `SELECT user_id FROM users_by_email WHERE email = ?`
-	err := db.session.Query(query, email).WithContext(ctx).Scan(&userID)
+	err := db.read(OpCheck, query, email).WithContext(ctx).Scan(&userID)
 	if err == gocql.ErrNotFound {
 		return false, nil
 	}
@@ -231,9 +660,12 @@ func (db *ScyllaDB) CheckEmailExists(ctx context.Context, email string) (bool, e
 
 // CheckPhoneExists checks if phone exists
 func (db *ScyllaDB) CheckPhoneExists(ctx context.Context, phone string) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx, OpCheck)
+	defer cancel()
+
 	var userID string
 	query := `SELECT user_id FROM users_by_phone WHERE phone_number = ?`
-	err := db.session.Query(query, phone).WithContext(ctx).Scan(&userID)
+	err := db.read(OpCheck, query, phone).WithContext(ctx).Scan(&userID)
 	if err == gocql.ErrNotFound {
 		return false, nil
 	}
@@ -243,28 +675,221 @@ func (db *ScyllaDB) CheckPhoneExists(ctx context.Context, phone string) (bool, e
 	return true, nil
 }
 
-// Helper methods — now accept string userID
-func (db *ScyllaDB) insertPhoneLookup(ctx context.Context, phone string, userID string) error {
-	query := `INSERT INTO users_by_phone (phone_number, user_id, created_at) VALUES (?, ?, ?)`
-	return db.session.Query(query, phone, userID, time.Now()).WithContext(ctx).Exec()
+// claimPhoneLookup reserves phone for userID using a lightweight transaction.
+// It reports false if the phone number already belongs to another user.
+func (db *ScyllaDB) claimPhoneLookup(ctx context.Context, phone string, userID string) (bool, error) {
+	claimed, err := db.claimLookup(ctx, phoneLookup, phone, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim phone: %w", err)
+	}
+	return claimed, nil
+}
+
+// releasePhoneLookup removes the phone lookup row if it still belongs to userID
+func (db *ScyllaDB) releasePhoneLookup(ctx context.Context, phone string, userID string) error {
+	return db.releaseLookup(ctx, phoneLookup, phone, userID)
 }
 
-func (db *ScyllaDB) deletePhoneLookup(ctx context.Context, phone string) error {
-	query := `DELETE FROM users_by_phone WHERE phone_number = ?`
-	return db.session.Query(query, phone).WithContext(ctx).Exec()
+// claimEmailLookup reserves email for userID using a lightweight transaction.
+// It reports false if the email already belongs to another user.
+func (db *ScyllaDB) claimEmailLookup(ctx context.Context, email string, userID string) (bool, error) {
+	claimed, err := db.claimLookup(ctx, emailLookup, email, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim email: %w", err)
+	}
+	return claimed, nil
 }
 
-func (db *ScyllaDB) insertEmailLookup(ctx context.Context, email string, userID string) error {
-	query := `INSERT INTO users_by_email (email, user_id, created_at) VALUES (?, ?, ?)`
-	return db.session.Query(query, email, userID, time.Now()).WithContext(ctx).Exec()
+// releaseEmailLookup removes the email lookup row if it still belongs to userID
+func (db *ScyllaDB) releaseEmailLookup(ctx context.Context, email string, userID string) error {
+	return db.releaseLookup(ctx, emailLookup, email, userID)
 }
 
-func (db *ScyllaDB) deleteEmailLookup(ctx context.Context, email string) error {
-	query := `DELETE FROM users_by_email WHERE email = ?`
-	return db.session.Query(query, email).WithContext(ctx).Exec()
+// claimLookup writes the lookup row of key for userID with INSERT ... IF NOT
+// EXISTS. The row has no TTL, so a claim is final once made. A row held by
+// another user is taken over if it is abandoned: older than readRepairGrace
+// and pointing at a user that doesn't exist or doesn't have the value, as a
+// claim left by a write that died before its users row does. Every write is
+// conditional, so the takeover can't remove a row that changed meanwhile.
+func (db *ScyllaDB) claimLookup(ctx context.Context, table lookupTable, key string, userID string) (bool, error) {
+	query := fmt.Sprintf(`INSERT INTO %s (%s, user_id, created_at) VALUES (?, ?, ?) IF NOT EXISTS`, table.name, table.column)
+	for attempt := 0; ; attempt++ {
+		existing := make(map[string]interface{})
+		applied, err := db.query(OpWrite, query, key, userID, time.Now()).WithContext(ctx).MapScanCAS(existing)
+		if err != nil {
+			return false, err
+		}
+		// A retried claim that already went through shows up as a conflict
+		// with our own user ID, which still counts as claimed.
+		owner, _ := existing["user_id"].(string)
+		if applied || owner == userID {
+			return true, nil
+		}
+		if attempt > 0 {
+			return false, nil
+		}
+
+		createdAt, _ := existing["created_at"].(time.Time)
+		abandoned, err := db.isAbandonedLookup(ctx, table, key, owner, createdAt)
+		if err != nil || !abandoned {
+			return false, err
+		}
+		slog.Warn("Taking over abandoned lookup row", "table", table.name, "value", key,
+			"lookup_user_id", owner, "user_id", userID)
+		if err := db.releaseLookup(ctx, table, key, owner); err != nil {
+			return false, err
+		}
+	}
+}
+
+// isAbandonedLookup reports whether the lookup row of key, held by owner
+// since createdAt, no longer belongs to anyone
+func (db *ScyllaDB) isAbandonedLookup(ctx context.Context, table lookupTable, key, owner string, createdAt time.Time) (bool, error) {
+	if time.Since(createdAt) < readRepairGrace {
+		return false, nil
+	}
+	user, err := db.getUser(ctx, OpWrite, owner)
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+	return contactValue(table, user) != key, nil
+}
+
+// releaseLookup runs even if ctx is already cancelled, since it is usually
+// undoing a claim after a failed write. If it fails, the delete is queued for
+// the index retry job.
+func (db *ScyllaDB) releaseLookup(ctx context.Context, table lookupTable, key string, userID string) error {
+	ctx = context.WithoutCancel(ctx)
+	query := fmt.Sprintf(`DELETE FROM %s WHERE %s = ? IF user_id = ?`, table.name, table.column)
+	_, err := db.query(OpWrite, query, key, userID).WithContext(ctx).MapScanCAS(make(map[string]interface{}))
+	if err == nil {
+		return nil
+	}
+	if qerr := db.enqueueIndexOps(ctx, err, nil, pendingOp{table: table, value: key, userID: userID, op: opDelete}); qerr != nil {
+		slog.Warn("Failed to release lookup row", "key", key, "user_id", userID, "error", err, "queue_error", qerr)
+	}
+	return err
+}
+
+// executeLoggedBatch runs a logged batch. A write timeout reported after the
+// batch log was written means the coordinator will finish the batch itself,
+// so it counts as success.
+func (db *ScyllaDB) executeLoggedBatch(batch *gocql.Batch) error {
+	err := db.session.Load().ExecuteBatch(batch)
+	var timeout *gocql.RequestErrWriteTimeout
+	if errors.As(err, &timeout) && timeout.WriteType == "BATCH" {
+		slog.Warn("Logged batch timed out after it was logged; it will be replayed", "error", err)
+		return nil
+	}
+	return err
+}
+
+// Row names in the user_counters table
+const (
+	counterTotal   = "total"
+	counterBlocked = "blocked"
+)
+
+// adjustCounters applies deltas to the user counters. The user write has
+// already succeeded by the time this runs, so a failure is logged rather
+// than returned. Counter updates are not idempotent, so the batch is never
+// retried: a timed-out batch may or may not have been applied, and a retry
+// could count it twice. Either way the counters drift until RecountUsers
+// resets them.
+func (db *ScyllaDB) adjustCounters(ctx context.Context, total, blocked int64) {
+	batch := db.newBatch(context.WithoutCancel(ctx), gocql.CounterBatch)
+	batch.RetryPolicy(&gocql.SimpleRetryPolicy{NumRetries: 0})
+	query := `UPDATE user_counters SET value = value + ? WHERE name = ?`
+	if total != 0 {
+		batch.Query(query, total, counterTotal)
+	}
+	if blocked != 0 {
+		batch.Query(query, blocked, counterBlocked)
+	}
+	if batch.Size() == 0 {
+		return
+	}
+	if err := db.session.Load().ExecuteBatch(batch); err != nil {
+		slog.Warn("Failed to update user counters", "total", total, "blocked", blocked, "error", err)
+	}
+}
+
+// RecountUsers scans the users table and moves the counters to what it
+// found, returning the new totals. Counters can only be incremented, so it
+// adds the difference between the scan and the values read just before;
+// changes made while it runs are counted by the scan, by adjustCounters or
+// by both, and can leave the counters off by the writes in flight. Run it
+// after upgrading a keyspace with existing users, and again whenever the
+// counters drift.
+func (db *ScyllaDB) RecountUsers(ctx context.Context) (total, blocked int64, err error) {
+	err = db.ExportUsers(ctx, storage.ExportOptions{}, func(user *domain.User) error {
+		t, b := counterDeltas(nil, user)
+		total += t
+		blocked += b
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	countedTotal, err := db.CountUsers(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	blockedOnly := true
+	countedBlocked, err := db.CountUsers(ctx, &blockedOnly)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	batch := db.newBatch(ctx, gocql.CounterBatch)
+	batch.RetryPolicy(&gocql.SimpleRetryPolicy{NumRetries: 0})
+	query := `UPDATE user_counters SET value = value + ? WHERE name = ?`
+	if delta := total - countedTotal; delta != 0 {
+		batch.Query(query, delta, counterTotal)
+	}
+	if delta := blocked - countedBlocked; delta != 0 {
+		batch.Query(query, delta, counterBlocked)
+	}
+	if batch.Size() > 0 {
+		if err := db.session.Load().ExecuteBatch(batch); err != nil {
+			return 0, 0, fmt.Errorf("failed to reset user counters: %w", err)
+		}
+	}
+	slog.Info("Recounted users", "total", total, "blocked", blocked,
+		"total_drift", countedTotal-total, "blocked_drift", countedBlocked-blocked)
+	return total, blocked, nil
+}
+
+// counterDeltas returns how the total and blocked counters change when a
+// user goes from before to after. A nil before means the user is new.
+// Deleted users are not counted.
+func counterDeltas(before, after *domain.User) (total, blocked int64) {
+	count := func(u *domain.User) (int64, int64) {
+		if u == nil || u.IsDeleted() {
+			return 0, 0
+		}
+		if u.IsBlocked {
+			return 1, 1
+		}
+		return 1, 0
+	}
+	beforeTotal, beforeBlocked := count(before)
+	afterTotal, afterBlocked := count(after)
+	return afterTotal - beforeTotal, afterBlocked - beforeBlocked
+}
+
+// isInvalidRequest reports whether Scylla rejected the request as invalid,
+// which is how it answers a paging state it can't resume from.
+func isInvalidRequest(err error) bool {
+	var reqErr gocql.RequestError
+	return errors.As(err, &reqErr) && reqErr.Code() == gocql.ErrCodeInvalid
 }
 
 func (db *ScyllaDB) Close() error {
-	db.session.Close()
+	db.session.Load().Close()
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,121 @@
+// internal/storage/scylla/search.go
+package scylla
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/gocql/gocql"
+)
+
+var _ storage.SearchIndexer = (*ScyllaDB)(nil)
+
+// searchFetchSize is the number of candidates read per IN query on users
+const searchFetchSize = 100
+
+const (
+	insertSearchTokenQuery = `INSERT INTO user_search_tokens (prefix, token, user_id) VALUES (?, ?, ?)`
+	deleteSearchTokenQuery = `DELETE FROM user_search_tokens WHERE prefix = ? AND token = ? AND user_id = ?`
+)
+
+// addSearchTokens adds the search index writes of a change from before to
+// after to batch. Tokens are only written when they change.
+func addSearchTokens(batch *gocql.Batch, before, after *domain.User) {
+	removed, added := search.Changes(before, after)
+	for _, token := range removed {
+		batch.Query(deleteSearchTokenQuery, search.Partition(token), token, after.ID)
+	}
+	for _, token := range added {
+		batch.Query(insertSearchTokenQuery, search.Partition(token), token, after.ID)
+	}
+}
+
+// SearchUsers reads the candidates from the token range of the query's
+// longest term, which lies in a single partition of user_search_tokens, and
+// ranks the ones that match every term. Candidates that are deleted, or
+// whose tokens are stale, are dropped as they are read, so only users that
+// match count towards search.MaxCandidates; reading stops once there are
+// more, and the search fails with domain.ErrSearchTooBroad.
+func (db *ScyllaDB) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]*domain.User, string, error) {
+	ctx, cancel := db.withTimeout(ctx, OpList)
+	defer cancel()
+
+	term := opts.Query.Longest()
+	iter := db.read(OpList, `SELECT user_id FROM user_search_tokens WHERE prefix = ? AND token >= ? AND token <= ?`,
+		search.Partition(term), term, search.PrefixEnd(term)).
+		WithContext(ctx).
+		PageSize(search.MaxCandidates + 1).
+		Iter()
+	seen := make(map[string]bool)
+	var candidates []*domain.User
+	ids := make([]string, 0, searchFetchSize)
+	var id string
+	for {
+		more := iter.Scan(&id)
+		if more && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		if len(ids) == searchFetchSize || (!more && len(ids) > 0) {
+			found, err := db.searchCandidates(ctx, opts, ids)
+			if err != nil {
+				iter.Close()
+				return nil, "", err
+			}
+			candidates = append(candidates, found...)
+			ids = ids[:0]
+		}
+		if !more || len(candidates) > search.MaxCandidates {
+			break
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to search users: %w", err)
+	}
+	if len(candidates) > search.MaxCandidates {
+		return nil, "", domain.ErrSearchTooBroad
+	}
+	return search.Rank(opts.Query, candidates, opts.Limit, opts.PageToken)
+}
+
+// searchCandidates reads the users with the given IDs and returns the ones
+// that match opts
+func (db *ScyllaDB) searchCandidates(ctx context.Context, opts storage.SearchOptions, ids []string) ([]*domain.User, error) {
+	iter := db.read(OpList, `SELECT `+userColumns+` FROM users WHERE id IN ?`, ids).
+		WithContext(ctx).
+		Iter()
+	var candidates []*domain.User
+	var user domain.User
+	for iter.Scan(userFields(&user)...) {
+		if (user.IsDeleted() && !opts.ShowDeleted) || opts.Query.Score(&user) == 0 {
+			continue
+		}
+		u := user
+		candidates = append(candidates, &u)
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to read search candidates: %w", err)
+	}
+	return candidates, nil
+}
+
+// RebuildSearchIndex writes the search tokens of every user, deleted ones
+// included, in one unlogged batch per user. Tokens that a failed write left
+// behind are not removed; searches check every candidate again, so they only
+// cost a read.
+func (db *ScyllaDB) RebuildSearchIndex(ctx context.Context) (int, error) {
+	indexed := 0
+	err := db.ExportUsers(ctx, storage.ExportOptions{ShowDeleted: true}, func(user *domain.User) error {
+		batch := db.newBatch(ctx, gocql.UnloggedBatch)
+		addSearchTokens(batch, nil, user)
+		if err := db.session.Load().ExecuteBatch(batch); err != nil {
+			return fmt.Errorf("failed to index user %s: %w", user.ID, err)
+		}
+		indexed++
+		return nil
+	})
+	return indexed, err
+}
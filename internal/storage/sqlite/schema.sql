@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    -- The last name folded by search.Fold, which ListUsers orders by
+    last_name_key TEXT NOT NULL,
+    gender TEXT NOT NULL,
+    date_of_birth TIMESTAMP NOT NULL,
+    phone_number TEXT NOT NULL UNIQUE,
+    -- Uniqueness and lookups go by the canonical email, not the one entered
+    email TEXT NOT NULL,
+    email_canonical TEXT NOT NULL UNIQUE,
+    is_blocked BOOLEAN NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- ListUsers orders by created_at or last_name, with the ID as tie-breaker
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS users_last_name_key_idx ON users (last_name_key, id);
+
+-- User change events waiting to be published by the event dispatcher. They
+-- are written in the same transaction as the change and deleted once
+-- published.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    id TEXT NOT NULL UNIQUE,
+    event_type TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    occurred_at TIMESTAMP NOT NULL
+);
+
+-- Audit trail of every change to a user, kept after the user is purged
+CREATE TABLE IF NOT EXISTS user_audit (
+    seq INTEGER PRIMARY KEY AUTOINCREMENT,
+    id TEXT NOT NULL UNIQUE,
+    user_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    method TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    changes TEXT NOT NULL,
+    occurred_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_audit_user_id_idx ON user_audit (user_id, seq);
+
+-- Snapshot of a user after every change, removed when the user is purged
+CREATE TABLE IF NOT EXISTS user_versions (
+    user_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    payload TEXT NOT NULL,
+    PRIMARY KEY (user_id, version)
+);
+
+-- Search tokens of every user (see internal/search), written with every
+-- change and removed when the user is purged. Prefix searches are ranges on
+-- the primary key.
+CREATE TABLE IF NOT EXISTS user_search_tokens (
+    token TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    PRIMARY KEY (token, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_search_tokens_user_id_idx ON user_search_tokens (user_id);
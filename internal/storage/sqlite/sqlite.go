@@ -0,0 +1,92 @@
+// internal/storage/sqlite/sqlite.go
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/sqlstore"
+	"github.com/mattn/go-sqlite3"
+)
+
+var _ storage.Storage = (*SQLiteDB)(nil)
+
+//go:embed schema.sql
+var schema string
+
+// SQLite has no row locks; transactions are started with BEGIN IMMEDIATE
+// (see NewSQLiteDB) so they take the write lock up front instead.
+var dialect = sqlstore.Dialect{
+	DuplicateError: duplicateError,
+}
+
+// SQLiteDB stores users in a single SQLite file
+type SQLiteDB struct {
+	*sqlstore.Store
+}
+
+// NewSQLiteDB opens the database file at path, creating it and the schema if
+// they do not exist yet. The database runs in WAL mode so reads don't block
+// behind writes.
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", "5000")
+	params.Set("_txlock", "immediate")
+	dsn := "file:" + path + "?" + params.Encode()
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var journalMode string
+	if err := db.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&journalMode); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open SQLite: %w", err)
+	}
+	if !strings.EqualFold(journalMode, "wal") {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable WAL mode, journal mode is %q", journalMode)
+	}
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &SQLiteDB{Store: sqlstore.New(db, dialect)}, nil
+}
+
+// duplicateError maps a unique violation to the matching domain error, or
+// returns nil if err is not one. SQLite only names the column in the message,
+// e.g. "UNIQUE constraint failed: users.email".
+func duplicateError(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return nil
+	}
+	switch {
+	case strings.Contains(sqliteErr.Error(), "users.email"):
+		return domain.ErrEmailAlreadyExists
+	case strings.Contains(sqliteErr.Error(), "users.phone_number"):
+		return domain.ErrPhoneAlreadyExists
+	}
+	return nil
+}
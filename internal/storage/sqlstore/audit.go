@@ -0,0 +1,110 @@
+// internal/storage/sqlstore/audit.go
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// recordChange writes the change event, the audit record, the snapshot and
+// the search tokens of a change from before to after as part of tx
+func (s *Store) recordChange(ctx context.Context, tx *sql.Tx, before, after *domain.User) error {
+	event := domain.NewUserEvent(before, after)
+	if err := s.insertEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	if err := s.insertAuditRecord(ctx, tx, audit.NewRecord(ctx, event)); err != nil {
+		return err
+	}
+	if err := s.insertVersions(ctx, tx, before, after); err != nil {
+		return err
+	}
+	return s.updateSearchTokens(ctx, tx, before, after)
+}
+
+func (s *Store) insertAuditRecord(ctx context.Context, tx *sql.Tx, record *domain.AuditRecord) error {
+	changes, err := json.Marshal(record.Changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+	query := `INSERT INTO user_audit (id, user_id, action, method, actor, reason, request_id, changes, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := s.exec(ctx, tx, query,
+		record.ID, record.UserID, string(record.Action), record.Method, record.Actor,
+		record.Reason, record.RequestID, string(changes), record.OccurredAt,
+	); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// ListAuditRecords lists a user's audit records, newest first. Records are
+// numbered in insert order, which for one user is the order of the changes
+// since each change holds the user's row lock; the page token is the number
+// of the last record on the previous page.
+func (s *Store) ListAuditRecords(ctx context.Context, userID string, opts storage.AuditListOptions) ([]*domain.AuditRecord, string, error) {
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if opts.PageToken != "" {
+		seq, err := strconv.ParseInt(opts.PageToken, 10, 64)
+		if err != nil {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+		conditions = append(conditions, "seq < ?")
+		args = append(args, seq)
+	}
+	if !opts.Since.IsZero() {
+		conditions = append(conditions, "occurred_at >= ?")
+		args = append(args, opts.Since)
+	}
+	if !opts.Until.IsZero() {
+		conditions = append(conditions, "occurred_at < ?")
+		args = append(args, opts.Until)
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, opts.Limit+1)
+
+	query := `SELECT seq, id, user_id, action, method, actor, reason, request_id, changes, occurred_at
+		FROM user_audit WHERE ` + strings.Join(conditions, " AND ") + ` ORDER BY seq DESC LIMIT ?`
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list audit records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*domain.AuditRecord
+	var seqs []int64
+	for rows.Next() {
+		var record domain.AuditRecord
+		var seq int64
+		var action, changes string
+		if err := rows.Scan(&seq, &record.ID, &record.UserID, &action, &record.Method, &record.Actor,
+			&record.Reason, &record.RequestID, &changes, &record.OccurredAt); err != nil {
+			return nil, "", fmt.Errorf("failed to list audit records: %w", err)
+		}
+		record.Action = domain.EventType(action)
+		if err := json.Unmarshal([]byte(changes), &record.Changes); err != nil {
+			return nil, "", fmt.Errorf("failed to decode audit changes: %w", err)
+		}
+		records = append(records, &record)
+		seqs = append(seqs, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list audit records: %w", err)
+	}
+
+	nextToken := ""
+	if len(records) > opts.Limit {
+		records = records[:opts.Limit]
+		nextToken = strconv.FormatInt(seqs[opts.Limit-1], 10)
+	}
+	return records, nextToken, nil
+}
@@ -0,0 +1,78 @@
+// internal/storage/sqlstore/export.go
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// exportBatchSize is the number of users ExportUsers reads per query
+const exportBatchSize = 1000
+
+// ExportUsers calls fn for every matching user, ordered by ID. It reads the
+// table in ID ranges of exportBatchSize users, so no query stays open while
+// fn runs and writers are never held up for the whole export.
+func (s *Store) ExportUsers(ctx context.Context, opts storage.ExportOptions, fn func(*domain.User) error) error {
+	conditions := []string{"id > ?"}
+	var filters []interface{}
+	if !opts.ShowDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if opts.Blocked != nil {
+		conditions = append(conditions, "is_blocked = ?")
+		filters = append(filters, *opts.Blocked)
+	}
+	if !opts.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		filters = append(filters, opts.CreatedFrom)
+	}
+	if !opts.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		filters = append(filters, opts.CreatedTo)
+	}
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY id LIMIT ?`
+
+	after := ""
+	for {
+		args := append([]interface{}{after}, filters...)
+		users, err := s.exportBatch(ctx, query, append(args, exportBatchSize)...)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if len(users) < exportBatchSize {
+			return nil
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+func (s *Store) exportBatch(ctx context.Context, query string, args ...interface{}) ([]*domain.User, error) {
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0, exportBatchSize)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to export users: %w", err)
+	}
+	return users, nil
+}
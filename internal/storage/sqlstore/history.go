@@ -0,0 +1,107 @@
+// internal/storage/sqlstore/history.go
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+// insertVersions writes the snapshot of after as part of tx. The snapshot of
+// before is written too if it is missing, which is the case for users last
+// changed before snapshots were kept.
+func (s *Store) insertVersions(ctx context.Context, tx *sql.Tx, before, after *domain.User) error {
+	for _, user := range []*domain.User{before, after} {
+		if user == nil {
+			continue
+		}
+		payload, err := json.Marshal(user)
+		if err != nil {
+			return fmt.Errorf("failed to encode user version: %w", err)
+		}
+		query := `INSERT INTO user_versions (user_id, version, updated_at, payload)
+			VALUES (?, ?, ?, ?) ON CONFLICT (user_id, version) DO NOTHING`
+		if _, err := s.exec(ctx, tx, query, user.ID, user.Version, user.UpdatedAt, string(payload)); err != nil {
+			return fmt.Errorf("failed to write user version: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListUserVersions lists a user's snapshots, newest first. The page token is
+// the version of the last snapshot on the previous page.
+func (s *Store) ListUserVersions(ctx context.Context, userID string, opts storage.VersionListOptions) ([]*domain.User, string, error) {
+	query := `SELECT payload FROM user_versions WHERE user_id = ?`
+	args := []interface{}{userID}
+	if opts.PageToken != "" {
+		last, err := strconv.ParseInt(opts.PageToken, 10, 64)
+		if err != nil {
+			return nil, "", domain.ErrInvalidPageToken
+		}
+		query += ` AND version < ?`
+		args = append(args, last)
+	}
+	// One extra row tells whether there is a next page
+	query += ` ORDER BY version DESC LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list user versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*domain.User
+	for rows.Next() {
+		user, err := scanVersion(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		versions = append(versions, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list user versions: %w", err)
+	}
+
+	nextToken := ""
+	if len(versions) > opts.Limit {
+		versions = versions[:opts.Limit]
+		nextToken = strconv.FormatInt(versions[opts.Limit-1].Version, 10)
+	}
+	return versions, nextToken, nil
+}
+
+// GetUserVersion returns the snapshot of a user at version
+func (s *Store) GetUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error) {
+	query := `SELECT payload FROM user_versions WHERE user_id = ? AND version = ?`
+	return scanVersion(s.queryRow(ctx, s.db, query, userID, version))
+}
+
+// GetUserAtTime returns the newest snapshot of a user updated at or before t
+func (s *Store) GetUserAtTime(ctx context.Context, userID string, t time.Time) (*domain.User, error) {
+	query := `SELECT payload FROM user_versions WHERE user_id = ? AND updated_at <= ?
+		ORDER BY version DESC LIMIT 1`
+	return scanVersion(s.queryRow(ctx, s.db, query, userID, t))
+}
+
+func scanVersion(row rowScanner) (*domain.User, error) {
+	var payload string
+	if err := row.Scan(&payload); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to get user version: %w", err)
+	}
+	var user domain.User
+	if err := json.Unmarshal([]byte(payload), &user); err != nil {
+		return nil, fmt.Errorf("failed to decode user version: %w", err)
+	}
+	return &user, nil
+}
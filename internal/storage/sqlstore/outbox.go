@@ -0,0 +1,69 @@
+// internal/storage/sqlstore/outbox.go
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+)
+
+// insertEvent adds event to the outbox as part of tx. The outbox_events
+// table numbers rows in insert order, which PendingEvents returns them in.
+func (s *Store) insertEvent(ctx context.Context, tx *sql.Tx, event *domain.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	query := `INSERT INTO outbox_events (id, event_type, user_id, payload, occurred_at)
+		VALUES (?, ?, ?, ?, ?)`
+	if _, err := s.exec(ctx, tx, query, event.ID, string(event.Type), event.UserID, string(payload), event.OccurredAt); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// PendingEvents returns up to limit pending events, oldest first
+func (s *Store) PendingEvents(ctx context.Context, limit int) ([]*domain.Event, error) {
+	rows, err := s.query(ctx, s.db, `SELECT payload FROM outbox_events ORDER BY seq LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*domain.Event
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+		var event domain.Event
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	return events, nil
+}
+
+// AckEvents removes delivered events from the outbox
+func (s *Store) AckEvents(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if _, err := s.exec(ctx, s.db, `DELETE FROM outbox_events WHERE id IN (`+placeholders+`)`, args...); err != nil {
+		return fmt.Errorf("failed to acknowledge events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// internal/storage/sqlstore/search.go
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+var _ storage.SearchIndexer = (*Store)(nil)
+
+// updateSearchTokens brings the search tokens of a user changed from before
+// to after up to date as part of tx
+func (s *Store) updateSearchTokens(ctx context.Context, tx *sql.Tx, before, after *domain.User) error {
+	removed, added := search.Changes(before, after)
+	if len(removed) > 0 {
+		query := `DELETE FROM user_search_tokens WHERE user_id = ? AND token IN (` + placeholders(len(removed)) + `)`
+		args := []interface{}{after.ID}
+		for _, token := range removed {
+			args = append(args, token)
+		}
+		if _, err := s.exec(ctx, tx, query, args...); err != nil {
+			return fmt.Errorf("failed to remove search tokens: %w", err)
+		}
+	}
+	return s.insertSearchTokens(ctx, tx, after.ID, added)
+}
+
+func (s *Store) insertSearchTokens(ctx context.Context, tx *sql.Tx, userID string, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	values := make([]string, len(tokens))
+	args := make([]interface{}, 0, 2*len(tokens))
+	for i, token := range tokens {
+		values[i] = "(?, ?)"
+		args = append(args, token, userID)
+	}
+	query := `INSERT INTO user_search_tokens (token, user_id) VALUES ` + strings.Join(values, ", ") +
+		` ON CONFLICT (token, user_id) DO NOTHING`
+	if _, err := s.exec(ctx, tx, query, args...); err != nil {
+		return fmt.Errorf("failed to write search tokens: %w", err)
+	}
+	return nil
+}
+
+// SearchUsers finds the users that have a token starting with every term of
+// the query, one token range per term, and ranks them. It reads at most one
+// more than search.MaxCandidates users, enough for search.Rank to tell that
+// there are too many.
+func (s *Store) SearchUsers(ctx context.Context, opts storage.SearchOptions) ([]*domain.User, string, error) {
+	ranges := make([]string, len(opts.Query.Terms))
+	var args []interface{}
+	for i, term := range opts.Query.Terms {
+		ranges[i] = `SELECT user_id FROM user_search_tokens WHERE token >= ? AND token <= ?`
+		args = append(args, term, search.PrefixEnd(term))
+	}
+	query := `SELECT ` + userColumns + ` FROM users
+		WHERE id IN (` + strings.Join(ranges, " INTERSECT ") + `) AND (? OR deleted_at IS NULL)
+		LIMIT ?`
+	// One extra row tells whether there are too many
+	args = append(args, opts.ShowDeleted, search.MaxCandidates+1)
+
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []*domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %w", err)
+		}
+		candidates = append(candidates, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to search users: %w", err)
+	}
+	return search.Rank(opts.Query, candidates, opts.Limit, opts.PageToken)
+}
+
+// RebuildSearchIndex rewrites the search tokens of every user, deleted ones
+// included, one transaction per user
+func (s *Store) RebuildSearchIndex(ctx context.Context) (int, error) {
+	indexed := 0
+	err := s.ExportUsers(ctx, storage.ExportOptions{ShowDeleted: true}, func(user *domain.User) error {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := s.exec(ctx, tx, `DELETE FROM user_search_tokens WHERE user_id = ?`, user.ID); err != nil {
+				return fmt.Errorf("failed to remove search tokens: %w", err)
+			}
+			return s.insertSearchTokens(ctx, tx, user.ID, search.Tokens(user))
+		})
+		if err != nil {
+			return err
+		}
+		indexed++
+		return nil
+	})
+	return indexed, err
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,505 @@
+// internal/storage/sqlstore/sqlstore.go
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+)
+
+var (
+	_ storage.Storage = (*Store)(nil)
+	_ storage.Outbox  = (*Store)(nil)
+)
+
+// userColumns lists the users table columns in the order scanUser reads them
+const userColumns = `id, first_name, last_name, gender, date_of_birth,
+	phone_number, email, email_canonical, is_blocked, created_at, updated_at, version, deleted_at`
+
+// Dialect describes how a SQL database differs from the queries Store
+// writes, which use ? placeholders
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th argument, counting
+	// from 1. Nil keeps the ? placeholders.
+	Placeholder func(n int) string
+	// ForUpdate is appended to SELECTs that must lock the row they read
+	ForUpdate string
+	// DuplicateError maps a unique violation on the email or phone column to
+	// domain.ErrEmailAlreadyExists or domain.ErrPhoneAlreadyExists. It
+	// returns nil for any other error.
+	DuplicateError func(err error) error
+}
+
+// Store implements storage.Storage on top of database/sql. The schema is
+// owned by the driver packages that construct it.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+func New(db *sql.DB, dialect Dialect) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*domain.User, error) {
+	var user domain.User
+	var canonicalEmail sql.NullString
+	var deletedAt sql.NullTime
+	if err := row.Scan(
+		&user.ID,
+		&user.FirstName,
+		&user.LastName,
+		&user.Gender,
+		&user.DateOfBirth,
+		&user.PhoneNumber,
+		&user.Email,
+		&canonicalEmail,
+		&user.IsBlocked,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+		&deletedAt,
+	); err != nil {
+		return nil, err
+	}
+	user.CanonicalEmail = canonicalEmail.String
+	if deletedAt.Valid {
+		user.DeletedAt = deletedAt.Time
+	}
+	return &user, nil
+}
+
+// nullTime binds a zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// CreateUser creates a new user. Email and phone uniqueness is enforced by
+// the table's unique constraints.
+func (s *Store) CreateUser(ctx context.Context, user *domain.User) error {
+	query := `INSERT INTO users (` + userColumns + `, last_name_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.exec(ctx, tx, query,
+			user.ID,
+			user.FirstName,
+			user.LastName,
+			user.Gender,
+			user.DateOfBirth,
+			user.PhoneNumber,
+			user.Email,
+			user.EmailKey(),
+			user.IsBlocked,
+			user.CreatedAt,
+			user.UpdatedAt,
+			user.Version,
+			nullTime(user.DeletedAt),
+			search.Fold(user.LastName),
+		); err != nil {
+			if dupErr := s.dialect.DuplicateError(err); dupErr != nil {
+				return dupErr
+			}
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		return s.recordChange(ctx, tx, nil, user)
+	})
+}
+
+// GetUserByID retrieves a user by ID
+func (s *Store) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	return s.getUser(ctx, "id", id)
+}
+
+// GetUserByPhone retrieves a user by phone number
+func (s *Store) GetUserByPhone(ctx context.Context, phone string) (*domain.User, error) {
+	return s.getUser(ctx, "phone_number", phone)
+}
+
+// GetUserByEmail retrieves a user by canonical email
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return s.getUser(ctx, "email_canonical", email)
+}
+
+// getUser reads the user whose column equals value. column is always one of
+// the unique columns above, never user input.
+func (s *Store) getUser(ctx context.Context, column, value string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + column + ` = ?`
+
+	user, err := scanUser(s.queryRow(ctx, s.db, query, value))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUser updates an existing user. The version check, the write and the
+// change event run in one transaction that holds the row lock, so they can't
+// interleave with another update.
+func (s *Store) UpdateUser(ctx context.Context, user *domain.User) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		existing, err := s.lockUser(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Version != user.Version {
+			return domain.ErrVersionConflict
+		}
+
+		query := `UPDATE users SET first_name = ?, last_name = ?, last_name_key = ?, gender = ?,
+			date_of_birth = ?, phone_number = ?, email = ?, email_canonical = ?,
+			is_blocked = ?, updated_at = ?, version = version + 1, deleted_at = ?
+			WHERE id = ?`
+
+		if _, err := s.exec(ctx, tx, query,
+			user.FirstName, user.LastName, search.Fold(user.LastName), user.Gender,
+			user.DateOfBirth, user.PhoneNumber, user.Email, user.EmailKey(),
+			user.IsBlocked, user.UpdatedAt, nullTime(user.DeletedAt),
+			user.ID,
+		); err != nil {
+			if dupErr := s.dialect.DuplicateError(err); dupErr != nil {
+				return dupErr
+			}
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+
+		user.Version++
+		return s.recordChange(ctx, tx, existing, user)
+	})
+}
+
+// DeleteUser soft-deletes a user. The email and phone stay reserved by the
+// unique constraints until the user is purged. The version is checked on the
+// locked row, so no update can slip in between.
+func (s *Store) DeleteUser(ctx context.Context, id string, version *int64) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		user, err := s.lockUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if user.IsDeleted() {
+			return domain.ErrUserNotFound
+		}
+		if version != nil && user.Version != *version {
+			return domain.ErrVersionConflict
+		}
+
+		before := *user
+		user.Delete()
+		query := `UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1
+			WHERE id = ?`
+		if _, err := s.exec(ctx, tx, query, user.DeletedAt, user.UpdatedAt, id); err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		user.Version++
+		return s.recordChange(ctx, tx, &before, user)
+	})
+}
+
+// RestoreUser undoes a soft delete. The version is checked on the locked row,
+// as in DeleteUser.
+func (s *Store) RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error) {
+	var user *domain.User
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = s.lockUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted() {
+			return domain.ErrUserNotDeleted
+		}
+		if version != nil && user.Version != *version {
+			return domain.ErrVersionConflict
+		}
+
+		before := *user
+		user.Restore()
+		query := `UPDATE users SET deleted_at = NULL, updated_at = ?, version = version + 1
+			WHERE id = ?`
+		if _, err := s.exec(ctx, tx, query, user.UpdatedAt, id); err != nil {
+			return fmt.Errorf("failed to restore user: %w", err)
+		}
+		user.Version++
+		return s.recordChange(ctx, tx, &before, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// PurgeDeletedUsers hard-deletes users soft-deleted before the cutoff,
+// together with their snapshots and search tokens
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	var purged int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		query := `DELETE FROM user_versions
+			WHERE user_id IN (SELECT id FROM users WHERE deleted_at < ?)`
+		if _, err := s.exec(ctx, tx, query, before); err != nil {
+			return fmt.Errorf("failed to purge user versions: %w", err)
+		}
+		query = `DELETE FROM user_search_tokens
+			WHERE user_id IN (SELECT id FROM users WHERE deleted_at < ?)`
+		if _, err := s.exec(ctx, tx, query, before); err != nil {
+			return fmt.Errorf("failed to purge search tokens: %w", err)
+		}
+		result, err := s.exec(ctx, tx, `DELETE FROM users WHERE deleted_at < ?`, before)
+		if err != nil {
+			return fmt.Errorf("failed to purge users: %w", err)
+		}
+		purged, err = result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to purge users: %w", err)
+		}
+		return nil
+	})
+	return int(purged), err
+}
+
+// ListUsers lists the matching users in opts.Order, by ID if not set, using
+// keyset pagination. The page token is the ID of the last user on the
+// previous page, or its listquery cursor when ordered by a field.
+func (s *Store) ListUsers(ctx context.Context, opts storage.ListOptions) ([]*domain.User, string, error) {
+	conditions, args := filterConditions(opts.Filter)
+	if !opts.ShowDeleted {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	order := opts.Order
+	orderBy := "id"
+	switch {
+	case order.Field == "":
+		if opts.PageToken != "" {
+			conditions = append(conditions, "id > ?")
+			args = append(args, opts.PageToken)
+		}
+	default:
+		// Last names are ordered by last_name_key, the key listquery orders
+		// them by
+		column := order.Field
+		if column == listquery.FieldLastName {
+			column = "last_name_key"
+		}
+		direction, cmp := "ASC", ">"
+		if order.Desc {
+			direction, cmp = "DESC", "<"
+		}
+		if opts.PageToken != "" {
+			key, id, err := order.ParseCursor(opts.PageToken)
+			if err != nil {
+				return nil, "", err
+			}
+			conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp))
+			args = append(args, key, key, id)
+		}
+		orderBy = column + " " + direction + ", id " + direction
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to learn whether there is a next page
+	query := `SELECT ` + userColumns + ` FROM users` + where + ` ORDER BY ` + orderBy + ` LIMIT ?`
+	args = append(args, opts.Limit+1)
+
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0, opts.Limit)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to list users: %w", err)
+	}
+
+	nextPageToken := ""
+	if len(users) > opts.Limit {
+		users = users[:opts.Limit]
+		nextPageToken = users[len(users)-1].ID
+		if order.Field != "" {
+			nextPageToken = order.Cursor(users[len(users)-1])
+		}
+	}
+	return users, nextPageToken, nil
+}
+
+// filterConditions turns f into WHERE conditions and their arguments
+func filterConditions(f listquery.Filter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Blocked != nil {
+		conditions = append(conditions, "is_blocked = ?")
+		args = append(args, *f.Blocked)
+	}
+	if f.Gender != "" {
+		conditions = append(conditions, "gender = ?")
+		args = append(args, f.Gender)
+	}
+	for _, r := range []struct {
+		column string
+		listquery.Range
+	}{
+		{"created_at", f.CreatedAt},
+		{"updated_at", f.UpdatedAt},
+		{"date_of_birth", f.DateOfBirth},
+	} {
+		if !r.From.IsZero() {
+			conditions = append(conditions, r.column+" >= ?")
+			args = append(args, r.From)
+		}
+		if !r.To.IsZero() {
+			conditions = append(conditions, r.column+" < ?")
+			args = append(args, r.To)
+		}
+	}
+	return conditions, args
+}
+
+// CountUsers counts users that are not deleted
+func (s *Store) CountUsers(ctx context.Context, blocked *bool) (int64, error) {
+	query := `SELECT count(*) FROM users WHERE deleted_at IS NULL`
+	args := []interface{}{}
+	if blocked != nil {
+		query += ` AND is_blocked = ?`
+		args = append(args, *blocked)
+	}
+
+	var count int64
+	if err := s.queryRow(ctx, s.db, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count, nil
+}
+
+// CheckEmailExists checks if a canonical email already exists
+func (s *Store) CheckEmailExists(ctx context.Context, email string) (bool, error) {
+	return s.exists(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE email_canonical = ?)`, email)
+}
+
+// CheckPhoneExists checks if phone already exists
+func (s *Store) CheckPhoneExists(ctx context.Context, phone string) (bool, error) {
+	return s.exists(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE phone_number = ?)`, phone)
+}
+
+func (s *Store) exists(ctx context.Context, query, value string) (bool, error) {
+	var exists bool
+	if err := s.queryRow(ctx, s.db, query, value).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check existence: %w", err)
+	}
+	return exists, nil
+}
+
+// Ping checks that the database answers. database/sql reconnects on its own,
+// so there is no Reconnect.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database connection
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// lockUser reads the user's row and locks it until tx ends
+func (s *Store) lockUser(ctx context.Context, tx *sql.Tx, id string) (*domain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ?` + s.dialect.ForUpdate
+	user, err := scanUser(s.queryRow(ctx, tx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) exec(ctx context.Context, q querier, query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(ctx, s.rebind(query), bindArgs(args)...)
+}
+
+func (s *Store) query(ctx context.Context, q querier, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(ctx, s.rebind(query), bindArgs(args)...)
+}
+
+func (s *Store) queryRow(ctx context.Context, q querier, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, s.rebind(query), bindArgs(args)...)
+}
+
+// rebind rewrites ? placeholders into the dialect's form
+func (s *Store) rebind(query string) string {
+	if s.dialect.Placeholder == nil {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(s.dialect.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bindArgs stores every time in UTC. SQLite keeps timestamps as text, so
+// mixed offsets would break comparisons like the purge cutoff.
+func bindArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[i] = t.UTC()
+		}
+	}
+	return args
+}
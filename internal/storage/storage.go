@@ -3,19 +3,165 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/search"
 )
 
+// ListOptions controls which users ListUsers returns
+type ListOptions struct {
+	Limit int
+	// PageToken is the backend-specific cursor returned with the previous
+	// page, not the opaque token handed to clients. It is only valid with the
+	// same Filter and Order.
+	PageToken string
+	// ShowDeleted includes soft-deleted users
+	ShowDeleted bool
+	Filter      listquery.Filter
+	Order       listquery.Order
+}
+
+// AuditListOptions controls which audit records ListAuditRecords returns
+type AuditListOptions struct {
+	Limit int
+	// PageToken is the backend-specific cursor returned with the previous
+	// page
+	PageToken string
+	// Since and Until bound OccurredAt: Since is inclusive, Until exclusive.
+	// A zero time leaves that end open.
+	Since time.Time
+	Until time.Time
+}
+
+// VersionListOptions controls which snapshots ListUserVersions returns
+type VersionListOptions struct {
+	Limit int
+	// PageToken is the backend-specific cursor returned with the previous
+	// page
+	PageToken string
+}
+
+// SearchOptions controls which users SearchUsers returns
+type SearchOptions struct {
+	Query search.Query
+	Limit int
+	// PageToken is the cursor returned with the previous page
+	PageToken string
+	// ShowDeleted includes soft-deleted users
+	ShowDeleted bool
+}
+
+// ExportOptions controls which users ExportUsers visits
+type ExportOptions struct {
+	// Blocked, if not nil, keeps only users in that blocked state
+	Blocked *bool
+	// CreatedFrom and CreatedTo bound CreatedAt: CreatedFrom is inclusive,
+	// CreatedTo exclusive. A zero time leaves that end open.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// ShowDeleted includes soft-deleted users
+	ShowDeleted bool
+}
+
+// Match reports whether user passes the filters in opts
+func (opts ExportOptions) Match(user *domain.User) bool {
+	if user.IsDeleted() && !opts.ShowDeleted {
+		return false
+	}
+	if opts.Blocked != nil && user.IsBlocked != *opts.Blocked {
+		return false
+	}
+	if !opts.CreatedFrom.IsZero() && user.CreatedAt.Before(opts.CreatedFrom) {
+		return false
+	}
+	if !opts.CreatedTo.IsZero() && !user.CreatedAt.Before(opts.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+// Storage persists users. Lookups by ID, phone and email return soft-deleted
+// users as well; it is up to the caller to hide them. Email uniqueness,
+// GetUserByEmail and CheckEmailExists use the canonical email, see
+// domain.User.EmailKey.
 type Storage interface {
 	CreateUser(ctx context.Context, user *domain.User) error
 	GetUserByID(ctx context.Context, id string) (*domain.User, error)
 	GetUserByPhone(ctx context.Context, phone string) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	// UpdateUser writes user only if the stored version equals user.Version,
+	// returning domain.ErrVersionConflict otherwise. On success user.Version
+	// is advanced to the new stored version.
 	UpdateUser(ctx context.Context, user *domain.User) error
-	DeleteUser(ctx context.Context, id string) error
-	ListUsers(ctx context.Context, limit int, pageToken string) ([]*domain.User, string, error)
+	// DeleteUser soft-deletes a user. The email and phone stay reserved until
+	// the user is purged. Deleting a missing or already deleted user returns
+	// domain.ErrUserNotFound. A non-nil version makes the delete conditional,
+	// like UpdateUser: if the stored version differs, nothing is written and
+	// domain.ErrVersionConflict is returned.
+	DeleteUser(ctx context.Context, id string, version *int64) error
+	// RestoreUser undoes a soft delete, returning domain.ErrUserNotDeleted if
+	// the user is not deleted. A non-nil version makes the restore
+	// conditional, like DeleteUser.
+	RestoreUser(ctx context.Context, id string, version *int64) (*domain.User, error)
+	// PurgeDeletedUsers hard-deletes users soft-deleted before the cutoff and
+	// frees their email and phone. It returns the number of users purged.
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error)
+	// ListUsers returns one page of the users that match opts.Filter, in
+	// opts.Order, and the cursor for the next page
+	ListUsers(ctx context.Context, opts ListOptions) ([]*domain.User, string, error)
+	// SearchUsers returns one page of the users that match opts.Query, most
+	// relevant first, and the cursor for the next page; see search.Rank.
+	// Database backends look the query up in a search index written with
+	// every change. At most search.MaxCandidates users that match are
+	// ranked: a query that more users match, not counting deleted ones
+	// unless opts.ShowDeleted, fails with domain.ErrSearchTooBroad rather
+	// than return some of them.
+	SearchUsers(ctx context.Context, opts SearchOptions) ([]*domain.User, string, error)
+	// ExportUsers calls fn for every user that matches opts, in no particular
+	// order, and stops at the first error fn returns. Unlike ListUsers it
+	// reads the whole table in one call, so it has no deadline of its own.
+	ExportUsers(ctx context.Context, opts ExportOptions, fn func(*domain.User) error) error
+	// CountUsers returns the number of users that are not deleted. A non-nil
+	// blocked restricts the count to users in that blocked state.
+	CountUsers(ctx context.Context, blocked *bool) (int64, error)
+	// ListAuditRecords returns one page of a user's audit records, newest
+	// first, and the cursor for the next page. Every change to a user writes
+	// a record along with the change (see domain.AuditRecord); records
+	// outlive the user.
+	ListAuditRecords(ctx context.Context, userID string, opts AuditListOptions) ([]*domain.AuditRecord, string, error)
+	// ListUserVersions returns one page of a user's snapshots, newest first,
+	// and the cursor for the next page. Every change to a user stores the
+	// user as it is after the change, keyed by version, along with the
+	// change (see domain.Event); purging the user removes them.
+	ListUserVersions(ctx context.Context, userID string, opts VersionListOptions) ([]*domain.User, string, error)
+	// GetUserVersion returns the snapshot of a user at version, or
+	// domain.ErrVersionNotFound
+	GetUserVersion(ctx context.Context, userID string, version int64) (*domain.User, error)
+	// GetUserAtTime returns the snapshot that was current at t: the newest
+	// one updated at or before t. It returns domain.ErrVersionNotFound if
+	// there is none.
+	GetUserAtTime(ctx context.Context, userID string, t time.Time) (*domain.User, error)
 	CheckEmailExists(ctx context.Context, email string) (bool, error)
 	CheckPhoneExists(ctx context.Context, phone string) (bool, error)
 	Close() error
-}
\ No newline at end of file
+}
+
+// SearchIndexer is implemented by backends whose search index lives in its
+// own table. RebuildSearchIndex writes the tokens of every user, for users
+// stored before the index existed, and returns the number of users indexed.
+type SearchIndexer interface {
+	RebuildSearchIndex(ctx context.Context) (int, error)
+}
+
+// Outbox gives the event dispatcher access to the user change events that
+// every backend writes together with the change itself. Events stay pending
+// until acknowledged, so a crash between publishing and acknowledging
+// publishes them again.
+type Outbox interface {
+	// PendingEvents returns up to limit unacknowledged events, oldest first
+	PendingEvents(ctx context.Context, limit int) ([]*domain.Event, error)
+	// AckEvents removes delivered events. Unknown IDs are ignored.
+	AckEvents(ctx context.Context, ids []string) error
+}
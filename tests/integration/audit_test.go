@@ -0,0 +1,68 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaAuditLog(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	// Time ranges resolve to milliseconds, so keep the records apart
+	time.Sleep(5 * time.Millisecond)
+	blockCtx := audit.WithInfo(ctx, audit.Info{
+		Method:    "BlockUser",
+		Actor:     "admin-7",
+		Reason:    "chargeback",
+		RequestID: "req-1",
+	})
+	user.Block()
+	require.NoError(t, db.UpdateUser(blockCtx, user))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	records, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, records, 3)
+	assert.Equal(t, domain.EventUserDeleted, records[0].Action)
+	assert.Equal(t, domain.EventUserCreated, records[2].Action)
+
+	blocked := records[1]
+	assert.Equal(t, domain.EventUserBlocked, blocked.Action)
+	assert.Equal(t, "admin-7", blocked.Actor)
+	assert.Equal(t, "chargeback", blocked.Reason)
+	assert.Equal(t, "req-1", blocked.RequestID)
+	assert.Equal(t, []domain.FieldChange{{Field: "is_blocked", OldValue: "false", NewValue: "true"}}, blocked.Changes)
+
+	first, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	require.NotEmpty(t, next)
+	rest, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 2, PageToken: next})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, rest, 1)
+	assert.Equal(t, records[2].ID, rest[0].ID)
+
+	ranged, _, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{
+		Limit: 10,
+		Since: blocked.OccurredAt,
+		Until: records[0].OccurredAt,
+	})
+	require.NoError(t, err)
+	require.Len(t, ranged, 1)
+	assert.Equal(t, blocked.ID, ranged[0].ID)
+}
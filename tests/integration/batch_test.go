@@ -0,0 +1,141 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lookupTTL returns the remaining TTL of a lookup row, or 0 if it has none
+func lookupTTL(t *testing.T, session *gocql.Session, table, column, value string) int {
+	var ttl *int
+	err := session.Query(`SELECT TTL(user_id) FROM `+table+` WHERE `+column+` = ?`, value).Scan(&ttl)
+	require.NoError(t, err)
+	if ttl == nil {
+		return 0
+	}
+	return *ttl
+}
+
+func TestScyllaCreateClaimsPermanentLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	assert.Zero(t, lookupTTL(t, session, "users_by_email", "email", user.Email))
+	assert.Zero(t, lookupTTL(t, session, "users_by_phone", "phone_number", user.PhoneNumber))
+}
+
+func TestScyllaCreateTakesOverAbandonedClaims(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	// Claims left by a create that died before writing its users row
+	user := newTestUser()
+	abandoned := gocql.TimeUUID().String()
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, session.Query(`INSERT INTO users_by_email (email, user_id, created_at) VALUES (?, ?, ?)`,
+		user.Email, abandoned, old).Exec())
+	require.NoError(t, session.Query(`INSERT INTO users_by_phone (phone_number, user_id, created_at) VALUES (?, ?, ?)`,
+		user.PhoneNumber, abandoned, old).Exec())
+
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	got, err := db.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	got, err = db.GetUserByPhone(ctx, user.PhoneNumber)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	// A fresh claim may still belong to an in-flight write
+	other := newTestUser()
+	require.NoError(t, session.Query(`INSERT INTO users_by_email (email, user_id, created_at) VALUES (?, ?, ?)`,
+		other.Email, abandoned, time.Now()).Exec())
+	t.Cleanup(func() { session.Query(`DELETE FROM users_by_email WHERE email = ?`, other.Email).Exec() })
+	assert.Equal(t, domain.ErrEmailAlreadyExists, db.CreateUser(ctx, other))
+}
+
+func TestScyllaUpdateContactMovesLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	oldEmail, oldPhone := user.Email, user.PhoneNumber
+	other := newTestUser()
+	user.UpdateContact(&other.PhoneNumber, &other.Email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+
+	got, err := db.GetUserByEmail(ctx, other.Email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	assert.Zero(t, lookupTTL(t, session, "users_by_email", "email", other.Email))
+	assert.Zero(t, lookupTTL(t, session, "users_by_phone", "phone_number", other.PhoneNumber))
+
+	exists, err := db.CheckEmailExists(ctx, oldEmail)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = db.CheckPhoneExists(ctx, oldPhone)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestScyllaPurgeRemovesLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	_, err := db.PurgeDeletedUsers(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+
+	exists, err := db.CheckEmailExists(ctx, user.Email)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = db.CheckPhoneExists(ctx, user.PhoneNumber)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestScyllaLookupsUseCanonicalEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	user.CanonicalEmail = user.Email
+	user.Email = "J.Doe+" + user.Email
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	var userID string
+	require.NoError(t, session.Query(`SELECT user_id FROM users_by_email WHERE email = ?`, user.CanonicalEmail).Scan(&userID))
+	assert.Equal(t, user.ID, userID)
+
+	got, err := db.GetUserByEmail(ctx, user.CanonicalEmail)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, got.Email)
+
+	exists, err := db.CheckEmailExists(ctx, user.Email)
+	require.NoError(t, err)
+	assert.False(t, exists, "only the canonical form is a key")
+}
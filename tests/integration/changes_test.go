@@ -0,0 +1,113 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptUpdate does what UpdateUser does up to the users row write and
+// stops there, as if the process died: it records the change from before to
+// after under a change ID from at, and writes the row pointing at it. If
+// apply is false, the row is left alone, as for an update that lost its
+// version check.
+func interruptUpdate(t *testing.T, session *gocql.Session, before, after *domain.User, at time.Time, apply bool) *domain.Event {
+	event := domain.NewUserEvent(before, after)
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":  event,
+		"record": audit.NewRecord(context.Background(), event),
+	})
+	require.NoError(t, err)
+
+	id := gocql.UUIDFromTime(at)
+	bucket := at.UTC().Truncate(time.Hour)
+	require.NoError(t, session.Query(`INSERT INTO pending_changes (bucket, id, user_id, version, payload) VALUES (?, ?, ?, ?, ?)`,
+		bucket, id, after.ID, after.Version, string(payload)).Exec())
+	if apply {
+		require.NoError(t, session.Query(`UPDATE users SET first_name = ?, version = ?, change_id = ? WHERE id = ?`,
+			after.FirstName, after.Version, id, after.ID).Exec())
+	}
+	return event
+}
+
+func pendingEventIDs(t *testing.T, db *scylla.ScyllaDB) map[string]bool {
+	events, err := db.PendingEvents(context.Background(), 10000)
+	require.NoError(t, err)
+	ids := make(map[string]bool)
+	for _, event := range events {
+		ids[event.ID] = true
+	}
+	return ids
+}
+
+func TestScyllaRetryIndexOpsFinishesInterruptedUpdates(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	// A change recorded by an update that lost its version check
+	lost := *user
+	lost.FirstName = "Lost"
+	lost.Version++
+	lostEvent := interruptUpdate(t, session, user, &lost, time.Now().Add(-2*time.Minute), false)
+
+	// A change whose users row was written before the update died
+	next := *user
+	next.FirstName = "Interrupted"
+	next.Version++
+	event := interruptUpdate(t, session, user, &next, time.Now().Add(-2*time.Minute), true)
+
+	_, err := db.GetUserVersion(ctx, user.ID, next.Version)
+	assert.Equal(t, domain.ErrVersionNotFound, err)
+
+	report, err := db.RetryIndexOps(ctx, scylla.RetryOptions{MinBackoff: time.Second, MaxBackoff: time.Minute})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Finished, 1)
+
+	snapshot, err := db.GetUserVersion(ctx, user.ID, next.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "Interrupted", snapshot.FirstName)
+	ids := pendingEventIDs(t, db)
+	assert.True(t, ids[event.ID])
+	assert.False(t, ids[lostEvent.ID])
+
+	var left int
+	require.NoError(t, session.Query(`SELECT COUNT(*) FROM pending_changes WHERE bucket = ? AND user_id = ? ALLOW FILTERING`,
+		time.Now().Add(-2*time.Minute).UTC().Truncate(time.Hour), user.ID).Scan(&left))
+	assert.Zero(t, left)
+}
+
+func TestScyllaUpdateFinishesInterruptedUpdate(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	// Too young for the retry job, so only the next update can finish it
+	next := *user
+	next.FirstName = "Interrupted"
+	next.Version++
+	event := interruptUpdate(t, session, user, &next, time.Now(), true)
+
+	next.Block()
+	require.NoError(t, db.UpdateUser(ctx, &next))
+
+	assert.True(t, pendingEventIDs(t, db)[event.ID])
+}
@@ -0,0 +1,40 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaRecountUsersResetsDrift(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	user := newTestUser()
+	user.Block()
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	total, blocked, err := db.RecountUsers(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, blocked)
+
+	// Counters from before the table existed, or a batch applied twice
+	session := newRawSession(t)
+	require.NoError(t, session.Query(`UPDATE user_counters SET value = value + 7 WHERE name = 'total'`).Exec())
+	require.NoError(t, session.Query(`UPDATE user_counters SET value = value - 3 WHERE name = 'blocked'`).Exec())
+
+	recounted, recountedBlocked, err := db.RecountUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, total, recounted)
+	assert.Equal(t, blocked, recountedBlocked)
+	count, err := db.CountUsers(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, total, count)
+	isBlocked := true
+	count, err = db.CountUsers(ctx, &isBlocked)
+	require.NoError(t, err)
+	assert.Equal(t, blocked, count)
+}
@@ -0,0 +1,62 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaExportUsers(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	active := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, active))
+	// created_at is stored in milliseconds, so keep the users apart
+	time.Sleep(5 * time.Millisecond)
+	blocked := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, blocked))
+	blocked.Block()
+	require.NoError(t, db.UpdateUser(ctx, blocked))
+	deleted := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, deleted))
+	require.NoError(t, db.DeleteUser(ctx, deleted.ID, nil))
+
+	// Other tests share the table, so only these users count
+	export := func(opts storage.ExportOptions) map[string]bool {
+		var mu sync.Mutex
+		seen := map[string]bool{}
+		require.NoError(t, db.ExportUsers(ctx, opts, func(user *domain.User) error {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.False(t, seen[user.ID], "user %s exported twice", user.ID)
+			seen[user.ID] = true
+			return nil
+		}))
+		return seen
+	}
+
+	seen := export(storage.ExportOptions{})
+	assert.True(t, seen[active.ID])
+	assert.True(t, seen[blocked.ID])
+	assert.False(t, seen[deleted.ID])
+
+	isBlocked := true
+	seen = export(storage.ExportOptions{Blocked: &isBlocked, ShowDeleted: true})
+	assert.False(t, seen[active.ID])
+	assert.True(t, seen[blocked.ID])
+	assert.False(t, seen[deleted.ID])
+
+	seen = export(storage.ExportOptions{CreatedFrom: blocked.CreatedAt.Truncate(time.Millisecond), ShowDeleted: true})
+	assert.False(t, seen[active.ID])
+	assert.True(t, seen[blocked.ID])
+	assert.True(t, seen[deleted.ID])
+}
@@ -0,0 +1,53 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaUserHistory(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	// Snapshot times resolve to milliseconds, so keep the versions apart
+	time.Sleep(5 * time.Millisecond)
+	user.Update("Jane", "Doe", "female", user.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	time.Sleep(5 * time.Millisecond)
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+
+	versions, next, err := db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, int64(3), versions[0].Version)
+	assert.True(t, versions[0].IsBlocked)
+	require.NotEmpty(t, next)
+	rest, next, err := db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 2, PageToken: next})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, rest, 1)
+	assert.Equal(t, "John", rest[0].FirstName)
+
+	second, err := db.GetUserVersion(ctx, user.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", second.FirstName)
+	_, err = db.GetUserVersion(ctx, user.ID, 4)
+	assert.Equal(t, domain.ErrVersionNotFound, err)
+
+	at, err := db.GetUserAtTime(ctx, user.ID, second.UpdatedAt.Add(time.Millisecond))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), at.Version)
+	_, err = db.GetUserAtTime(ctx, user.ID, rest[0].UpdatedAt.Add(-time.Second))
+	assert.Equal(t, domain.ErrVersionNotFound, err)
+}
@@ -0,0 +1,55 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/importer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaImportUsers(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	existing := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, existing))
+	fresh := []*domain.User{newTestUser(), newTestUser(), newTestUser()}
+
+	var buf bytes.Buffer
+	enc := export.NewEncoder(&buf, export.FormatJSONL)
+	for _, user := range append([]*domain.User{existing}, fresh...) {
+		require.NoError(t, enc.Encode(user))
+	}
+	file := buf.String()
+
+	report, err := importer.Import(ctx, db, strings.ToLower, strings.NewReader(file), importer.Options{
+		Mode:        importer.ModeSkipDuplicates,
+		Concurrency: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 3, report.Created)
+	for _, user := range fresh {
+		got, err := db.GetUserByEmail(ctx, user.Email)
+		require.NoError(t, err)
+		assert.Equal(t, user.PhoneNumber, got.PhoneNumber)
+	}
+
+	// The same file as an upsert changes nothing
+	report, err = importer.Import(ctx, db, strings.ToLower, strings.NewReader(file), importer.Options{
+		Mode: importer.ModeUpsert,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Unchanged)
+	got, err := db.GetUserByID(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), got.Version)
+}
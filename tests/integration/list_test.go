@@ -0,0 +1,102 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listOwn pages through db and returns the IDs among ids, in the order listed
+func listOwn(t *testing.T, db storage.Storage, filter, order string, ids map[string]bool) []string {
+	f, err := listquery.ParseFilter(filter)
+	require.NoError(t, err)
+	o, err := listquery.ParseOrder(order)
+	require.NoError(t, err)
+	opts := storage.ListOptions{Limit: 3, Filter: f, Order: o}
+
+	var own []string
+	for page := 0; ; page++ {
+		require.Less(t, page, 10000)
+		users, next, err := db.ListUsers(context.Background(), opts)
+		require.NoError(t, err)
+		for _, user := range users {
+			if ids[user.ID] {
+				own = append(own, user.ID)
+			}
+		}
+		if next == "" {
+			return own
+		}
+		opts.PageToken = next
+	}
+}
+
+func TestScyllaListUsersFilteredAndOrdered(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	// Other runs may have users in the same months; only ours are checked
+	base := time.Now().UTC().Truncate(time.Millisecond).AddDate(-30, 0, 0)
+	ids := map[string]bool{}
+	var created []string
+	for i, name := range []string{"Moss", "Adams", "Zhou", "Baker"} {
+		user := newTestUser()
+		user.LastName = fmt.Sprintf("%s%d", name, base.UnixNano())
+		if i == 3 {
+			user.Block()
+		}
+		user.CreatedAt = base.AddDate(0, 0, 40*i)
+		require.NoError(t, db.CreateUser(ctx, user))
+		ids[user.ID] = true
+		created = append(created, user.ID)
+	}
+
+	window := fmt.Sprintf("created_at >= %q AND created_at < %q",
+		base.Format(time.RFC3339Nano), base.AddDate(0, 0, 200).Format(time.RFC3339Nano))
+	assert.Equal(t, created, listOwn(t, db, window, "created_at", ids))
+	assert.Equal(t, []string{created[3], created[2], created[1], created[0]}, listOwn(t, db, window, "created_at desc", ids))
+	assert.Equal(t, created[:3], listOwn(t, db, window+" AND is_blocked = false", "", ids))
+	assert.Equal(t, []string{created[1], created[3], created[0], created[2]}, listOwn(t, db, "", "last_name", ids))
+
+	// A renamed user moves, a deleted one drops out
+	user, err := db.GetUserByID(ctx, created[2])
+	require.NoError(t, err)
+	user.Update(user.FirstName, fmt.Sprintf("Able%d", base.UnixNano()), user.Gender, user.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, created[0], nil))
+	assert.Equal(t, []string{created[2], created[1], created[3]}, listOwn(t, db, "", "last_name", ids))
+	assert.Equal(t, created[1:], listOwn(t, db, window, "", ids))
+}
+
+func TestScyllaListUsersByLastNameIgnoresCaseAndAccents(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	// Spread over the shards of one bucket group; the merge must keep the order
+	stamp := time.Now().UnixNano()
+	ids := map[string]bool{}
+	var want []string
+	for _, name := range []string{"qadir%d-a", "Qádir%d-B", "QADIR%d-c", "qadir%d-D", "Qadir%d-e", "qádir%d-F", "QAdir%d-g"} {
+		user := newTestUser()
+		user.LastName = fmt.Sprintf(name, stamp)
+		require.NoError(t, db.CreateUser(ctx, user))
+		t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+		ids[user.ID] = true
+		want = append(want, user.ID)
+	}
+
+	assert.Equal(t, want, listOwn(t, db, "", "last_name", ids))
+	reversed := make([]string, len(want))
+	for i, id := range want {
+		reversed[len(want)-1-i] = id
+	}
+	assert.Equal(t, reversed, listOwn(t, db, "", "last_name desc", ids))
+}
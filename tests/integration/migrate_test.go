@@ -0,0 +1,120 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaMigrateUpIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	migrator, err := scylla.NewMigrator(scyllaTestOptions(),
+		scylla.Replication{Class: "NetworkTopologyStrategy", Factor: 1})
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	// Everything is applied now, so a second run is a no-op
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.True(t, s.Applied, "migration %d not applied", s.Version)
+	}
+}
+
+// TestScyllaMigrateUpFromBaselineSchema brings a keyspace created from the
+// schema that predates migrations up to date, with a user already in it
+func TestScyllaMigrateUpFromBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	const keyspace = "userservice_baseline"
+	opts := scyllaTestOptions()
+
+	cluster := gocql.NewCluster(opts.Hosts...)
+	cluster.Consistency = gocql.Quorum
+	cluster.Timeout = 30 * time.Second
+	admin, err := cluster.CreateSession()
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	require.NoError(t, admin.Query(`DROP KEYSPACE IF EXISTS `+keyspace).Exec())
+	require.NoError(t, admin.Query(`CREATE KEYSPACE `+keyspace+
+		` WITH replication = {'class': 'NetworkTopologyStrategy', 'replication_factor': 1}`).Exec())
+	t.Cleanup(func() { admin.Query(`DROP KEYSPACE IF EXISTS ` + keyspace).Exec() })
+
+	cluster.Keyspace = keyspace
+	session, err := cluster.CreateSession()
+	require.NoError(t, err)
+	defer session.Close()
+	schema, err := os.ReadFile("../testdata/scylla_baseline_schema.cql")
+	require.NoError(t, err)
+	for _, stmt := range strings.Split(string(schema), ";") {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		if stmt = strings.TrimSpace(strings.Join(lines, "\n")); stmt != "" {
+			require.NoError(t, session.Query(stmt).Exec(), stmt)
+		}
+	}
+
+	// A user written by the service before migrations
+	legacy := newTestUser()
+	require.NoError(t, session.Query(`INSERT INTO users (id, first_name, last_name, gender, date_of_birth,
+		phone_number, email, is_blocked, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		legacy.ID, legacy.FirstName, legacy.LastName, legacy.Gender, legacy.DateOfBirth,
+		legacy.PhoneNumber, legacy.Email, false, legacy.CreatedAt, legacy.UpdatedAt).Exec())
+	require.NoError(t, session.Query(`INSERT INTO users_by_email (email, user_id, created_at) VALUES (?, ?, ?)`,
+		legacy.Email, legacy.ID, legacy.CreatedAt).Exec())
+	require.NoError(t, session.Query(`INSERT INTO users_by_phone (phone_number, user_id, created_at) VALUES (?, ?, ?)`,
+		legacy.PhoneNumber, legacy.ID, legacy.CreatedAt).Exec())
+
+	opts.Keyspace = keyspace
+	migrator, err := scylla.NewMigrator(opts, scylla.Replication{Class: "NetworkTopologyStrategy", Factor: 1})
+	require.NoError(t, err)
+	defer migrator.Close()
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	db, err := scylla.NewScyllaDB(opts)
+	require.NoError(t, err)
+	defer db.Close()
+
+	user, err := db.GetUserByID(ctx, legacy.ID)
+	require.NoError(t, err)
+	assert.Zero(t, user.Version)
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	assert.Equal(t, int64(1), user.Version)
+
+	fresh := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, fresh))
+	require.NoError(t, db.DeleteUser(ctx, fresh.ID, nil))
+	users, _, err := db.ListUsers(ctx, storage.ListOptions{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, legacy.ID, users[0].ID)
+
+	total, blocked, err := db.RecountUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, int64(1), blocked)
+	_, err = db.GetUserByEmail(ctx, legacy.Email)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,54 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaWritesEventsWithChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	other := newTestUser()
+	user.UpdateContact(&other.PhoneNumber, &other.Email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	// Other tests write to the outbox too, so only this user's events count
+	pending, err := db.PendingEvents(ctx, 100000)
+	require.NoError(t, err)
+	var mine []*domain.Event
+	var ids []string
+	for _, event := range pending {
+		if event.UserID == user.ID {
+			mine = append(mine, event)
+			ids = append(ids, event.ID)
+		}
+	}
+	require.Len(t, mine, 4)
+	assert.Equal(t, domain.EventUserCreated, mine[0].Type)
+	assert.Equal(t, domain.EventUserBlocked, mine[1].Type)
+	assert.Equal(t, domain.EventUserContactChanged, mine[2].Type)
+	assert.Equal(t, other.Email, mine[2].After.Email)
+	assert.Equal(t, domain.EventUserDeleted, mine[3].Type)
+	for i, event := range mine {
+		assert.Equal(t, int64(i+1), event.After.Version)
+	}
+
+	require.NoError(t, db.AckEvents(ctx, ids))
+	pending, err = db.PendingEvents(ctx, 100000)
+	require.NoError(t, err)
+	for _, event := range pending {
+		assert.NotEqual(t, user.ID, event.UserID)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaRetryIndexOps(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	// Simulate a contact change whose lookup writes failed: the new email
+	// has no lookup row and the old one is still in place
+	oldEmail := user.Email
+	newEmail := newTestUser().Email
+	require.NoError(t, session.Query(`UPDATE users SET email = ? WHERE id = ?`, newEmail, user.ID).Exec())
+
+	enqueue := `INSERT INTO pending_index_ops (id, table_name, value, user_id, op, attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?)`
+	now := time.Now()
+	require.NoError(t, session.Query(enqueue, gocql.TimeUUID(), "users_by_email", newEmail, user.ID, "put", now, now).Exec())
+	require.NoError(t, session.Query(enqueue, gocql.TimeUUID(), "users_by_email", oldEmail, user.ID, "delete", now, now).Exec())
+
+	report, err := db.RetryIndexOps(ctx, scylla.RetryOptions{MinBackoff: time.Second, MaxBackoff: time.Minute})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, report.Succeeded, 2)
+
+	got, err := db.GetUserByEmail(ctx, newEmail)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	exists, err := db.CheckEmailExists(ctx, oldEmail)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
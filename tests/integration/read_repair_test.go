@@ -0,0 +1,56 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaReadRepairsStaleLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	// Point a lookup at a user who doesn't have the email, and another at a
+	// user who doesn't exist
+	old := user.CreatedAt.Add(-time.Hour)
+	staleEmail := newTestUser().Email
+	orphanPhone := newTestUser().PhoneNumber
+	require.NoError(t, session.Query(`INSERT INTO users_by_email (email, user_id, created_at) VALUES (?, ?, ?)`,
+		staleEmail, user.ID, old).Exec())
+	require.NoError(t, session.Query(`INSERT INTO users_by_phone (phone_number, user_id, created_at) VALUES (?, ?, ?)`,
+		orphanPhone, "no-such-user", old).Exec())
+
+	before := db.Stats()
+
+	_, err := db.GetUserByEmail(ctx, staleEmail)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = db.GetUserByPhone(ctx, orphanPhone)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	exists, err := db.CheckEmailExists(ctx, staleEmail)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = db.CheckPhoneExists(ctx, orphanPhone)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	after := db.Stats()
+	assert.Equal(t, before.StaleLookups+2, after.StaleLookups)
+	assert.Equal(t, before.RepairedLookups+2, after.RepairedLookups)
+
+	// The user's own lookups still resolve
+	got, err := db.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+}
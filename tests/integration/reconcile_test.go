@@ -0,0 +1,80 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRawSession opens a session for corrupting tables behind the storage
+// layer's back
+func newRawSession(t *testing.T) *gocql.Session {
+	hosts := os.Getenv("SCYLLA_HOSTS")
+	if hosts == "" {
+		hosts = "localhost"
+	}
+	cluster := gocql.NewCluster(strings.Split(hosts, ",")...)
+	cluster.Keyspace = "userservice"
+	cluster.Consistency = gocql.Quorum
+	session, err := cluster.CreateSession()
+	require.NoError(t, err)
+	t.Cleanup(session.Close)
+	return session
+}
+
+func findIssue(report *scylla.ReconcileReport, kind scylla.IssueKind, value string) *scylla.LookupIssue {
+	for i := range report.Issues {
+		if report.Issues[i].Kind == kind && report.Issues[i].Value == value {
+			return &report.Issues[i]
+		}
+	}
+	return nil
+}
+
+func TestScyllaReconcileRepairsLookups(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	session := newRawSession(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	// Drop the email lookup and leave an orphaned phone lookup behind
+	require.NoError(t, session.Query(`DELETE FROM users_by_email WHERE email = ?`, user.Email).Exec())
+	orphanPhone := newTestUser().PhoneNumber
+	require.NoError(t, session.Query(`INSERT INTO users_by_phone (phone_number, user_id, created_at) VALUES (?, ?, ?)`,
+		orphanPhone, "no-such-user", user.CreatedAt.Add(-time.Hour)).Exec())
+
+	opts := scylla.ReconcileOptions{GracePeriod: 0}
+	report, err := db.Reconcile(ctx, opts)
+	require.NoError(t, err)
+	require.NotNil(t, findIssue(report, scylla.IssueMissing, user.Email))
+	require.NotNil(t, findIssue(report, scylla.IssueOrphaned, orphanPhone))
+
+	// A dry run changes nothing
+	_, err = db.GetUserByEmail(ctx, user.Email)
+	assert.Error(t, err)
+
+	opts.Repair = true
+	report, err = db.Reconcile(ctx, opts)
+	require.NoError(t, err)
+	assert.True(t, findIssue(report, scylla.IssueMissing, user.Email).Repaired)
+	assert.True(t, findIssue(report, scylla.IssueOrphaned, orphanPhone).Repaired)
+
+	got, err := db.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	exists, err := db.CheckPhoneExists(ctx, orphanPhone)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
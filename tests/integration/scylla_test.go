@@ -0,0 +1,165 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scyllaTestOptions points at the ScyllaDB started by `make docker-up`
+func scyllaTestOptions() scylla.ClusterOptions {
+	hosts := os.Getenv("SCYLLA_HOSTS")
+	if hosts == "" {
+		hosts = "localhost"
+	}
+	return scylla.ClusterOptions{
+		Hosts:             strings.Split(hosts, ","),
+		Port:              9042,
+		Keyspace:          "userservice",
+		Consistency:       "QUORUM",
+		SerialConsistency: "SERIAL",
+		TokenAware:        true,
+		ProtoVersion:      4,
+		RetryPolicy:       "simple",
+		RetryAttempts:     3,
+		NumConns:          2,
+		ConnectTimeout:    10 * time.Second,
+		Timeout:           10 * time.Second,
+	}
+}
+
+// newScyllaDB connects to the ScyllaDB started by `make docker-up`
+func newScyllaDB(t *testing.T) *scylla.ScyllaDB {
+	db, err := scylla.NewScyllaDB(scyllaTestOptions())
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestUser() *domain.User {
+	n := time.Now().UnixNano()
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	return domain.NewUser("John", "Doe", "male", dob,
+		fmt.Sprintf("+1%010d", n%10000000000), fmt.Sprintf("john%d@example.com", n))
+}
+
+func TestScyllaConcurrentCreateSameEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+	base := newTestUser()
+
+	const workers = 8
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := newTestUser()
+			u.Email = base.Email
+			errs[i] = db.CreateUser(ctx, u)
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyExists)
+	}
+	assert.Equal(t, 1, created)
+
+	winner, err := db.GetUserByEmail(ctx, base.Email)
+	require.NoError(t, err)
+	assert.NoError(t, db.DeleteUser(ctx, winner.ID, nil))
+}
+
+func TestScyllaCreateDuplicatePhoneReleasesEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	first := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, first))
+	t.Cleanup(func() { db.DeleteUser(ctx, first.ID, nil) })
+
+	second := newTestUser()
+	second.PhoneNumber = first.PhoneNumber
+	assert.ErrorIs(t, db.CreateUser(ctx, second), domain.ErrPhoneAlreadyExists)
+
+	// The email claimed by the failed create must have been rolled back
+	exists, err := db.CheckEmailExists(ctx, second.Email)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestScyllaListUsersWalksAllPages(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	created := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		u := newTestUser()
+		require.NoError(t, db.CreateUser(ctx, u))
+		created[u.ID] = true
+		t.Cleanup(func() { db.DeleteUser(ctx, u.ID, nil) })
+	}
+
+	seen := make(map[string]bool)
+	token := ""
+	for {
+		users, next, err := db.ListUsers(ctx, storage.ListOptions{Limit: 3, PageToken: token})
+		require.NoError(t, err)
+		for _, u := range users {
+			assert.False(t, seen[u.ID], "user returned twice")
+			seen[u.ID] = true
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	for id := range created {
+		assert.True(t, seen[id], "user missing from listing: "+id)
+	}
+}
+
+func TestScyllaUpdateUserVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	user := newTestUser()
+	require.NoError(t, db.CreateUser(ctx, user))
+	t.Cleanup(func() { db.DeleteUser(ctx, user.ID, nil) })
+
+	first, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	second, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+
+	first.Block()
+	require.NoError(t, db.UpdateUser(ctx, first))
+
+	second.Update("Jane", "Doe", "female", second.DateOfBirth)
+	assert.ErrorIs(t, db.UpdateUser(ctx, second), domain.ErrVersionConflict)
+
+	stored, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.Version, stored.Version)
+	assert.Equal(t, "John", stored.FirstName)
+}
@@ -0,0 +1,62 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scyllaSearch(t *testing.T, db storage.Storage, query string, showDeleted bool) []string {
+	q, err := search.ParseQuery(query)
+	require.NoError(t, err)
+	users, _, err := db.SearchUsers(context.Background(), storage.SearchOptions{Query: q, Limit: 100, ShowDeleted: showDeleted})
+	require.NoError(t, err)
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+func TestScyllaSearchUsers(t *testing.T) {
+	ctx := context.Background()
+	db := newScyllaDB(t)
+
+	// A last name no other test uses
+	family := fmt.Sprintf("Ñandú%d", time.Now().UnixNano())
+	folded := search.Fold(family)
+	first := newTestUser()
+	first.FirstName = "Zoë"
+	first.LastName = family
+	second := newTestUser()
+	second.FirstName = "Zola"
+	second.LastName = family
+	require.NoError(t, db.CreateUser(ctx, first))
+	require.NoError(t, db.CreateUser(ctx, second))
+
+	assert.ElementsMatch(t, []string{first.ID, second.ID}, scyllaSearch(t, db, folded, false))
+	assert.Equal(t, []string{first.ID}, scyllaSearch(t, db, "zoe "+folded, false))
+	assert.Equal(t, []string{first.ID}, scyllaSearch(t, db, first.PhoneNumber, false))
+
+	first.Update("Chloé", first.LastName, first.Gender, first.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, first))
+	assert.Empty(t, scyllaSearch(t, db, "zoe "+folded, false))
+	assert.Equal(t, []string{first.ID}, scyllaSearch(t, db, "CHLOE "+folded, false))
+
+	require.NoError(t, db.DeleteUser(ctx, second.ID, nil))
+	assert.Equal(t, []string{first.ID}, scyllaSearch(t, db, folded, false))
+	assert.ElementsMatch(t, []string{first.ID, second.ID}, scyllaSearch(t, db, folded, true))
+
+	n, err := db.RebuildSearchIndex(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, 2)
+	assert.Equal(t, []string{first.ID}, scyllaSearch(t, db, "chloe "+folded, false))
+}
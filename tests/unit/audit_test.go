@@ -0,0 +1,142 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/audit"
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func auditActions(records []*domain.AuditRecord) []domain.EventType {
+	actions := make([]domain.EventType, len(records))
+	for i, record := range records {
+		actions[i] = record.Action
+	}
+	return actions
+}
+
+// testAuditLog checks that db records who made each change and lists the
+// records newest first, by page and by time range
+func testAuditLog(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	blockCtx := audit.WithInfo(ctx, audit.Info{
+		Method:    "BlockUser",
+		Actor:     "admin-7",
+		Reason:    "chargeback",
+		RequestID: "req-1",
+	})
+	user.Block()
+	require.NoError(t, db.UpdateUser(blockCtx, user))
+	email := "new@example.com"
+	user.UpdateContact(nil, &email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	// Records of another user are not listed
+	require.NoError(t, db.CreateUser(ctx, newTestUser(2)))
+
+	records, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Equal(t, []domain.EventType{
+		domain.EventUserDeleted,
+		domain.EventUserContactChanged,
+		domain.EventUserBlocked,
+		domain.EventUserCreated,
+	}, auditActions(records))
+
+	blocked := records[2]
+	assert.Equal(t, user.ID, blocked.UserID)
+	assert.Equal(t, "BlockUser", blocked.Method)
+	assert.Equal(t, "admin-7", blocked.Actor)
+	assert.Equal(t, "chargeback", blocked.Reason)
+	assert.Equal(t, "req-1", blocked.RequestID)
+	assert.Equal(t, []domain.FieldChange{{Field: "is_blocked", OldValue: "false", NewValue: "true"}}, blocked.Changes)
+
+	contact := records[1]
+	assert.Equal(t, audit.SystemActor, contact.Actor)
+	assert.Contains(t, contact.Changes, domain.FieldChange{Field: "email", OldValue: "john1@example.com", NewValue: email})
+
+	created := records[3]
+	assert.Contains(t, created.Changes, domain.FieldChange{Field: "first_name", NewValue: "John"})
+	assert.False(t, created.OccurredAt.IsZero())
+
+	// Paging
+	first, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, first, 3)
+	require.NotEmpty(t, next)
+	rest, next, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 3, PageToken: next})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, rest, 1)
+	assert.Equal(t, created.ID, rest[0].ID)
+
+	// Time range: Since is inclusive, Until exclusive
+	ranged, _, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{
+		Limit: 10,
+		Since: blocked.OccurredAt,
+		Until: records[0].OccurredAt,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.EventType{domain.EventUserContactChanged, domain.EventUserBlocked}, auditActions(ranged))
+
+	ranged, _, err = db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{
+		Limit: 10,
+		Since: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, ranged)
+
+	// Audit records outlive the user
+	_, err = db.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	records, _, err = db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, records, 4)
+}
+
+func TestMemoryStorageAuditLog(t *testing.T) {
+	testAuditLog(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageAuditLog(t *testing.T) {
+	testAuditLog(t, newSQLiteDB(t))
+}
+
+func TestMemoryStorageAuditLogInvalidPageToken(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	_, _, err := db.ListAuditRecords(ctx, user.ID, storage.AuditListOptions{Limit: 10, PageToken: "unknown"})
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+}
+
+func TestDiffUsers(t *testing.T) {
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	before := domain.NewUser("John", "Doe", "male", dob, "+12025550001", "john@example.com")
+	after := *before
+	after.Update("Jane", "Doe", "female", dob)
+	after.Version++
+	after.UpdatedAt = after.UpdatedAt.Add(time.Second)
+
+	assert.Equal(t, []domain.FieldChange{
+		{Field: "first_name", OldValue: "John", NewValue: "Jane"},
+		{Field: "gender", OldValue: "male", NewValue: "female"},
+	}, domain.DiffUsers(before, &after))
+	assert.Empty(t, domain.DiffUsers(before, before))
+
+	created := domain.DiffUsers(nil, before)
+	assert.Contains(t, created, domain.FieldChange{Field: "date_of_birth", NewValue: "1990-01-15T00:00:00Z"})
+}
@@ -0,0 +1,207 @@
+package unit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/cache"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage counts reads that reach the wrapped storage
+type countingStorage struct {
+	storage.Storage
+	reads atomic.Int64
+	delay time.Duration
+}
+
+func (s *countingStorage) GetUserByID(ctx context.Context, id string) (*domain.User, error) {
+	s.reads.Add(1)
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetUserByID(ctx, id)
+}
+
+func (s *countingStorage) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	s.reads.Add(1)
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Storage.GetUserByEmail(ctx, email)
+}
+
+// wait sleeps for the delay of a slow database read
+func (s *countingStorage) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newCountingStorage(t *testing.T, users ...*domain.User) *countingStorage {
+	db := memory.NewMemoryStorage()
+	for _, u := range users {
+		require.NoError(t, db.CreateUser(context.Background(), u))
+	}
+	return &countingStorage{Storage: db}
+}
+
+func TestCachedStorageHitsAndMisses(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	backend := newCountingStorage(t, user)
+	c := cache.NewCachedStorage(backend, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got, err := c.GetUserByID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Email, got.Email)
+	}
+	assert.Equal(t, int64(1), backend.reads.Load())
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Size)
+
+	// Missing users are not cached
+	for i := 0; i < 2; i++ {
+		_, err := c.GetUserByID(ctx, "missing")
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	}
+	assert.Equal(t, int64(3), backend.reads.Load())
+}
+
+func TestCachedStorageReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	c := cache.NewCachedStorage(newCountingStorage(t, user), 10, time.Minute)
+
+	got, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	got.Block()
+
+	again, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, again.IsBlocked)
+}
+
+func TestCachedStorageInvalidatesOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	oldEmail := user.Email
+	c := cache.NewCachedStorage(newCountingStorage(t, user), 10, time.Minute)
+
+	_, err := c.GetUserByEmail(ctx, oldEmail)
+	require.NoError(t, err)
+
+	got, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	newEmail := "changed@example.com"
+	got.UpdateContact(nil, &newEmail)
+	require.NoError(t, c.UpdateUser(ctx, got))
+
+	// The entry cached under the old email must be gone too
+	_, err = c.GetUserByEmail(ctx, oldEmail)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	fresh, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, newEmail, fresh.Email)
+
+	require.NoError(t, c.DeleteUser(ctx, user.ID, nil))
+	deleted, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted())
+}
+
+func TestCachedStorageEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	a, b, d := newTestUser(1), newTestUser(2), newTestUser(3)
+	backend := newCountingStorage(t, a, b, d)
+	c := cache.NewCachedStorage(backend, 2, time.Minute)
+
+	for _, id := range []string{a.ID, b.ID, a.ID, d.ID} {
+		_, err := c.GetUserByID(ctx, id)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+
+	// b was the least recently used, so it is the one that was evicted
+	reads := backend.reads.Load()
+	_, err := c.GetUserByID(ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, reads, backend.reads.Load())
+	_, err = c.GetUserByID(ctx, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, reads+1, backend.reads.Load())
+}
+
+func TestCachedStorageExpires(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	backend := newCountingStorage(t, user)
+	c := cache.NewCachedStorage(backend, 10, 10*time.Millisecond)
+
+	_, err := c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = c.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), backend.reads.Load())
+}
+
+func TestCachedStorageCollapsesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	backend := newCountingStorage(t, user)
+	backend.delay = 50 * time.Millisecond
+	c := cache.NewCachedStorage(backend, 10, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetUserByID(ctx, user.ID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), backend.reads.Load())
+}
+
+func TestCachedStorageSharedLoadOutlivesFirstCaller(t *testing.T) {
+	user := newTestUser(1)
+	backend := newCountingStorage(t, user)
+	backend.delay = 50 * time.Millisecond
+	c := cache.NewCachedStorage(backend, 10, time.Minute)
+
+	// The first caller starts the load and gives up before it finishes
+	first, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := c.GetUserByID(first, user.ID)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	got, err := c.GetUserByID(context.Background(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	wg.Wait()
+	assert.Equal(t, int64(1), backend.reads.Load())
+}
@@ -48,4 +48,13 @@ func TestLoadConfigDefaults(t *testing.T) {
 	assert.Equal(t, "development", cfg.Env) // default
 	assert.Equal(t, 50051, cfg.GRPC.Port)   // default
 	assert.Equal(t, 8080, cfg.HTTP.Port)    // default
+	assert.Empty(t, cfg.Debug.Addr)         // debug listener off
+	assert.Equal(t, "memory", cfg.Storage.Driver)
+	assert.True(t, cfg.Email.LowercaseLocal)
+	assert.Equal(t, []string{"gmail.com"}, cfg.Email.IgnoreDotsDomains)
+	assert.Equal(t, map[string]string{"googlemail.com": "gmail.com"}, cfg.Email.DomainAliases)
+	assert.Equal(t, "log", cfg.Events.Publisher)
+	assert.Equal(t, 100, cfg.Events.BatchSize)
+	assert.Equal(t, 8, cfg.ScyllaDB.ExportWorkers)
+	assert.Equal(t, 8, cfg.Import.Concurrency)
 }
@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/emailnorm"
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillCanonicalEmails(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	canonical := emailnorm.New(emailnorm.Rules{LowercaseLocal: true}).Canonical
+
+	// Users stored before canonical emails have none
+	mixedCase := newTestUser(1)
+	mixedCase.Email = "Jane@Example.com"
+	lower := newTestUser(2)
+	lower.Email = "jane@example.com"
+	done := newTestUser(3)
+	done.CanonicalEmail = done.Email
+	for _, u := range []*domain.User{mixedCase, lower, done} {
+		require.NoError(t, db.CreateUser(ctx, u))
+	}
+
+	report, err := jobs.BackfillCanonicalEmails(ctx, db, canonical, true)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Scanned)
+	assert.Equal(t, 1, report.Updated)
+	require.Len(t, report.Conflicts, 1)
+	assert.Equal(t, jobs.EmailConflict{UserID: mixedCase.ID, Email: "Jane@Example.com",
+		CanonicalEmail: "jane@example.com", OwnerID: lower.ID}, report.Conflicts[0])
+	// A dry run writes nothing
+	_, err = db.GetUserByEmail(ctx, "Jane@Example.com")
+	assert.NoError(t, err)
+
+	report, err = jobs.BackfillCanonicalEmails(ctx, db, canonical, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+	assert.Len(t, report.Conflicts, 1)
+
+	got, err := db.GetUserByEmail(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, lower.ID, got.ID)
+	assert.Equal(t, "jane@example.com", got.CanonicalEmail)
+
+	// Once the conflicting user changes their email the rerun goes through
+	conflicting, err := db.GetUserByID(ctx, mixedCase.ID)
+	require.NoError(t, err)
+	newEmail := "Jane.Smith@Example.com"
+	conflicting.UpdateContact(nil, &newEmail)
+	require.NoError(t, db.UpdateUser(ctx, conflicting))
+
+	report, err = jobs.BackfillCanonicalEmails(ctx, db, canonical, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Updated)
+	assert.Empty(t, report.Conflicts)
+	got, err = db.GetUserByEmail(ctx, "jane.smith@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, mixedCase.ID, got.ID)
+	assert.Equal(t, newEmail, got.Email)
+}
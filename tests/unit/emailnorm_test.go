@@ -0,0 +1,39 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/emailnorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailCanonical(t *testing.T) {
+	gmail := emailnorm.Rules{
+		LowercaseLocal:    true,
+		IgnoreDotsDomains: []string{"gmail.com"},
+		StripPlusDomains:  []string{"gmail.com"},
+		DomainAliases:     map[string]string{"GoogleMail.com": "gmail.com"},
+	}
+
+	tests := []struct {
+		name  string
+		rules emailnorm.Rules
+		email string
+		want  string
+	}{
+		{"lowercases", gmail, "John@Example.com", "john@example.com"},
+		{"trims", gmail, "  john@example.com ", "john@example.com"},
+		{"keeps dots elsewhere", gmail, "j.doe+news@example.com", "j.doe+news@example.com"},
+		{"gmail dots and plus", gmail, "J.Doe+News@Gmail.com", "jdoe@gmail.com"},
+		{"alias", gmail, "j.doe@googlemail.com", "jdoe@gmail.com"},
+		{"trailing dot", gmail, "jdoe@gmail.com.", "jdoe@gmail.com"},
+		{"leading plus kept", gmail, "+tag@gmail.com", "+tag@gmail.com"},
+		{"case-sensitive local", emailnorm.Rules{}, "John@Example.COM", "John@example.com"},
+		{"not an email", gmail, "John", "John"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, emailnorm.New(tt.rules).Canonical(tt.email))
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/events"
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func eventTypes(events []*domain.Event) []domain.EventType {
+	types := make([]domain.EventType, len(events))
+	for i, event := range events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestMemoryStorageWritesUserEvents(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	user.Unblock()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	email := "new@example.com"
+	user.UpdateContact(nil, &email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	user.Update("Jane", "Doe", "female", user.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+	_, err := db.RestoreUser(ctx, user.ID, nil)
+	require.NoError(t, err)
+
+	// A failed update writes no event
+	stale := *user
+	assert.ErrorIs(t, db.UpdateUser(ctx, &stale), domain.ErrVersionConflict)
+
+	pending, err := db.PendingEvents(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.EventType{
+		domain.EventUserCreated,
+		domain.EventUserBlocked,
+		domain.EventUserUnblocked,
+		domain.EventUserContactChanged,
+		domain.EventUserUpdated,
+		domain.EventUserDeleted,
+		domain.EventUserRestored,
+	}, eventTypes(pending))
+
+	assert.Nil(t, pending[0].Before)
+	assert.Equal(t, int64(1), pending[0].After.Version)
+	contact := pending[3]
+	assert.Equal(t, "john1@example.com", contact.Before.Email)
+	assert.Equal(t, "new@example.com", contact.After.Email)
+	assert.Equal(t, contact.Before.Version+1, contact.After.Version)
+	assert.Equal(t, int64(7), pending[6].After.Version)
+}
+
+func TestEventDispatcherPublishesInOrder(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, db.CreateUser(ctx, newTestUser(i)))
+	}
+	publisher := events.NewMemoryPublisher()
+	dispatcher := jobs.NewEventDispatcher(db, publisher, time.Minute, 2)
+
+	published, err := dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, published)
+
+	got := publisher.Events()
+	require.Len(t, got, 5)
+	for i, event := range got {
+		assert.Equal(t, domain.EventUserCreated, event.Type)
+		assert.Equal(t, fmt.Sprintf("+1202555%04d", i+1), event.After.PhoneNumber)
+	}
+
+	pending, err := db.PendingEvents(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.Equal(t, uint64(5), dispatcher.Stats().Published)
+}
+
+func TestEventDispatcherRetriesFailedEvents(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+
+	publisher := events.NewMemoryPublisher()
+	publisher.Fail(errors.New("broker down"))
+	dispatcher := jobs.NewEventDispatcher(db, publisher, time.Minute, 10)
+
+	published, err := dispatcher.DispatchOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 0, published)
+	pending, err := db.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	publisher.Fail(nil)
+	published, err = dispatcher.DispatchOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []domain.EventType{domain.EventUserCreated, domain.EventUserBlocked}, eventTypes(publisher.Events()))
+	assert.Equal(t, jobs.DispatchStats{Published: 2, Failed: 1}, dispatcher.Stats())
+}
+
+func TestFilePublisherAppendsJSONLines(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "events", "user-events.jsonl")
+	publisher, err := events.NewFilePublisher(path)
+	require.NoError(t, err)
+
+	user := newTestUser(1)
+	created := domain.NewUserEvent(nil, user)
+	blocked := *user
+	blocked.Block()
+	blocked.Version++
+	require.NoError(t, publisher.Publish(ctx, created))
+	require.NoError(t, publisher.Publish(ctx, domain.NewUserEvent(user, &blocked)))
+	require.NoError(t, publisher.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+	assert.Equal(t, created.ID, lines[0]["id"])
+	assert.Equal(t, "UserCreated", lines[0]["type"])
+	assert.NotContains(t, lines[0], "before")
+	assert.Equal(t, "UserBlocked", lines[1]["type"])
+	assert.Equal(t, false, lines[1]["before"].(map[string]interface{})["is_blocked"])
+	assert.Equal(t, true, lines[1]["after"].(map[string]interface{})["is_blocked"])
+}
@@ -0,0 +1,130 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportIDs(t *testing.T, db storage.Storage, opts storage.ExportOptions) []string {
+	var ids []string
+	require.NoError(t, db.ExportUsers(context.Background(), opts, func(user *domain.User) error {
+		ids = append(ids, user.ID)
+		return nil
+	}))
+	return ids
+}
+
+// testExportUsers checks the export filters of db
+func testExportUsers(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	users := make([]*domain.User, 4)
+	for i := range users {
+		users[i] = newTestUser(i + 1)
+		users[i].CreatedAt = time.Date(2025, 1, i+1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, db.CreateUser(ctx, users[i]))
+	}
+	users[1].Block()
+	require.NoError(t, db.UpdateUser(ctx, users[1]))
+	require.NoError(t, db.DeleteUser(ctx, users[3].ID, nil))
+
+	ids := func(indexes ...int) []string {
+		var ids []string
+		for _, i := range indexes {
+			ids = append(ids, users[i].ID)
+		}
+		return ids
+	}
+
+	assert.ElementsMatch(t, ids(0, 1, 2), exportIDs(t, db, storage.ExportOptions{}))
+	assert.ElementsMatch(t, ids(0, 1, 2, 3), exportIDs(t, db, storage.ExportOptions{ShowDeleted: true}))
+
+	blocked, unblocked := true, false
+	assert.ElementsMatch(t, ids(1), exportIDs(t, db, storage.ExportOptions{Blocked: &blocked}))
+	assert.ElementsMatch(t, ids(0, 2), exportIDs(t, db, storage.ExportOptions{Blocked: &unblocked}))
+
+	assert.ElementsMatch(t, ids(1, 2), exportIDs(t, db, storage.ExportOptions{
+		CreatedFrom: users[1].CreatedAt,
+		CreatedTo:   users[3].CreatedAt,
+		ShowDeleted: true,
+	}))
+
+	// An error from fn stops the export
+	stop := errors.New("stop")
+	calls := 0
+	err := db.ExportUsers(ctx, storage.ExportOptions{}, func(*domain.User) error {
+		calls++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMemoryStorageExportUsers(t *testing.T) {
+	testExportUsers(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageExportUsers(t *testing.T) {
+	testExportUsers(t, newSQLiteDB(t))
+}
+
+func TestParseExportFormat(t *testing.T) {
+	format, err := export.ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, export.FormatJSONL, format)
+	format, err = export.ParseFormat("CSV")
+	require.NoError(t, err)
+	assert.Equal(t, export.FormatCSV, format)
+	_, err = export.ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestExportEncoders(t *testing.T) {
+	user := newTestUser(1)
+	user.FirstName = "Jean, \"JJ\""
+	user.Block()
+
+	var buf bytes.Buffer
+	enc := export.NewEncoder(&buf, export.FormatJSONL)
+	require.NoError(t, enc.Encode(user))
+	require.NoError(t, enc.Encode(newTestUser(2)))
+	require.NoError(t, enc.Flush())
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	var got domain.User
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.Equal(t, user.ID, got.ID)
+	assert.Equal(t, user.FirstName, got.FirstName)
+	assert.True(t, got.IsBlocked)
+
+	buf.Reset()
+	enc = export.NewEncoder(&buf, export.FormatCSV)
+	require.NoError(t, enc.Encode(user))
+	require.NoError(t, enc.Flush())
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, export.Columns, rows[0])
+	assert.Equal(t, user.ID, rows[1][0])
+	assert.Equal(t, user.FirstName, rows[1][1])
+	assert.Equal(t, "1990-01-15T00:00:00Z", rows[1][4])
+	assert.Equal(t, "true", rows[1][7])
+	assert.Equal(t, "", rows[1][11])
+
+	// An empty CSV export still has the header
+	buf.Reset()
+	require.NoError(t, export.NewEncoder(&buf, export.FormatCSV).Flush())
+	assert.Equal(t, strings.Join(export.Columns, ",")+"\n", buf.String())
+}
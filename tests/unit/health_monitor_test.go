@@ -0,0 +1,101 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend fails pings while down; reconnecting brings it back up
+type fakeBackend struct {
+	mu         sync.Mutex
+	down       bool
+	reconnects int
+}
+
+func (f *fakeBackend) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("session lost")
+	}
+	return nil
+}
+
+func (f *fakeBackend) Reconnect() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reconnects++
+	f.down = false
+	return nil
+}
+
+func (f *fakeBackend) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+// servingLog records what the monitor reports
+type servingLog struct {
+	mu      sync.Mutex
+	changes []bool
+}
+
+func (l *servingLog) set(serving bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changes = append(l.changes, serving)
+}
+
+func (l *servingLog) get() []bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]bool(nil), l.changes...)
+}
+
+func TestHealthMonitorReportsAfterFailures(t *testing.T) {
+	ctx := context.Background()
+	backend := &fakeBackend{down: true}
+	log := &servingLog{}
+	monitor := jobs.NewHealthMonitor(backend, jobs.HealthOptions{Interval: time.Second, Failures: 2}, log.set)
+
+	assert.False(t, monitor.CheckOnce(ctx))
+	assert.Empty(t, log.get(), "a single failure is tolerated")
+	assert.False(t, monitor.CheckOnce(ctx))
+	assert.Equal(t, []bool{false}, log.get())
+	assert.False(t, monitor.CheckOnce(ctx))
+	assert.Equal(t, []bool{false}, log.get(), "only changes are reported")
+
+	backend.setDown(false)
+	assert.True(t, monitor.CheckOnce(ctx))
+	assert.Equal(t, []bool{false, true}, log.get())
+	assert.Equal(t, jobs.HealthStats{Serving: true, Failures: 3}, monitor.Stats())
+}
+
+func TestHealthMonitorReconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backend := &fakeBackend{}
+	log := &servingLog{}
+	monitor := jobs.NewHealthMonitor(backend, jobs.HealthOptions{
+		Interval:   5 * time.Millisecond,
+		Failures:   1,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	}, log.set)
+	go monitor.Run(ctx)
+
+	backend.setDown(true)
+	assert.Eventually(t, func() bool {
+		changes := log.get()
+		return len(changes) == 2 && !changes[0] && changes[1]
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, uint64(1), monitor.Stats().Reconnects)
+}
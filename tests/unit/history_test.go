@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func userVersions(users []*domain.User) []int64 {
+	versions := make([]int64, len(users))
+	for i, user := range users {
+		versions[i] = user.Version
+	}
+	return versions
+}
+
+// testUserHistory checks that db keeps a snapshot of every version of a user
+// and drops them when the user is purged
+func testUserHistory(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+	user.Update("Jane", "Doe", "female", user.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	email := "new@example.com"
+	user.UpdateContact(nil, &email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+
+	versions, next, err := db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Equal(t, []int64{4, 3, 2, 1}, userVersions(versions))
+	assert.True(t, versions[0].IsBlocked)
+	assert.Equal(t, "john1@example.com", versions[3].Email)
+	assert.Equal(t, "John", versions[3].FirstName)
+
+	first, next, err := db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 3, 2}, userVersions(first))
+	require.NotEmpty(t, next)
+	rest, next, err := db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 3, PageToken: next})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.Equal(t, []int64{1}, userVersions(rest))
+
+	_, _, err = db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 3, PageToken: "x"})
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+
+	second, err := db.GetUserVersion(ctx, user.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", second.FirstName)
+	assert.Equal(t, "john1@example.com", second.Email)
+	assert.True(t, second.UpdatedAt.Equal(versions[2].UpdatedAt))
+	_, err = db.GetUserVersion(ctx, user.ID, 5)
+	assert.Equal(t, domain.ErrVersionNotFound, err)
+
+	// A snapshot is current from its update until the next one
+	at, err := db.GetUserAtTime(ctx, user.ID, versions[2].UpdatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), at.Version)
+	at, err = db.GetUserAtTime(ctx, user.ID, versions[1].UpdatedAt.Add(-time.Nanosecond))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), at.Version)
+	at, err = db.GetUserAtTime(ctx, user.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), at.Version)
+	_, err = db.GetUserAtTime(ctx, user.ID, versions[3].UpdatedAt.Add(-time.Second))
+	assert.Equal(t, domain.ErrVersionNotFound, err)
+
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+	_, err = db.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	versions, _, err = db.ListUserVersions(ctx, user.ID, storage.VersionListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+// testRevertUser checks that reverting to a snapshot goes through the usual
+// uniqueness checks
+func testRevertUser(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+	email := "new@example.com"
+	user.UpdateContact(nil, &email)
+	require.NoError(t, db.UpdateUser(ctx, user))
+
+	// Another user takes the old email
+	other := newTestUser(2)
+	other.Email = "john1@example.com"
+	require.NoError(t, db.CreateUser(ctx, other))
+
+	snapshot, err := db.GetUserVersion(ctx, user.ID, 1)
+	require.NoError(t, err)
+	reverted := *user
+	reverted.Revert(snapshot)
+	assert.Equal(t, domain.ErrEmailAlreadyExists, db.UpdateUser(ctx, &reverted))
+
+	// Once it is free again the revert goes through as a new version
+	newEmail := "other@example.com"
+	other.UpdateContact(nil, &newEmail)
+	require.NoError(t, db.UpdateUser(ctx, other))
+	reverted = *user
+	reverted.Revert(snapshot)
+	require.NoError(t, db.UpdateUser(ctx, &reverted))
+	assert.Equal(t, int64(3), reverted.Version)
+
+	got, err := db.GetUserByEmail(ctx, "john1@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+	latest, err := db.GetUserVersion(ctx, user.ID, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "john1@example.com", latest.Email)
+}
+
+func TestMemoryStorageUserHistory(t *testing.T) {
+	testUserHistory(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageUserHistory(t *testing.T) {
+	testUserHistory(t, newSQLiteDB(t))
+}
+
+func TestMemoryStorageRevertUser(t *testing.T) {
+	testRevertUser(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageRevertUser(t *testing.T) {
+	testRevertUser(t, newSQLiteDB(t))
+}
@@ -0,0 +1,193 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/emailnorm"
+	"github.com/Divyansh031/user-service/internal/export"
+	"github.com/Divyansh031/user-service/internal/importer"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var importEmails = emailnorm.New(emailnorm.Rules{LowercaseLocal: true})
+
+func importUsers(t *testing.T, db storage.Storage, file string, opts importer.Options) *importer.Report {
+	report, err := importer.Import(context.Background(), db, importEmails.Canonical, strings.NewReader(file), opts)
+	require.NoError(t, err)
+	return report
+}
+
+func rowStatuses(report *importer.Report) []importer.Status {
+	statuses := make([]importer.Status, len(report.Rows))
+	for i, row := range report.Rows {
+		statuses[i] = row.Status
+	}
+	return statuses
+}
+
+const importCSV = `first_name,last_name,gender,date_of_birth,phone_number,email
+Ada,Lovelace,female,1815-12-10,+12025550101,ada@example.com
+Alan,Turing,male,1912-06-23,+12025550102,not-an-email
+Grace,Hopper,female,1906-12-09,+12025550103,ADA@example.com
+Linus,Torvalds,male,1969-12-28T00:00:00Z,+12025550104,linus@example.com
+Ken,Thompson,male,1943-02-04,+12025550105
+`
+
+// testImportUsers checks the import modes against db
+func testImportUsers(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	existing := newTestUser(1)
+	existing.CanonicalEmail = importEmails.Canonical(existing.Email)
+	require.NoError(t, db.CreateUser(ctx, existing))
+
+	// Rows fail on their own: bad email, an email taken earlier in the file
+	// and a short row
+	report := importUsers(t, db, importCSV, importer.Options{Format: export.FormatCSV})
+	assert.Equal(t, []importer.Status{
+		importer.StatusCreated,
+		importer.StatusFailed,
+		importer.StatusFailed,
+		importer.StatusCreated,
+		importer.StatusFailed,
+	}, rowStatuses(report))
+	assert.Equal(t, []int{2, 3, 4, 5, 6}, []int{
+		report.Rows[0].Line, report.Rows[1].Line, report.Rows[2].Line, report.Rows[3].Line, report.Rows[4].Line,
+	})
+	assert.Equal(t, domain.ErrInvalidEmail, report.Rows[1].Err)
+	assert.ErrorIs(t, report.Rows[2].Err, domain.ErrEmailAlreadyExists)
+	assert.Contains(t, report.Rows[2].Err.Error(), "line 2")
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, 3, report.Failed)
+
+	ada, err := db.GetUserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, report.Rows[0].UserID, ada.ID)
+	assert.Equal(t, "1815-12-10", ada.DateOfBirth.Format("2006-01-02"))
+
+	// Importing it again: create fails on taken emails, skip-duplicates
+	// skips them
+	file := `{"first_name":"Ada","last_name":"Lovelace","gender":"female","date_of_birth":"1815-12-10","phone_number":"+12025550101","email":"ada@example.com"}
+
+{"first_name":"Joan","last_name":"Clarke","gender":"female","date_of_birth":"1917-06-24","phone_number":"+12025550106","email":"joan@example.com"}
+{"first_name":
+`
+	report = importUsers(t, db, file, importer.Options{Format: export.FormatJSONL, DryRun: true})
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []importer.Status{importer.StatusFailed, importer.StatusCreated, importer.StatusFailed}, rowStatuses(report))
+	assert.Equal(t, []int{1, 3, 4}, []int{report.Rows[0].Line, report.Rows[1].Line, report.Rows[2].Line})
+	assert.Equal(t, domain.ErrEmailAlreadyExists, report.Rows[0].Err)
+	assert.Empty(t, report.Rows[1].UserID)
+	_, err = db.GetUserByEmail(ctx, "joan@example.com")
+	assert.Equal(t, domain.ErrUserNotFound, err)
+
+	report = importUsers(t, db, file, importer.Options{Format: export.FormatJSONL, Mode: importer.ModeSkipDuplicates})
+	assert.Equal(t, []importer.Status{importer.StatusSkipped, importer.StatusCreated, importer.StatusFailed}, rowStatuses(report))
+	_, err = db.GetUserByEmail(ctx, "joan@example.com")
+	assert.NoError(t, err)
+
+	// Upsert matches by email or phone and leaves unchanged users alone
+	file = `first_name,last_name,gender,date_of_birth,phone_number,email,is_blocked
+Ada,King,female,1815-12-10,+12025550101,ada@example.com,true
+John,Doe,male,1990-01-15,+12025550001,john1@example.com,
+Joan,Clarke,female,1917-06-24,+12025550106,joan.clarke@example.com,
+Ken,Thompson,male,1943-02-04,+12025550105,ken@example.com,
+Alan,Turing,male,1912-06-23,+12025550106,linus@example.com,
+`
+	report = importUsers(t, db, file, importer.Options{Format: export.FormatCSV, Mode: importer.ModeUpsert})
+	assert.Equal(t, []importer.Status{
+		importer.StatusUpdated,
+		importer.StatusUnchanged,
+		importer.StatusUpdated,
+		importer.StatusCreated,
+		importer.StatusFailed,
+	}, rowStatuses(report))
+	assert.Equal(t, existing.ID, report.Rows[1].UserID)
+	// Its phone belongs to Joan, claimed on line 4
+	assert.ErrorIs(t, report.Rows[4].Err, domain.ErrPhoneAlreadyExists)
+
+	ada, err = db.GetUserByID(ctx, ada.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "King", ada.LastName)
+	assert.True(t, ada.IsBlocked)
+	assert.Equal(t, int64(2), ada.Version)
+	john, err := db.GetUserByID(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), john.Version)
+	joan, err := db.GetUserByPhone(ctx, "+12025550106")
+	require.NoError(t, err)
+	assert.Equal(t, "joan.clarke@example.com", joan.Email)
+}
+
+func TestMemoryStorageImportUsers(t *testing.T) {
+	testImportUsers(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageImportUsers(t *testing.T) {
+	testImportUsers(t, newSQLiteDB(t))
+}
+
+func TestImportUsersUpsertConflict(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	require.NoError(t, db.CreateUser(ctx, newTestUser(1)))
+	require.NoError(t, db.CreateUser(ctx, newTestUser(2)))
+
+	// The email of one user and the phone of another
+	file := `{"first_name":"John","last_name":"Doe","gender":"male","date_of_birth":"1990-01-15","phone_number":"+12025550002","email":"john1@example.com"}`
+	report := importUsers(t, db, file, importer.Options{Mode: importer.ModeUpsert})
+	require.Len(t, report.Rows, 1)
+	assert.Equal(t, importer.StatusFailed, report.Rows[0].Status)
+	assert.Equal(t, domain.ErrPhoneAlreadyExists, report.Rows[0].Err)
+}
+
+func TestImportUsersExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := memory.NewMemoryStorage()
+	for i := 1; i <= 20; i++ {
+		require.NoError(t, source.CreateUser(ctx, newTestUser(i)))
+	}
+
+	for _, format := range []export.Format{export.FormatJSONL, export.FormatCSV} {
+		var buf bytes.Buffer
+		enc := export.NewEncoder(&buf, format)
+		require.NoError(t, source.ExportUsers(ctx, storage.ExportOptions{}, enc.Encode))
+		require.NoError(t, enc.Flush())
+
+		target := memory.NewMemoryStorage()
+		report := importUsers(t, target, buf.String(), importer.Options{Format: format, Concurrency: 4})
+		assert.Equal(t, 20, report.Created, format)
+		assert.Zero(t, report.Failed, format)
+		count, err := target.CountUsers(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), count)
+	}
+}
+
+func TestImportUsersBadFile(t *testing.T) {
+	db := memory.NewMemoryStorage()
+	_, err := importer.Import(context.Background(), db, importEmails.Canonical,
+		strings.NewReader("name,email\nAda,ada@example.com\n"), importer.Options{Format: export.FormatCSV})
+	assert.ErrorContains(t, err, `missing column "first_name"`)
+
+	_, err = importer.Import(context.Background(), db, importEmails.Canonical,
+		strings.NewReader(""), importer.Options{Format: export.FormatCSV})
+	assert.Error(t, err)
+}
+
+func TestParseImportMode(t *testing.T) {
+	mode, err := importer.ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, importer.ModeCreate, mode)
+	mode, err = importer.ParseMode("Upsert")
+	require.NoError(t, err)
+	assert.Equal(t, importer.ModeUpsert, mode)
+	_, err = importer.ParseMode("merge")
+	assert.Error(t, err)
+}
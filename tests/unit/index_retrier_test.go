@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIndexQueue returns the queued reports one per run
+type fakeIndexQueue struct {
+	reports []*scylla.RetryReport
+	errs    []error
+	opts    scylla.RetryOptions
+}
+
+func (f *fakeIndexQueue) RetryIndexOps(ctx context.Context, opts scylla.RetryOptions) (*scylla.RetryReport, error) {
+	f.opts = opts
+	report, err := f.reports[0], f.errs[0]
+	f.reports, f.errs = f.reports[1:], f.errs[1:]
+	return report, err
+}
+
+func TestIndexRetrierTracksQueue(t *testing.T) {
+	ctx := context.Background()
+	queue := &fakeIndexQueue{
+		reports: []*scylla.RetryReport{
+			{Depth: 3, Succeeded: 2, Failed: 3, Finished: 1},
+			{Depth: 1, Succeeded: 2, Failed: 1},
+		},
+		errs: []error{nil, errors.New("scan failed")},
+	}
+	opts := scylla.RetryOptions{MinBackoff: time.Second, MaxBackoff: time.Minute}
+	retrier := jobs.NewIndexRetrier(queue, time.Minute, opts)
+
+	_, err := retrier.RetryOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, opts, queue.opts)
+	assert.Equal(t, jobs.IndexRetryStats{Depth: 3, Succeeded: 2, Failed: 3, Finished: 1}, retrier.Stats())
+
+	// A run that errors part-way counts its retries but keeps the last
+	// complete depth
+	_, err = retrier.RetryOnce(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, jobs.IndexRetryStats{Depth: 3, Succeeded: 4, Failed: 4, Finished: 1}, retrier.Stats())
+}
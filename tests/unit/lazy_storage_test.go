@@ -0,0 +1,59 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/lazy"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyStorageUnavailableUntilSet(t *testing.T) {
+	ctx := context.Background()
+	db := lazy.New()
+
+	assert.False(t, db.Ready())
+	_, err := db.GetUserByID(ctx, "missing")
+	assert.Equal(t, domain.ErrStorageUnavailable, err)
+	assert.NoError(t, db.Close())
+
+	db.Set(memory.NewMemoryStorage())
+	assert.True(t, db.Ready())
+	_, err = db.GetUserByID(ctx, "missing")
+	assert.Equal(t, domain.ErrUserNotFound, err)
+}
+
+func TestLazyConnectRetries(t *testing.T) {
+	attempts := 0
+	opts := lazy.ConnectOptions{Backoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Deadline: time.Second}
+	db, err := lazy.Connect(context.Background(), opts, func() (storage.Storage, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return memory.NewMemoryStorage(), nil
+	})
+	require.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestLazyConnectGivesUpAtDeadline(t *testing.T) {
+	refused := errors.New("connection refused")
+	opts := lazy.ConnectOptions{Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Deadline: 30 * time.Millisecond}
+	start := time.Now()
+	_, err := lazy.Connect(context.Background(), opts, func() (storage.Storage, error) {
+		return nil, refused
+	})
+	assert.ErrorIs(t, err, refused)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = lazy.Connect(context.Background(), lazy.ConnectOptions{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/listquery"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListFilter(t *testing.T) {
+	f, err := listquery.ParseFilter(`is_blocked != true AND gender = female AND created_at >= "2025-01-01T00:00:00Z" ` +
+		`AND created_at < 2025-03-01T00:00:00Z AND created_at > 2025-01-15T00:00:00Z AND date_of_birth <= 1990-01-15`)
+	require.NoError(t, err)
+	require.NotNil(t, f.Blocked)
+	assert.False(t, *f.Blocked)
+	assert.Equal(t, "female", f.Gender)
+	// The later lower bound wins
+	assert.Equal(t, time.Date(2025, 1, 15, 0, 0, 0, 1, time.UTC), f.CreatedAt.From)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), f.CreatedAt.To)
+	assert.True(t, f.UpdatedAt.IsZero())
+	assert.True(t, f.DateOfBirth.From.IsZero())
+	assert.Equal(t, time.Date(1990, 1, 16, 0, 0, 0, 0, time.UTC), f.DateOfBirth.To)
+
+	assert.False(t, f.BlockedOnly())
+
+	f, err = listquery.ParseFilter("  ")
+	require.NoError(t, err)
+	assert.Equal(t, listquery.Filter{}, f)
+	assert.True(t, f.BlockedOnly())
+	f, err = listquery.ParseFilter("is_blocked = true")
+	require.NoError(t, err)
+	assert.True(t, f.BlockedOnly())
+
+	for filter, message := range map[string]string{
+		"is_blocked = maybe":                         "true or false",
+		"is_blocked < true":                          "only supports",
+		"is_blocked = true AND is_blocked = false":   "more than once",
+		"gender = robot":                             "male, female or other",
+		"created_at >= yesterday":                    "RFC 3339",
+		"date_of_birth = 1990-01-15T00:00:00Z":       "date",
+		"updated_at != 2025-01-01T00:00:00Z":         "does not support !=",
+		"email = john@example.com":                   "unknown filter field",
+		"is_blocked = true OR gender = male":         "OR is not supported",
+		"(is_blocked = true)":                        "parentheses",
+		"is_blocked =":                               "incomplete",
+		`gender = "female`:                           "unterminated",
+		"is_blocked == true":                         "unknown operator",
+		"NOT is_blocked = true":                      "NOT is not supported",
+		"is_blocked = true AND created_at >= 2025-1": "RFC 3339",
+		`is_blocked = true gender = "male"`:          "expected AND",
+		`is_blocked = true "AND" gender = male`:      "expected AND",
+		"AND is_blocked = true":                      "expected a comparison",
+		"is_blocked = true AND":                      "incomplete",
+	} {
+		_, err := listquery.ParseFilter(filter)
+		if assert.Error(t, err, filter) {
+			assert.Contains(t, err.Error(), message, filter)
+		}
+	}
+}
+
+func TestParseListOrder(t *testing.T) {
+	order, err := listquery.ParseOrder("")
+	require.NoError(t, err)
+	assert.Equal(t, listquery.Order{}, order)
+	order, err = listquery.ParseOrder("created_at desc")
+	require.NoError(t, err)
+	assert.Equal(t, listquery.Order{Field: listquery.FieldCreatedAt, Desc: true}, order)
+	order, err = listquery.ParseOrder(" last_name  asc ")
+	require.NoError(t, err)
+	assert.Equal(t, listquery.Order{Field: listquery.FieldLastName}, order)
+
+	for _, s := range []string{"email", "created_at up", "last_name, created_at", "created_at desc nulls"} {
+		_, err := listquery.ParseOrder(s)
+		assert.Error(t, err, s)
+	}
+
+	_, _, err = order.ParseCursor("nope")
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+}
+
+// listAll pages through db with opts and returns the last names in order
+func listAll(t *testing.T, db storage.Storage, opts storage.ListOptions) []string {
+	var names []string
+	for page := 0; ; page++ {
+		require.Less(t, page, 20)
+		users, next, err := db.ListUsers(context.Background(), opts)
+		require.NoError(t, err)
+		for _, user := range users {
+			names = append(names, user.LastName)
+		}
+		if next == "" {
+			return names
+		}
+		opts.PageToken = next
+	}
+}
+
+// testListUsersFiltered checks that db filters and orders ListUsers
+func testListUsersFiltered(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	lastNames := []string{"Moss", "Adams", "Zhou", "Baker", "Adams", "Nakamura"}
+	users := make([]*domain.User, len(lastNames))
+	for i, name := range lastNames {
+		user := newTestUser(i + 1)
+		user.LastName = name
+		if i >= 4 {
+			user.Block()
+		}
+		if i%2 == 1 {
+			user.Gender = "female"
+		}
+		user.CreatedAt = time.Date(2025, time.Month(i+1), 10, 12, 0, 0, 0, time.UTC)
+		user.UpdatedAt = user.CreatedAt
+		user.DateOfBirth = time.Date(1980+i*4, 6, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, db.CreateUser(ctx, user))
+		users[i] = user
+	}
+	require.NoError(t, db.DeleteUser(ctx, users[2].ID, nil))
+
+	parse := func(filter, order string) storage.ListOptions {
+		f, err := listquery.ParseFilter(filter)
+		require.NoError(t, err)
+		o, err := listquery.ParseOrder(order)
+		require.NoError(t, err)
+		return storage.ListOptions{Limit: 2, Filter: f, Order: o}
+	}
+
+	assert.Equal(t, []string{"Moss", "Adams", "Baker", "Adams", "Nakamura"}, listAll(t, db, parse("", "created_at")))
+	assert.Equal(t, []string{"Nakamura", "Adams", "Baker", "Adams", "Moss"}, listAll(t, db, parse("", "created_at desc")))
+
+	// Ties on last name go by ID
+	byName := listAll(t, db, parse("", "last_name"))
+	assert.Equal(t, []string{"Adams", "Adams", "Baker", "Moss", "Nakamura"}, byName)
+	assert.Equal(t, []string{"Nakamura", "Moss", "Baker", "Adams", "Adams"}, listAll(t, db, parse("", "last_name desc")))
+	withDeleted := parse("", "last_name")
+	withDeleted.ShowDeleted = true
+	assert.Equal(t, []string{"Adams", "Adams", "Baker", "Moss", "Nakamura", "Zhou"}, listAll(t, db, withDeleted))
+
+	assert.Equal(t, []string{"Adams", "Baker"},
+		listAll(t, db, parse("gender = female AND is_blocked = false", "last_name")))
+	assert.Equal(t, []string{"Adams", "Baker", "Adams"},
+		listAll(t, db, parse(`created_at >= "2025-02-10T12:00:00Z" AND created_at < 2025-05-10T12:00:00.001Z`, "created_at")))
+	assert.Equal(t, []string{"Adams", "Nakamura"}, listAll(t, db, parse("is_blocked = true", "created_at")))
+	assert.Equal(t, []string{"Moss", "Adams"}, listAll(t, db, parse("date_of_birth < 1988-06-01", "created_at")))
+	assert.Equal(t, []string{"Adams"}, listAll(t, db, parse("date_of_birth = 1984-06-01", "")))
+	assert.Empty(t, listAll(t, db, parse("updated_at > 2025-06-10T12:00:00Z", "")))
+
+	// A changed last name moves the user
+	users[0].Update(users[0].FirstName, "Abbott", users[0].Gender, users[0].DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, users[0]))
+	assert.Equal(t, []string{"Abbott", "Adams", "Adams", "Baker", "Nakamura"}, listAll(t, db, parse("", "last_name")))
+	assert.Equal(t, []string{"Abbott"}, listAll(t, db, parse("updated_at > 2025-06-10T12:00:00Z", "")))
+
+	// Case and accents don't matter
+	users[3].Update(users[3].FirstName, "ábel", users[3].Gender, users[3].DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, users[3]))
+	assert.Equal(t, []string{"Abbott", "ábel", "Adams", "Adams", "Nakamura"}, listAll(t, db, parse("", "last_name")))
+	assert.Equal(t, []string{"Nakamura", "Adams", "Adams", "ábel", "Abbott"}, listAll(t, db, parse("", "last_name desc")))
+
+	// Without an order, users come by ID
+	var ids []string
+	opts := parse("is_blocked = false", "")
+	for {
+		page, next, err := db.ListUsers(ctx, opts)
+		require.NoError(t, err)
+		for _, user := range page {
+			ids = append(ids, user.ID)
+		}
+		if next == "" {
+			break
+		}
+		opts.PageToken = next
+	}
+	assert.Len(t, ids, 3)
+	assert.IsIncreasing(t, ids)
+
+	opts = parse("", "created_at")
+	opts.PageToken = "x"
+	_, _, err := db.ListUsers(ctx, opts)
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+}
+
+func TestMemoryStorageListUsersFiltered(t *testing.T) {
+	testListUsersFiltered(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageListUsersFiltered(t *testing.T) {
+	testListUsersFiltered(t, newSQLiteDB(t))
+}
+
+func TestListOrderCursorRoundTrip(t *testing.T) {
+	user := newTestUser(1)
+	user.CreatedAt = time.Date(2025, 1, 10, 12, 0, 0, 123456789, time.FixedZone("", 3600))
+	for _, order := range []listquery.Order{
+		{Field: listquery.FieldCreatedAt},
+		{Field: listquery.FieldLastName, Desc: true},
+	} {
+		key, id, err := order.ParseCursor(order.Cursor(user))
+		require.NoError(t, err, fmt.Sprint(order))
+		assert.Equal(t, user.ID, id)
+		assert.False(t, order.After(user, key, id))
+		other := newTestUser(2)
+		other.CreatedAt = user.CreatedAt
+		other.LastName = user.LastName
+		assert.Equal(t, other.ID > user.ID != order.Desc, order.After(other, key, id))
+	}
+}
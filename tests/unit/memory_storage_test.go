@@ -0,0 +1,282 @@
+package unit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUser(n int) *domain.User {
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	return domain.NewUser("John", "Doe", "male", dob,
+		fmt.Sprintf("+1202555%04d", n), fmt.Sprintf("john%d@example.com", n))
+}
+
+func TestMemoryStorageCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	byID, err := db.GetUserByID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Email, byID.Email)
+
+	byEmail, err := db.GetUserByEmail(ctx, user.Email)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, byEmail.ID)
+
+	byPhone, err := db.GetUserByPhone(ctx, user.PhoneNumber)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, byPhone.ID)
+
+	// Returned users are copies
+	byID.FirstName = "Changed"
+	again, _ := db.GetUserByID(ctx, user.ID)
+	assert.Equal(t, "John", again.FirstName)
+}
+
+func TestMemoryStorageUniqueness(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	sameEmail := newTestUser(2)
+	sameEmail.Email = user.Email
+	assert.ErrorIs(t, db.CreateUser(ctx, sameEmail), domain.ErrEmailAlreadyExists)
+
+	samePhone := newTestUser(3)
+	samePhone.PhoneNumber = user.PhoneNumber
+	assert.ErrorIs(t, db.CreateUser(ctx, samePhone), domain.ErrPhoneAlreadyExists)
+
+	other := newTestUser(4)
+	assert.NoError(t, db.CreateUser(ctx, other))
+	other.Email = user.Email
+	assert.ErrorIs(t, db.UpdateUser(ctx, other), domain.ErrEmailAlreadyExists)
+}
+
+func TestMemoryStorageUpdateContact(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	oldEmail := user.Email
+	newEmail := "new@example.com"
+	user.UpdateContact(nil, &newEmail)
+	assert.NoError(t, db.UpdateUser(ctx, user))
+
+	_, err := db.GetUserByEmail(ctx, oldEmail)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+
+	exists, err := db.CheckEmailExists(ctx, newEmail)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestMemoryStorageNotFound(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+
+	_, err := db.GetUserByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	assert.ErrorIs(t, db.UpdateUser(ctx, newTestUser(1)), domain.ErrUserNotFound)
+	assert.ErrorIs(t, db.DeleteUser(ctx, "missing", nil), domain.ErrUserNotFound)
+}
+
+func TestMemoryStorageSoftDelete(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	stale := user.Version - 1
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID, &stale), domain.ErrVersionConflict)
+	assert.NoError(t, db.DeleteUser(ctx, user.ID, &user.Version))
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID, nil), domain.ErrUserNotFound)
+
+	got, err := db.GetUserByID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.True(t, got.IsDeleted())
+
+	// Email and phone stay reserved until the user is purged
+	assert.ErrorIs(t, db.CreateUser(ctx, newTestUser(1)), domain.ErrEmailAlreadyExists)
+
+	users, _, err := db.ListUsers(ctx, storage.ListOptions{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+
+	users, _, err = db.ListUsers(ctx, storage.ListOptions{Limit: 10, ShowDeleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	count, err := db.CountUsers(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMemoryStorageRestoreUser(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	_, err := db.RestoreUser(ctx, user.ID, nil)
+	assert.ErrorIs(t, err, domain.ErrUserNotDeleted)
+
+	assert.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	// The version read before the delete is stale
+	_, err = db.RestoreUser(ctx, user.ID, &user.Version)
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+
+	restored, err := db.RestoreUser(ctx, user.ID, nil)
+	assert.NoError(t, err)
+	assert.False(t, restored.IsDeleted())
+
+	_, err = db.RestoreUser(ctx, "missing", nil)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func TestMemoryStoragePurgeDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	deleted := newTestUser(1)
+	active := newTestUser(2)
+	assert.NoError(t, db.CreateUser(ctx, deleted))
+	assert.NoError(t, db.CreateUser(ctx, active))
+	assert.NoError(t, db.DeleteUser(ctx, deleted.ID, nil))
+
+	// Nothing was deleted before the cutoff yet
+	purged, err := db.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = db.PurgeDeletedUsers(ctx, time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	_, err = db.GetUserByID(ctx, deleted.ID)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = db.GetUserByID(ctx, active.ID)
+	assert.NoError(t, err)
+
+	// Email and phone are free again
+	assert.NoError(t, db.CreateUser(ctx, newTestUser(1)))
+}
+
+func TestMemoryStorageListUsersPaging(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, db.CreateUser(ctx, newTestUser(i)))
+	}
+
+	seen := make(map[string]bool)
+	token := ""
+	pages := 0
+	for {
+		users, next, err := db.ListUsers(ctx, storage.ListOptions{Limit: 10, PageToken: token})
+		assert.NoError(t, err)
+		for _, u := range users {
+			assert.False(t, seen[u.ID], "user returned twice")
+			seen[u.ID] = true
+		}
+		pages++
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	assert.Equal(t, 3, pages)
+	assert.Len(t, seen, 25)
+}
+
+func TestMemoryStorageConcurrentCreateSameEmail(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	email := "shared@example.com"
+
+	const workers = 16
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := newTestUser(i)
+			u.Email = email
+			errs <- db.CreateUser(ctx, u)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyExists)
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestMemoryStorageCountUsers(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	for i := 0; i < 5; i++ {
+		user := newTestUser(i)
+		if i < 2 {
+			user.Block()
+		}
+		assert.NoError(t, db.CreateUser(ctx, user))
+	}
+
+	blocked, unblocked := true, false
+
+	total, err := db.CountUsers(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	count, err := db.CountUsers(ctx, &blocked)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = db.CountUsers(ctx, &unblocked)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestMemoryStorageUpdateVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+
+	first, _ := db.GetUserByID(ctx, user.ID)
+	second, _ := db.GetUserByID(ctx, user.ID)
+
+	first.Block()
+	assert.NoError(t, db.UpdateUser(ctx, first))
+	assert.Equal(t, int64(2), first.Version)
+
+	second.Update("Jane", "Doe", "female", second.DateOfBirth)
+	assert.ErrorIs(t, db.UpdateUser(ctx, second), domain.ErrVersionConflict)
+
+	stored, _ := db.GetUserByID(ctx, user.ID)
+	assert.True(t, stored.IsBlocked)
+	assert.Equal(t, "John", stored.FirstName)
+	assert.Equal(t, first.ETag(), stored.ETag())
+}
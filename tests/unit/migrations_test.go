@@ -0,0 +1,74 @@
+package unit
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScyllaMigrationsAreWellFormed(t *testing.T) {
+	migrations, err := scylla.Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Up, "migration %d has no up statements", m.Version)
+		assert.NotEmpty(t, m.Down, "migration %d has no down statements", m.Version)
+		for _, stmt := range append(m.Up, m.Down...) {
+			assert.NotContains(t, stmt, ";")
+			assert.NotContains(t, stmt, "--")
+			// Migrations run inside the configured keyspace
+			assert.NotContains(t, strings.ToUpper(stmt), "KEYSPACE")
+		}
+	}
+}
+
+// cqlStatements splits a CQL script into statements with comments dropped and
+// whitespace collapsed
+func cqlStatements(script string) []string {
+	var lines []string
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	var statements []string
+	for _, stmt := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if stmt = strings.Join(strings.Fields(stmt), " "); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements
+}
+
+func TestScyllaInitialMigrationIsBaselineSchema(t *testing.T) {
+	baseline, err := os.ReadFile("../testdata/scylla_baseline_schema.cql")
+	require.NoError(t, err)
+	migrations, err := scylla.Migrations()
+	require.NoError(t, err)
+
+	// Keyspaces created from the baseline skip 0001, so anything it added
+	// beyond the baseline would never reach them
+	assert.Equal(t, cqlStatements(string(baseline)), cqlStatements(strings.Join(migrations[0].Up, ";")))
+
+	// Every users column the service reads that the baseline lacks comes from
+	// a later ALTER TABLE
+	added := make(map[string]bool)
+	for _, m := range migrations[1:] {
+		for _, stmt := range m.Up {
+			words := strings.Fields(stmt)
+			if len(words) > 4 && strings.EqualFold(words[0], "ALTER") && words[2] == "users" && strings.EqualFold(words[3], "ADD") {
+				added[words[4]] = true
+			}
+		}
+	}
+	for _, column := range []string{"email_canonical", "version", "deleted_at"} {
+		assert.True(t, added[column], "no migration adds users.%s", column)
+	}
+}
@@ -0,0 +1,66 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/pagetoken"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	codec := pagetoken.NewCodec("test-secret", time.Hour)
+	cursor := string([]byte{0x00, 0x12, 0xff, 'a', 'b'})
+
+	token := codec.Encode("ListUsers", cursor)
+	assert.NotEmpty(t, token)
+	assert.NotContains(t, token, "ab")
+
+	decoded, err := codec.Decode("ListUsers", token)
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+}
+
+func TestPageTokenEmptyCursor(t *testing.T) {
+	codec := pagetoken.NewCodec("test-secret", time.Hour)
+	assert.Equal(t, "", codec.Encode("ListUsers", ""))
+}
+
+func TestPageTokenRejectsInvalid(t *testing.T) {
+	codec := pagetoken.NewCodec("test-secret", time.Hour)
+	token := codec.Encode("ListUsers", "cursor")
+
+	tampered := []byte(token)
+	tampered[len(tampered)/2] ^= 0x01
+
+	tests := []struct {
+		name  string
+		codec *pagetoken.Codec
+		scope string
+		token string
+	}{
+		{name: "malformed", codec: codec, scope: "ListUsers", token: "not a token!"},
+		{name: "truncated", codec: codec, scope: "ListUsers", token: token[:8]},
+		{name: "tampered", codec: codec, scope: "ListUsers", token: string(tampered)},
+		{name: "other scope", codec: codec, scope: "SearchUsers", token: token},
+		{name: "other secret", codec: pagetoken.NewCodec("other-secret", time.Hour), scope: "ListUsers", token: token},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.codec.Decode(tt.scope, tt.token)
+			assert.ErrorIs(t, err, domain.ErrInvalidPageToken)
+		})
+	}
+}
+
+func TestPageTokenExpires(t *testing.T) {
+	codec := pagetoken.NewCodec("test-secret", time.Millisecond)
+	token := codec.Encode("ListUsers", "cursor")
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := codec.Decode("ListUsers", token)
+	assert.ErrorIs(t, err, domain.ErrInvalidPageToken)
+}
@@ -0,0 +1,27 @@
+package unit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/jobs"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgerRespectsRetention(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewMemoryStorage()
+	user := newTestUser(1)
+	assert.NoError(t, db.CreateUser(ctx, user))
+	assert.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	purged, err := jobs.NewPurger(db, time.Hour, time.Minute).PurgeOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	purged, err = jobs.NewPurger(db, -time.Second, time.Minute).PurgeOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/storage/scylla"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validClusterOptions() scylla.ClusterOptions {
+	return scylla.ClusterOptions{
+		Hosts:             []string{"localhost"},
+		Port:              9042,
+		Keyspace:          "userservice",
+		Consistency:       "LOCAL_QUORUM",
+		SerialConsistency: "LOCAL_SERIAL",
+		LocalDC:           "dc1",
+		TokenAware:        true,
+		ProtoVersion:      4,
+		RetryPolicy:       "exponential",
+		RetryAttempts:     3,
+		RetryMinBackoff:   100 * time.Millisecond,
+		RetryMaxBackoff:   time.Second,
+		NumConns:          4,
+		ConnectTimeout:    5 * time.Second,
+		Timeout:           2 * time.Second,
+	}
+}
+
+func TestNewClusterConfig(t *testing.T) {
+	cluster, err := scylla.NewClusterConfig(validClusterOptions())
+	require.NoError(t, err)
+	assert.Equal(t, gocql.LocalQuorum, cluster.Consistency)
+	assert.Equal(t, gocql.LocalSerial, cluster.SerialConsistency)
+	assert.Equal(t, 4, cluster.NumConns)
+	assert.Equal(t, 2*time.Second, cluster.Timeout)
+	assert.IsType(t, &gocql.ExponentialBackoffRetryPolicy{}, cluster.RetryPolicy)
+	assert.NotNil(t, cluster.PoolConfig.HostSelectionPolicy)
+	assert.Nil(t, cluster.Authenticator)
+	assert.Nil(t, cluster.SslOpts)
+}
+
+func TestNewClusterConfigAuth(t *testing.T) {
+	opts := validClusterOptions()
+	opts.Username, opts.Password = "scylla", "secret"
+	cluster, err := scylla.NewClusterConfig(opts)
+	require.NoError(t, err)
+	assert.Equal(t, gocql.PasswordAuthenticator{Username: "scylla", Password: "secret"}, cluster.Authenticator)
+}
+
+func TestNewClusterConfigRejectsUnknownValues(t *testing.T) {
+	tests := map[string]func(*scylla.ClusterOptions){
+		"consistency":        func(o *scylla.ClusterOptions) { o.Consistency = "MOST" },
+		"serial consistency": func(o *scylla.ClusterOptions) { o.SerialConsistency = "QUORUM" },
+		"retry policy":       func(o *scylla.ClusterOptions) { o.RetryPolicy = "forever" },
+		"backoff bounds":     func(o *scylla.ClusterOptions) { o.RetryMaxBackoff = time.Millisecond },
+		"proto version":      func(o *scylla.ClusterOptions) { o.ProtoVersion = 7 },
+		"num conns":          func(o *scylla.ClusterOptions) { o.NumConns = 0 },
+		"timeout":            func(o *scylla.ClusterOptions) { o.Timeout = 0 },
+		"speculative delay":  func(o *scylla.ClusterOptions) { o.SpeculativeAttempts = 2; o.SpeculativeDelay = 0 },
+		"password only":      func(o *scylla.ClusterOptions) { o.Password = "secret" },
+		"missing CA file": func(o *scylla.ClusterOptions) {
+			o.TLS = scylla.TLSOptions{Enabled: true, CAFile: "/nonexistent/ca.pem"}
+		},
+		"cert without key": func(o *scylla.ClusterOptions) {
+			o.TLS = scylla.TLSOptions{Enabled: true, CertFile: "client.pem"}
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := validClusterOptions()
+			mutate(&opts)
+			_, err := scylla.NewClusterConfig(opts)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseConsistency(t *testing.T) {
+	for name, want := range map[string]gocql.Consistency{
+		"ONE":          gocql.One,
+		"QUORUM":       gocql.Quorum,
+		"local_quorum": gocql.LocalQuorum,
+		"LOCAL_ONE":    gocql.LocalOne,
+		"EACH_QUORUM":  gocql.EachQuorum,
+	} {
+		got, err := scylla.ParseConsistency(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+}
+
+func TestNewClusterConfigOperationOverrides(t *testing.T) {
+	opts := validClusterOptions()
+	opts.Operations = map[scylla.Operation]scylla.OperationOptions{
+		scylla.OpWrite: {Consistency: "EACH_QUORUM", Timeout: time.Second},
+		scylla.OpList:  {Consistency: "LOCAL_ONE", Timeout: 500 * time.Millisecond},
+	}
+	_, err := scylla.NewClusterConfig(opts)
+	assert.NoError(t, err)
+
+	tests := map[string]map[scylla.Operation]scylla.OperationOptions{
+		"unknown consistency":    {scylla.OpRead: {Consistency: "SOME"}},
+		"EACH_QUORUM read":       {scylla.OpRead: {Consistency: "EACH_QUORUM"}},
+		"negative timeout":       {scylla.OpCheck: {Timeout: -time.Second}},
+		"unknown operation name": {scylla.Operation("scan"): {Consistency: "ONE"}},
+	}
+	for name, ops := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := validClusterOptions()
+			opts.Operations = ops
+			_, err := scylla.NewClusterConfig(opts)
+			assert.Error(t, err)
+		})
+	}
+
+	// EACH_QUORUM as the default would apply to reads too
+	opts = validClusterOptions()
+	opts.Consistency = "EACH_QUORUM"
+	_, err = scylla.NewClusterConfig(opts)
+	assert.Error(t, err)
+}
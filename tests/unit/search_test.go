@@ -0,0 +1,257 @@
+package unit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/search"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/memory"
+	"github.com/Divyansh031/user-service/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchFold(t *testing.T) {
+	assert.Equal(t, "zoe", search.Fold("Zoë"))
+	assert.Equal(t, "angstrom", search.Fold("ÅNGSTRÖM"))
+	assert.Equal(t, "strasse", search.Fold("Straße"))
+	assert.Equal(t, "lodz", search.Fold("Łódź"))
+}
+
+func TestSearchParseQuery(t *testing.T) {
+	q, err := search.ParseQuery("  Joh  SMÍTH +1-202-555 ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"joh", "smith", "1202555"}, q.Terms)
+	assert.Equal(t, "sm", search.Partition("smith"))
+	assert.Equal(t, "å", search.Partition("å"))
+	assert.Equal(t, "1202555", q.Longest())
+
+	_, err = search.ParseQuery("j smith")
+	assert.Error(t, err)
+	_, err = search.ParseQuery("   ")
+	assert.Error(t, err)
+	_, err = search.ParseQuery("aa bb cc dd ee ff")
+	assert.Error(t, err)
+}
+
+func TestSearchTokens(t *testing.T) {
+	user := domain.NewUser("Jean-Luc", "O'Brien", "male", time.Now(), "+12025550123", "JL.OBrien+work@Example.com")
+	user.CanonicalEmail = "jl.obrien@example.com"
+	tokens := search.Tokens(user)
+
+	for _, token := range []string{
+		"jean-luc", "jean", "luc", "o'brien", "brien",
+		"jl.obrien@example.com", "jl.obrien+work@example.com", "example.com", "jl", "obrien", "work",
+		"12025550123", "5550123", "0123",
+	} {
+		assert.Contains(t, tokens, token)
+	}
+	// Too short to be matched
+	assert.NotContains(t, tokens, "o")
+	assert.NotContains(t, tokens, "123")
+	assert.IsIncreasing(t, tokens)
+}
+
+func TestSearchRank(t *testing.T) {
+	q, err := search.ParseQuery("joh")
+	require.NoError(t, err)
+
+	var candidates []*domain.User
+	for i := 1; i <= 5; i++ {
+		candidates = append(candidates, newTestUser(i))
+	}
+	johanna := newTestUser(6)
+	johanna.FirstName = "Johanna"
+	johanna.LastName = "Abbott"
+	// Stale index entries are dropped
+	jack := newTestUser(7)
+	jack.FirstName = "Jack"
+	jack.Email = "jack@example.com"
+	candidates = append(candidates, jack, johanna)
+
+	var got []*domain.User
+	token := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 10)
+		users, next, err := search.Rank(q, candidates, 2, token)
+		require.NoError(t, err)
+		got = append(got, users...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	require.Len(t, got, 6)
+	// Same score, so by last name
+	assert.Equal(t, johanna.ID, got[0].ID)
+	for i := 2; i < len(got); i++ {
+		assert.Less(t, got[i-1].ID, got[i].ID)
+	}
+
+	q, err = search.ParseQuery("john")
+	require.NoError(t, err)
+	users, _, err := search.Rank(q, candidates, 10, "")
+	require.NoError(t, err)
+	require.Len(t, users, 6)
+	// An exact first name beats a prefix of one
+	assert.Equal(t, "John", users[0].FirstName)
+	assert.Equal(t, johanna.ID, users[5].ID)
+
+	_, _, err = search.Rank(q, candidates, 10, "x")
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+}
+
+func searchUserIDs(t *testing.T, db storage.Storage, query string, showDeleted bool) []string {
+	q, err := search.ParseQuery(query)
+	require.NoError(t, err)
+	users, _, err := db.SearchUsers(context.Background(), storage.SearchOptions{Query: q, Limit: 100, ShowDeleted: showDeleted})
+	require.NoError(t, err)
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+// testSearchUsers checks that db keeps its search index up to date as users
+// change
+func testSearchUsers(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	zoe := domain.NewUser("Zoë", "Ångström", "female", dob, "+12025550001", "za@example.com")
+	john := domain.NewUser("John", "Smith", "male", dob, "+12025550002", "john.smith@example.org")
+	johanna := domain.NewUser("Johanna", "O'Brien", "female", dob, "+12025550003", "jo@corp.io")
+	for _, user := range []*domain.User{zoe, john, johanna} {
+		require.NoError(t, db.CreateUser(ctx, user))
+	}
+
+	assert.Equal(t, []string{johanna.ID, john.ID}, searchUserIDs(t, db, "JOH", false))
+	assert.Equal(t, []string{john.ID}, searchUserIDs(t, db, "john", false))
+	assert.Equal(t, []string{zoe.ID}, searchUserIDs(t, db, "zoe ANG", false))
+	assert.Equal(t, []string{zoe.ID}, searchUserIDs(t, db, "ångström", false))
+	assert.Equal(t, []string{johanna.ID}, searchUserIDs(t, db, "brien", false))
+	assert.Equal(t, []string{john.ID}, searchUserIDs(t, db, "example.org", false))
+	assert.Equal(t, []string{john.ID}, searchUserIDs(t, db, "555-0002", false))
+	assert.Equal(t, []string{zoe.ID, johanna.ID, john.ID}, searchUserIDs(t, db, "5550", false))
+	assert.Empty(t, searchUserIDs(t, db, "joh zoe", false))
+
+	// Old names stop matching once changed
+	zoe.Update("Chloé", zoe.LastName, zoe.Gender, zoe.DateOfBirth)
+	require.NoError(t, db.UpdateUser(ctx, zoe))
+	assert.Empty(t, searchUserIDs(t, db, "zoe", false))
+	assert.Equal(t, []string{zoe.ID}, searchUserIDs(t, db, "chloe", false))
+
+	require.NoError(t, db.DeleteUser(ctx, johanna.ID, nil))
+	assert.Equal(t, []string{john.ID}, searchUserIDs(t, db, "joh", false))
+	assert.Equal(t, []string{johanna.ID, john.ID}, searchUserIDs(t, db, "joh", true))
+	_, err := db.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{john.ID}, searchUserIDs(t, db, "joh", true))
+
+	// Paging follows the relevance order
+	for i := 1; i <= 5; i++ {
+		user := newTestUser(10 + i)
+		user.LastName = fmt.Sprintf("Doe%d", i)
+		require.NoError(t, db.CreateUser(ctx, user))
+	}
+	q, err := search.ParseQuery("doe")
+	require.NoError(t, err)
+	var lastNames []string
+	token := ""
+	for page := 0; ; page++ {
+		require.Less(t, page, 10)
+		users, next, err := db.SearchUsers(ctx, storage.SearchOptions{Query: q, Limit: 2, PageToken: token})
+		require.NoError(t, err)
+		for _, user := range users {
+			lastNames = append(lastNames, user.LastName)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, []string{"Doe1", "Doe2", "Doe3", "Doe4", "Doe5"}, lastNames)
+
+	_, _, err = db.SearchUsers(ctx, storage.SearchOptions{Query: q, Limit: 2, PageToken: "x"})
+	assert.Equal(t, domain.ErrInvalidPageToken, err)
+}
+
+func TestMemoryStorageSearchUsers(t *testing.T) {
+	testSearchUsers(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageSearchUsers(t *testing.T) {
+	testSearchUsers(t, newSQLiteDB(t))
+}
+
+func TestSQLiteStorageRebuildSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := sqlite.NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.CreateUser(ctx, newTestUser(i)))
+	}
+
+	// Users written before the search index
+	raw, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer raw.Close()
+	_, err = raw.Exec(`DELETE FROM user_search_tokens`)
+	require.NoError(t, err)
+	assert.Empty(t, searchUserIDs(t, db, "john", false))
+
+	n, err := db.RebuildSearchIndex(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Len(t, searchUserIDs(t, db, "john", false), 3)
+}
+
+// testSearchTooBroad checks that db fails searches that more than
+// search.MaxCandidates users match, not counting deleted ones
+func testSearchTooBroad(t *testing.T, db storage.Storage) {
+	ctx := context.Background()
+	var first *domain.User
+	for i := 1; i <= search.MaxCandidates+1; i++ {
+		user := newTestUser(i)
+		user.LastName = "Broad"
+		require.NoError(t, db.CreateUser(ctx, user))
+		if first == nil {
+			first = user
+		}
+	}
+	q, err := search.ParseQuery("broad")
+	require.NoError(t, err)
+
+	// A deleted user doesn't count unless deleted users are searched too
+	require.NoError(t, db.DeleteUser(ctx, first.ID, nil))
+	users, _, err := db.SearchUsers(ctx, storage.SearchOptions{Query: q, Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, users, 10)
+	_, _, err = db.SearchUsers(ctx, storage.SearchOptions{Query: q, Limit: 10, ShowDeleted: true})
+	assert.Equal(t, domain.ErrSearchTooBroad, err)
+
+	user := newTestUser(search.MaxCandidates + 2)
+	user.LastName = "Broad"
+	require.NoError(t, db.CreateUser(ctx, user))
+	_, _, err = db.SearchUsers(ctx, storage.SearchOptions{Query: q, Limit: 10})
+	assert.Equal(t, domain.ErrSearchTooBroad, err)
+
+	// Another term narrows it down
+	assert.Len(t, searchUserIDs(t, db, "broad 5550002", false), 1)
+}
+
+func TestMemoryStorageSearchTooBroad(t *testing.T) {
+	testSearchTooBroad(t, memory.NewMemoryStorage())
+}
+
+func TestSQLiteStorageSearchTooBroad(t *testing.T) {
+	testSearchTooBroad(t, newSQLiteDB(t))
+}
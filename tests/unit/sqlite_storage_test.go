@@ -0,0 +1,238 @@
+package unit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/Divyansh031/user-service/internal/storage"
+	"github.com/Divyansh031/user-service/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSQLiteDB(t *testing.T) *sqlite.SQLiteDB {
+	db, err := sqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteStorageCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	got, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, got.Email)
+	assert.True(t, user.DateOfBirth.Equal(got.DateOfBirth))
+	assert.Equal(t, int64(1), got.Version)
+	assert.False(t, got.IsDeleted())
+
+	got, err = db.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	got, err = db.GetUserByPhone(ctx, user.PhoneNumber)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	_, err = db.GetUserByID(ctx, "missing")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func TestSQLiteStorageUniqueness(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	require.NoError(t, db.CreateUser(ctx, newTestUser(1)))
+
+	dupEmail := newTestUser(2)
+	dupEmail.Email = "john1@example.com"
+	assert.ErrorIs(t, db.CreateUser(ctx, dupEmail), domain.ErrEmailAlreadyExists)
+
+	dupPhone := newTestUser(3)
+	dupPhone.PhoneNumber = "+12025550001"
+	assert.ErrorIs(t, db.CreateUser(ctx, dupPhone), domain.ErrPhoneAlreadyExists)
+
+	other := newTestUser(4)
+	require.NoError(t, db.CreateUser(ctx, other))
+	email := "john1@example.com"
+	other.UpdateContact(nil, &email)
+	assert.ErrorIs(t, db.UpdateUser(ctx, other), domain.ErrEmailAlreadyExists)
+}
+
+func TestSQLiteStorageVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	first, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	second, err := db.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+
+	first.Block()
+	require.NoError(t, db.UpdateUser(ctx, first))
+	assert.Equal(t, int64(2), first.Version)
+
+	second.Update("Jane", "Doe", "female", second.DateOfBirth)
+	assert.ErrorIs(t, db.UpdateUser(ctx, second), domain.ErrVersionConflict)
+
+	missing := newTestUser(2)
+	assert.ErrorIs(t, db.UpdateUser(ctx, missing), domain.ErrUserNotFound)
+}
+
+func TestSQLiteStorageListUsersPaging(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	for i := 0; i < 25; i++ {
+		require.NoError(t, db.CreateUser(ctx, newTestUser(i)))
+	}
+
+	seen := make(map[string]bool)
+	token := ""
+	pages := 0
+	for {
+		users, next, err := db.ListUsers(ctx, storage.ListOptions{Limit: 10, PageToken: token})
+		require.NoError(t, err)
+		pages++
+		for _, u := range users {
+			assert.False(t, seen[u.ID], "user returned twice")
+			seen[u.ID] = true
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Len(t, seen, 25)
+	assert.Equal(t, 3, pages)
+}
+
+func TestSQLiteStorageSoftDeleteAndPurge(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	// A delete based on a stale read loses to the update in between
+	stale := user.Version
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID, &stale), domain.ErrVersionConflict)
+
+	require.NoError(t, db.DeleteUser(ctx, user.ID, &user.Version))
+	restoredFrom := user.Version + 1
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID, nil), domain.ErrUserNotFound)
+
+	users, _, err := db.ListUsers(ctx, storage.ListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	count, err := db.CountUsers(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	_, err = db.RestoreUser(ctx, user.ID, &user.Version)
+	assert.ErrorIs(t, err, domain.ErrVersionConflict)
+	restored, err := db.RestoreUser(ctx, user.ID, &restoredFrom)
+	require.NoError(t, err)
+	assert.False(t, restored.IsDeleted())
+	_, err = db.RestoreUser(ctx, user.ID, nil)
+	assert.ErrorIs(t, err, domain.ErrUserNotDeleted)
+
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+	purged, err := db.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	purged, err = db.PurgeDeletedUsers(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	// Email and phone are free again
+	assert.NoError(t, db.CreateUser(ctx, newTestUser(1)))
+}
+
+func TestSQLiteStorageConcurrentCreateSameEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+
+	const workers = 10
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			u := newTestUser(i)
+			u.Email = "same@example.com"
+			errs <- db.CreateUser(ctx, u)
+		}(i)
+	}
+
+	created := 0
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err == nil {
+			created++
+		} else {
+			assert.ErrorIs(t, err, domain.ErrEmailAlreadyExists)
+		}
+	}
+	assert.Equal(t, 1, created)
+}
+
+func TestSQLiteStorageCanonicalEmail(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+
+	user := newTestUser(1)
+	user.Email = "John.Doe@Example.com"
+	user.CanonicalEmail = "john.doe@example.com"
+	require.NoError(t, db.CreateUser(ctx, user))
+
+	got, err := db.GetUserByEmail(ctx, "john.doe@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "John.Doe@Example.com", got.Email)
+	assert.Equal(t, "john.doe@example.com", got.CanonicalEmail)
+
+	// Only the canonical form has to collide
+	dup := newTestUser(2)
+	dup.Email = "JOHN.DOE@example.com"
+	dup.CanonicalEmail = "john.doe@example.com"
+	assert.ErrorIs(t, db.CreateUser(ctx, dup), domain.ErrEmailAlreadyExists)
+}
+
+func TestSQLiteStorageWritesEventsWithChanges(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	user := newTestUser(1)
+	require.NoError(t, db.CreateUser(ctx, user))
+	user.Block()
+	require.NoError(t, db.UpdateUser(ctx, user))
+	require.NoError(t, db.DeleteUser(ctx, user.ID, nil))
+
+	// Writes that fail leave no event behind
+	dup := newTestUser(2)
+	dup.Email = user.Email
+	assert.ErrorIs(t, db.CreateUser(ctx, dup), domain.ErrEmailAlreadyExists)
+	assert.ErrorIs(t, db.DeleteUser(ctx, user.ID, nil), domain.ErrUserNotFound)
+
+	pending, err := db.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 3)
+	assert.Equal(t, domain.EventUserCreated, pending[0].Type)
+	assert.Equal(t, domain.EventUserBlocked, pending[1].Type)
+	assert.False(t, pending[1].Before.IsBlocked)
+	assert.True(t, pending[1].After.IsBlocked)
+	assert.Equal(t, domain.EventUserDeleted, pending[2].Type)
+	assert.Equal(t, int64(3), pending[2].After.Version)
+	assert.True(t, pending[2].After.IsDeleted())
+
+	require.NoError(t, db.AckEvents(ctx, []string{pending[0].ID, pending[1].ID}))
+	pending, err = db.PendingEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, domain.EventUserDeleted, pending[0].Type)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/Divyansh031/user-service/internal/domain"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,6 +24,8 @@ func TestNewUser(t *testing.T) {
 	assert.False(t, user.IsBlocked)
 	assert.False(t, user.CreatedAt.IsZero())
 	assert.False(t, user.UpdatedAt.IsZero())
+	assert.Equal(t, int64(1), user.Version)
+	assert.Equal(t, "1", user.ETag())
 }
 
 func TestUserValidation(t *testing.T) {
@@ -188,4 +191,54 @@ func TestUserUpdateContactPartial(t *testing.T) {
 
 	assert.Equal(t, originalPhone, user.PhoneNumber) // Phone unchanged
 	assert.Equal(t, newEmail, user.Email)            // Email updated
-}
\ No newline at end of file
+}
+
+func TestNewUserEventClassifiesChange(t *testing.T) {
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	before := domain.NewUser("John", "Doe", "male", dob, "+12025550001", "john@example.com")
+
+	change := func(fn func(u *domain.User)) domain.EventType {
+		after := *before
+		fn(&after)
+		return domain.NewUserEvent(before, &after).Type
+	}
+	phone := "+12025550002"
+
+	assert.Equal(t, domain.EventUserCreated, domain.NewUserEvent(nil, before).Type)
+	assert.Equal(t, domain.EventUserBlocked, change(func(u *domain.User) { u.Block() }))
+	assert.Equal(t, domain.EventUserContactChanged, change(func(u *domain.User) { u.UpdateContact(&phone, nil) }))
+	assert.Equal(t, domain.EventUserUpdated, change(func(u *domain.User) { u.Update("Jane", "Doe", "female", dob) }))
+	assert.Equal(t, domain.EventUserDeleted, change(func(u *domain.User) { u.Block(); u.Delete() }))
+
+	// The event keeps its own copies of the snapshots
+	event := domain.NewUserEvent(nil, before)
+	before.FirstName = "Changed"
+	assert.Equal(t, "John", event.After.FirstName)
+
+	// The Scylla outbox is bucketed by the time in the ID
+	id, err := uuid.Parse(event.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(1), id.Version())
+}
+
+func TestUserRevert(t *testing.T) {
+	dob := time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)
+	snapshot := domain.NewUser("John", "Doe", "male", dob, "+12025550001", "John@Example.com")
+	snapshot.CanonicalEmail = "john@example.com"
+	user := *snapshot
+	user.Update("Jane", "Roe", "female", dob.AddDate(1, 0, 0))
+	user.Block()
+	user.Version = 5
+	updatedAt := user.UpdatedAt
+
+	user.Revert(snapshot)
+	assert.Equal(t, "John", user.FirstName)
+	assert.Equal(t, "Doe", user.LastName)
+	assert.Equal(t, "male", user.Gender)
+	assert.Equal(t, dob, user.DateOfBirth)
+	assert.Equal(t, "John@Example.com", user.Email)
+	assert.Empty(t, user.CanonicalEmail)
+	assert.False(t, user.IsBlocked)
+	assert.Equal(t, int64(5), user.Version)
+	assert.False(t, user.UpdatedAt.Before(updatedAt))
+}